@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// clfTimeFormat is the timestamp format of the Common Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// NewAccessLog wraps the handler to write a line per request to w in the
+// given format. The only format is clf, the Common Log Format of Apache and
+// NGINX, which log analysis tools can read as is:
+//
+//	127.0.0.1 - - [16/Oct/2026:09:05:27 +0000] "GET /extensions/ HTTP/1.1" 200 1234
+//
+// Websocket connections are logged once they are closed.
+func NewAccessLog(handler http.Handler, format string, w io.Writer) (http.Handler, error) {
+	if format != "clf" {
+		return nil, fmt.Errorf("unsupported access log format %q, supported formats: clf", format)
+	}
+
+	var mutex sync.Mutex
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &accessLogResponseWriter{ResponseWriter: rw}
+		handler.ServeHTTP(recorder, r)
+
+		line := formatCLF(r, start, recorder.status(), recorder.bytes)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		io.WriteString(w, line)
+	}), nil
+}
+
+func formatCLF(r *http.Request, start time.Time, status int, bytes int64) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if host == "" {
+		host = "-"
+	}
+
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+
+	size := "-"
+	if bytes > 0 {
+		size = strconv.FormatInt(bytes, 10)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] %q %d %s\n", host, user, start.Format(clfTimeFormat), r.Method+" "+uri+" "+r.Proto, status, size)
+}
+
+// accessLogResponseWriter records the status and size of responses. It can be
+// hijacked for websocket upgrades.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int64
+}
+
+func (w *accessLogResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessLogResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer doesn't support hijacking")
+	}
+	w.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (w *accessLogResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *accessLogResponseWriter) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -6,11 +6,16 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"net/http"
-	"path/filepath"
+	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Shopify/shopify-cli-extensions/core"
@@ -18,47 +23,197 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// reconnectBackoff is the delay suggested to clients before reconnecting
+const reconnectBackoff = 1 * time.Second
+
+const defaultMaxConnections = 100
+
+// defaultCloseTimeout is how long websocket clients get to acknowledge the
+// close message before the connection is closed
+const defaultCloseTimeout = 1 * time.Second
+
+// Websocket subprotocols selecting the format of status updates. Version 1
+// only has the type and extensions of an update. Clients that don't request
+// a subprotocol receive the latest format.
+const (
+	protocolV1 = "shopify-extensions-v1"
+	protocolV2 = "shopify-extensions-v2"
+)
+
+var v1MessageTypes = map[string]bool{"connected": true, "success": true, "error": true}
+
+// supportedProtocols is ordered by preference
+var supportedProtocols = []string{protocolV2, protocolV1}
+
 func New(config *core.Config) *ExtensionsApi {
-	mux := mux.NewRouter()
+	mux := mux.NewRouter().StrictSlash(config.StrictSlash)
+
+	if upstream := newUpstreamProxy(config); upstream != nil {
+		// The root belongs to the upstream dev server rather than redirecting
+		mux.NotFoundHandler = upstream
+	} else if !config.AssetsOnly {
+		redirectStatus := getRedirectStatus(config)
+		mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+			http.Redirect(rw, r, "/extensions/", redirectStatus)
+		})
+	}
 
-	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
-		http.Redirect(rw, r, "/extensions/", http.StatusTemporaryRedirect)
-	})
+	api := configureExtensionsApi(config, mux, os.DirFS)
+	mux.HandleFunc("/status", api.statusHandler)
 
-	api := configureExtensionsApi(config, mux)
+	for _, app := range config.Apps {
+		api.apps = append(api.apps, configureExtensionsApi(config.ForApp(app), mux, os.DirFS))
+	}
+
+	// The limit of concurrent asset reads is shared by all apps
+	if config.MaxConcurrentAssetReads > 0 {
+		assetReads := make(chan struct{}, config.MaxConcurrentAssetReads)
+		for _, namespace := range api.namespaces() {
+			namespace.assetReads = assetReads
+		}
+	}
+
+	api.commands = api.Methods("POST").Subrouter().StrictSlash(false)
 
 	return api
 }
 
+// getRedirectStatus returns the status code used to redirect from the root,
+// which is validated when the configuration is loaded. It defaults to 307
+// since browsers cache permanent redirects (301, 308) indefinitely, so
+// developers would keep getting redirected to a stale location after the
+// root changed.
+func getRedirectStatus(config *core.Config) int {
+	if config.RedirectStatus == 0 {
+		return http.StatusTemporaryRedirect
+	}
+	return config.RedirectStatus
+}
+
+// HandleCommand registers a POST endpoint. Trailing slash redirects are never
+// applied to commands, even with strict_slash enabled, since clients follow
+// a redirect with a GET request and drop the body.
+func (api *ExtensionsApi) HandleCommand(path string, handler http.HandlerFunc) {
+	api.commands.HandleFunc(path, handler)
+}
+
+// Notify sends the status update to the clients of the app the updated
+// extensions belong to. Updates without extensions are sent to all clients.
+// Updates of a single extension are debounced, only the latest update within
+// notify_debounce is sent. build_progress updates are sent right away, they
+// would otherwise replace the result of the previous build.
 func (api *ExtensionsApi) Notify(statusUpdate StatusUpdate) {
-	api.connections.Range(func(_, clientHandlers interface{}) bool {
-		clientHandlers.(client).notify(statusUpdate)
-		return true
-	})
+	if window := api.notifyDebounce(); window > 0 && len(statusUpdate.Extensions) == 1 && statusUpdate.Type != "build_progress" {
+		api.debouncer.debounce(statusUpdate.Extensions[0].UUID, window, statusUpdate, api.broadcast)
+		return
+	}
+	api.broadcast(statusUpdate)
+}
+
+func (api *ExtensionsApi) broadcast(statusUpdate StatusUpdate) {
+	for _, namespace := range api.namespaces() {
+		if !namespace.concerns(statusUpdate) {
+			continue
+		}
+
+		update := namespace.withAssetUrl(statusUpdate)
+		namespace.pollLog.add(update)
+		namespace.connections.Range(func(_, clientHandlers interface{}) bool {
+			clientHandlers.(client).notify(update)
+			return true
+		})
+	}
 }
 
+// Shutdown closes the connections of all websocket clients. Clients are
+// closed concurrently, so shutting down takes at most the close timeout
+// however many clients are connected.
 func (api *ExtensionsApi) Shutdown() {
-	api.connections.Range(func(_, clientHandlers interface{}) bool {
-		clientHandlers.(client).close(1000, "server shut down")
+	api.debouncer.stop()
+
+	var wg sync.WaitGroup
+	for _, namespace := range api.namespaces() {
+		namespace.connections.Range(func(_, clientHandlers interface{}) bool {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				clientHandlers.(client).close(1000, "server shut down")
+			}()
+			return true
+		})
+	}
+	wg.Wait()
+}
+
+// namespaces returns the API of the top level extensions followed by the
+// APIs of all apps.
+func (api *ExtensionsApi) namespaces() []*ExtensionsApi {
+	return append([]*ExtensionsApi{api}, api.apps...)
+}
+
+// withAssetUrl links the changed asset of an update to the asset route of
+// the namespace
+func (api *ExtensionsApi) withAssetUrl(statusUpdate StatusUpdate) StatusUpdate {
+	if statusUpdate.Asset == nil || statusUpdate.Asset.Url != "" || len(statusUpdate.Extensions) != 1 {
+		return statusUpdate
+	}
+
+	asset := *statusUpdate.Asset
+	asset.Url = api.assetUrl(statusUpdate.Extensions[0], asset.Name)
+	statusUpdate.Asset = &asset
+	return statusUpdate
+}
+
+// assetUrl returns the URL a file of the build directory is served at
+func (api *ExtensionsApi) assetUrl(extension core.Extension, name string) string {
+	return fmt.Sprintf("%s%s/extensions/%s/assets/%s", api.config.PublicBaseUrl(extension.Type), api.config.AssetBase(), extension.UUID, name)
+}
+
+func (api *ExtensionsApi) concerns(statusUpdate StatusUpdate) bool {
+	if len(statusUpdate.Extensions) == 0 {
 		return true
-	})
+	}
+
+	for _, extension := range statusUpdate.Extensions {
+		if _, found := api.findExtension(extension.UUID); found {
+			return true
+		}
+	}
+	return false
 }
 
-func configureExtensionsApi(config *core.Config, router *mux.Router) *ExtensionsApi {
+// configureExtensionsApi registers the routes of the extensions of a config.
+// openDir opens the build directories assets are served from, tests can
+// serve assets from memory instead of os.DirFS.
+func configureExtensionsApi(config *core.Config, router *mux.Router, openDir func(dir string) fs.FS) *ExtensionsApi {
 	api := &ExtensionsApi{
-		core.NewExtensionService(config),
-		router,
-		sync.Map{},
+		ExtensionService: core.NewExtensionService(config),
+		Router:           router,
+		openDir:          openDir,
+		config:           config,
+		sessionId:        newSessionId(),
+		bytesServed:      make(map[string]*uint64),
 	}
 
-	api.HandleFunc("/extensions/", api.extensionsHandler)
+	root := config.ApiRoot
+	if !config.AssetsOnly {
+		api.HandleFunc(root+"/extensions/", compress(api.extensionsHandler))
+		// Registered before the extension routes, which would match it
+		api.HandleFunc(root+"/extensions/poll", api.pollHandler).Methods("GET")
+		api.HandleFunc(root+"/extensions/{uuid}", compress(api.extensionRootHandler))
+		api.HandleFunc(root+"/extensions/{uuid}/icon", api.extensionIconHandler)
+		api.HandleFunc(root+"/extensions/{uuid}/assets.zip", api.extensionArchiveHandler).Methods("GET")
+		api.HandleFunc(root+"/metrics", api.metricsHandler)
+		api.HandleFunc(root+"/manifest/full", compress(api.fullManifestHandler)).Methods("GET")
+
+		if config.DebugEndpoints {
+			api.HandleFunc(root+"/extensions/{uuid}/debug", api.extensionDebugHandler)
+		}
+	}
 
 	for _, extension := range api.Extensions {
-		prefix := fmt.Sprintf("/extensions/%s/assets/", extension.UUID)
-		buildDir := filepath.Join(".", extension.Development.RootDir, extension.Development.BuildDir)
-		api.PathPrefix(prefix).Handler(
-			http.StripPrefix(prefix, http.FileServer(http.Dir(buildDir))),
-		)
+		prefix := fmt.Sprintf("%s/extensions/%s/assets/", config.AssetBase(), extension.UUID)
+		api.PathPrefix(prefix).Handler(api.assetHandler(extension, prefix))
 	}
 
 	return api
@@ -79,51 +234,249 @@ func (api *ExtensionsApi) sendStatusUpdates(rw http.ResponseWriter, r *http.Requ
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
+		Subprotocols: supportedProtocols,
+	}
+
+	if !isSupportedProtocolRequest(r) {
+		http.Error(rw, fmt.Sprintf("unsupported websocket protocol, supported protocols: %s", strings.Join(supportedProtocols, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	if !api.reserveConnection() {
+		http.Error(rw, "too many open connections, try again later", http.StatusServiceUnavailable)
+		return
 	}
 
 	connection, err := upgrader.Upgrade(rw, r, nil)
 	if err != nil {
+		api.releaseConnection()
 		return
 	}
 
 	notifications := make(chan StatusUpdate)
 
-	close := func(closeCode int, message string) error {
-		api.unregisterClient(connection, closeCode, message)
-		close(notifications)
+	// Only the send loop below writes to the connection, gorilla/websocket
+	// doesn't support concurrent writers. Closing the client signals the loop,
+	// which writes the close frame and unregisters the client.
+	done := make(chan struct{})
+	closed := make(chan struct{})
+	var closeOnce sync.Once
+	var closeCode int
+	var closeMessage string
+	stop := func(code int, message string) error {
+		closeOnce.Do(func() {
+			closeCode, closeMessage = code, message
+			close(done)
+		})
 		return nil
 	}
 
-	connection.SetCloseHandler(close)
+	connection.SetCloseHandler(stop)
 
 	api.registerClient(connection, func(update StatusUpdate) {
-		notifications <- update
-	}, close)
+		if update, visible := visibleUpdate(r, update); visible {
+			select {
+			case notifications <- update:
+			case <-done:
+			}
+		}
+	}, func(code int, message string) error {
+		stop(code, message)
+		<-closed
+		return nil
+	})
+
+	defer func() {
+		api.unregisterClient(connection, closeCode, closeMessage)
+		close(closed)
+	}()
 
-	err = api.writeJSONMessage(connection, &StatusUpdate{Type: "connected", Extensions: api.Extensions})
+	protocol := connection.Subprotocol()
+
+	err = api.writeJSONMessage(connection, protocol, &StatusUpdate{
+		Type:             "connected",
+		Extensions:       visibleExtensions(r, api.getExtensions()),
+		SessionId:        api.sessionId,
+		ReconnectBackoff: reconnectBackoff.Milliseconds(),
+	})
 
 	if err != nil {
-		close(websocket.CloseNoStatusReceived, "cannot establish connection to client")
+		stop(websocket.CloseNoStatusReceived, "cannot establish connection to client")
 		return
 	}
 
-	go handleClientMessages(connection)
-
-	for notification := range notifications {
-		encoder := json.NewEncoder(rw)
-		encoder.Encode(extensionsResponse{api.Extensions, api.Version})
+	go handleClientMessages(connection, api.handleClientMessage, func() {
+		stop(websocket.CloseGoingAway, "connection lost")
+	})
 
-		err = api.writeJSONMessage(connection, &notification)
-		if err != nil {
-			break
+	for {
+		select {
+		case notification := <-notifications:
+			encoder := json.NewEncoder(rw)
+			encoder.Encode(api.extensionsResponse(r))
+
+			err = api.writeJSONMessage(connection, protocol, &notification)
+			if err != nil {
+				stop(websocket.CloseGoingAway, "connection lost")
+			}
+		case <-done:
+			return
 		}
 	}
 }
 
 func (api *ExtensionsApi) listExtensions(rw http.ResponseWriter, r *http.Request) {
+	response := api.extensionsResponse(r)
+	if types := r.URL.Query()["type"]; len(types) > 0 {
+		response.Extensions = filterByType(response.Extensions, types)
+	}
+	if labels := r.URL.Query()["label"]; len(labels) > 0 {
+		response.Extensions = filterByLabel(response.Extensions, labels)
+	}
+
+	switch groupBy := r.URL.Query().Get("group_by"); groupBy {
+	case "":
+	case "group":
+		api.encodeManifest(rw, r, groupedExtensionsResponse{groupExtensions(response.Extensions), response.Version, response.Store, response.Warnings})
+		return
+	default:
+		http.Error(rw, fmt.Sprintf("unsupported group_by %q, supported values: group", groupBy), http.StatusBadRequest)
+		return
+	}
+
+	// Older hosts expect a bare array of extensions without the version
+	if r.URL.Query().Get("format") == "flat" {
+		api.encodeManifest(rw, r, response.Extensions)
+		return
+	}
+
+	api.streamExtensionsResponse(rw, r, response)
+}
+
+// filterByType keeps the extensions of any of the given types, so hosts only
+// interested in some types don't have to download all extensions
+func filterByType(extensions []core.Extension, types []string) []core.Extension {
+	filtered := make([]core.Extension, 0, len(extensions))
+	for _, extension := range extensions {
+		for _, extensionType := range types {
+			if extension.Type == extensionType {
+				filtered = append(filtered, extension)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// groupExtensions nests the extensions under the name of their group,
+// extensions without a group end up in defaultGroup
+func groupExtensions(extensions []core.Extension) map[string][]core.Extension {
+	groups := make(map[string][]core.Extension)
+	for _, extension := range extensions {
+		group := extension.Group
+		if group == "" {
+			group = defaultGroup
+		}
+		groups[group] = append(groups[group], extension)
+	}
+	return groups
+}
+
+// filterByLabel keeps the extensions tagged with any of the given labels
+func filterByLabel(extensions []core.Extension, labels []string) []core.Extension {
+	filtered := make([]core.Extension, 0, len(extensions))
+	for _, extension := range extensions {
+		for _, label := range labels {
+			if extension.HasLabel(label) {
+				filtered = append(filtered, extension)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// extensionsResponse lists the extensions visible to the request, see
+// visibleExtensions
+func (api *ExtensionsApi) extensionsResponse(r *http.Request) extensionsResponse {
+	extensions := api.getExtensions()
+	return extensionsResponse{visibleExtensions(r, extensions), api.Version, api.config.Store, visibleWarnings(r, extensions, api.Warnings)}
+}
+
+func (api *ExtensionsApi) extensionRootHandler(rw http.ResponseWriter, r *http.Request) {
+	extension, found := api.findExtension(mux.Vars(r)["uuid"])
+	if !found {
+		api.extensionNotFound(rw, r)
+		return
+	}
+
+	if !requirePreviewToken(rw, r, extension) {
+		return
+	}
+	extension = withPreviewToken(extension)
+
+	contentType := negotiateContentType(r.Header.Get("Accept"), []string{"application/json", "text/html"}, api.defaultAccept())
+	if contentType == "text/html" {
+		api.handleExtensionHtmlRequest(rw, r, extension)
+		return
+	}
+
 	rw.Header().Add("Content-Type", "application/json")
-	encoder := json.NewEncoder(rw)
-	encoder.Encode(extensionsResponse{api.Extensions, api.Version})
+	encoder := api.newJSONEncoder(rw, r)
+	encoder.Encode(singleExtensionResponse{extension, api.Version})
+}
+
+// extensionNotFound redirects browsers asking for the page of an unknown
+// extension to not_found_redirect when it's set. API clients always get a
+// 404.
+func (api *ExtensionsApi) extensionNotFound(rw http.ResponseWriter, r *http.Request) {
+	if api.config.NotFoundRedirect != "" {
+		contentType := negotiateContentType(r.Header.Get("Accept"), []string{"application/json", "text/html"}, api.defaultAccept())
+		if contentType == "text/html" {
+			http.Redirect(rw, r, api.config.NotFoundRedirect, http.StatusTemporaryRedirect)
+			return
+		}
+	}
+	http.NotFound(rw, r)
+}
+
+func (api *ExtensionsApi) findExtension(uuid string) (core.Extension, bool) {
+	for _, extension := range api.getExtensions() {
+		if extension.UUID == uuid {
+			return extension, true
+		}
+	}
+	return core.Extension{}, false
+}
+
+// defaultAccept is the content type served to clients that don't express a
+// preference, i.e. when the Accept header is missing or */*.
+func (api *ExtensionsApi) defaultAccept() string {
+	if api.config.DefaultAccept == "text/html" {
+		return "text/html"
+	}
+	return "application/json"
+}
+
+// reserveConnection counts a new client towards max_connections before the
+// connection is upgraded. sync.Map can't tell its size cheaply.
+func (api *ExtensionsApi) reserveConnection() bool {
+	if atomic.AddInt64(&api.connectionCount, 1) > api.maxConnections() {
+		api.releaseConnection()
+		return false
+	}
+	return true
+}
+
+func (api *ExtensionsApi) releaseConnection() {
+	atomic.AddInt64(&api.connectionCount, -1)
+}
+
+func (api *ExtensionsApi) maxConnections() int64 {
+	if api.config.MaxConnections > 0 {
+		return int64(api.config.MaxConnections)
+	}
+	return defaultMaxConnections
 }
 
 func (api *ExtensionsApi) registerClient(connection *websocket.Conn, notify notificationHandler, close closeHandler) bool {
@@ -132,49 +485,161 @@ func (api *ExtensionsApi) registerClient(connection *websocket.Conn, notify noti
 }
 
 func (api *ExtensionsApi) unregisterClient(connection *websocket.Conn, closeCode int, message string) {
-	duration := 1 * time.Second
+	duration := api.closeTimeout()
 	deadline := time.Now().Add(duration)
 
 	connection.SetWriteDeadline(deadline)
 	connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, message))
 
-	// TODO: Break out of this 1 second wait if the client responds correctly to the close message
+	// TODO: Break out of this wait if the client responds correctly to the close message
 	<-time.After(duration)
 	connection.Close()
 	api.connections.Delete(connection)
+	api.releaseConnection()
 }
 
-func (api *ExtensionsApi) writeJSONMessage(connection *websocket.Conn, statusUpdate *StatusUpdate) error {
+// closeTimeout is part of the shutdown grace period, so it never exceeds
+// shutdown_timeout
+func (api *ExtensionsApi) closeTimeout() time.Duration {
+	if api.config.ShutdownTimeout < 0 {
+		return 0
+	}
+	if api.config.ShutdownTimeout > 0 && api.config.ShutdownTimeout < defaultCloseTimeout {
+		return api.config.ShutdownTimeout
+	}
+	return defaultCloseTimeout
+}
+
+// writeJSONMessage serializes the status update in the format of the
+// negotiated subprotocol
+func (api *ExtensionsApi) writeJSONMessage(connection *websocket.Conn, protocol string, statusUpdate *StatusUpdate) error {
 	connection.SetWriteDeadline(time.Now().Add(1 * time.Second))
+
+	if protocol == protocolV1 {
+		// Version 1 clients reload the extension instead of swapping the asset
+		if statusUpdate.Type == "asset_changed" {
+			return connection.WriteJSON(statusUpdateV1{"success", statusUpdate.Extensions})
+		}
+		// Version 1 clients don't know about newer message types
+		if !v1MessageTypes[statusUpdate.Type] {
+			return nil
+		}
+		return connection.WriteJSON(statusUpdateV1{statusUpdate.Type, statusUpdate.Extensions})
+	}
 	return connection.WriteJSON(statusUpdate)
 }
 
-func handleClientMessages(connection *websocket.Conn) {
-	// TODO: Handle messages from the client
-	// Currently we don't do anything with the messages
-	// but the code is needed to establish a two-way connection
+// isSupportedProtocolRequest rejects clients that only request subprotocols
+// we don't speak, rather than silently falling back to the latest format.
+func isSupportedProtocolRequest(r *http.Request) bool {
+	requested := websocket.Subprotocols(r)
+	if len(requested) == 0 {
+		return true
+	}
+
+	for _, protocol := range requested {
+		for _, supported := range supportedProtocols {
+			if protocol == supported {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newSessionId identifies a server run so that reconnecting clients can tell
+// whether they reached a restarted server.
+func newSessionId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+func handleClientMessages(connection *websocket.Conn, onMessage func([]byte), onDisconnect func()) {
 	for {
-		_, _, err := connection.ReadMessage()
+		_, message, err := connection.ReadMessage()
 		if err != nil {
 			break
 		}
+		onMessage(message)
 	}
+	onDisconnect()
 }
 
 type ExtensionsApi struct {
+	// connectionCount is accessed atomically and needs to stay 64-bit aligned
+	connectionCount int64
 	*core.ExtensionService
 	*mux.Router
+	config      *core.Config
+	sessionId   string
+	commands    *mux.Router
+	apps        []*ExtensionsApi
 	connections sync.Map
+	// extensionsMutex guards the focus of the extensions
+	extensionsMutex sync.RWMutex
+	bytesServed     map[string]*uint64
+	debouncer       debouncer
+	pollLog         pollLog
+	// assetReads limits the assets served concurrently, nil without limit
+	assetReads chan struct{}
+	// openDir opens build directories, os.DirFS unless testing
+	openDir func(dir string) fs.FS
 }
 
 type StatusUpdate struct {
 	Type       string           `json:"type"`
 	Extensions []core.Extension `json:"extensions"`
+	// SessionId and ReconnectBackoff are only sent with the connected message.
+	// Clients should wait ReconnectBackoff milliseconds plus some random jitter
+	// before reconnecting and reload everything when the session id changed.
+	SessionId        string `json:"sessionId,omitempty"`
+	ReconnectBackoff int64  `json:"reconnectBackoff,omitempty"`
+	// Duration is the time in milliseconds a completed build took
+	Duration int64 `json:"duration,omitempty"`
+	// Asset is sent with asset_changed updates, which hosts can handle by
+	// swapping the asset rather than reloading the extension
+	Asset *ChangedAsset `json:"asset,omitempty"`
+	// Progress is sent with build_progress updates, the approximate
+	// percentage of the build or -1 when it can't be determined
+	Progress *int `json:"progress,omitempty"`
+}
+
+// ChangedAsset is a file of the build directory that changed on its own.
+// The URL is filled in for each namespace when the update is sent.
+type ChangedAsset struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// statusUpdateV1 is the format of status updates for shopify-extensions-v1
+type statusUpdateV1 struct {
+	Type       string           `json:"type"`
+	Extensions []core.Extension `json:"extensions"`
 }
 
 type extensionsResponse struct {
 	Extensions []core.Extension `json:"extensions"`
 	Version    string           `json:"version"`
+	Store      string           `json:"store,omitempty"`
+	Warnings   []string         `json:"warnings,omitempty"`
+}
+
+// defaultGroup holds the extensions without a group in grouped responses
+const defaultGroup = "default"
+
+type groupedExtensionsResponse struct {
+	Groups   map[string][]core.Extension `json:"groups"`
+	Version  string                      `json:"version"`
+	Store    string                      `json:"store,omitempty"`
+	Warnings []string                    `json:"warnings,omitempty"`
+}
+
+type singleExtensionResponse struct {
+	Extension core.Extension `json:"extension"`
+	Version   string         `json:"version"`
 }
 
 type client struct {
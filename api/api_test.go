@@ -1,18 +1,32 @@
 package api
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/Shopify/shopify-cli-extensions/core"
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -36,6 +50,32 @@ func init() {
 	}
 }
 
+func TestRootRedirect(t *testing.T) {
+	tests := []struct {
+		configured int
+		expected   int
+	}{
+		{0, http.StatusTemporaryRedirect},
+		{http.StatusFound, http.StatusFound},
+	}
+
+	for _, test := range tests {
+		redirectConfig := *config
+		redirectConfig.RedirectStatus = test.configured
+
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		New(&redirectConfig).ServeHTTP(rec, req)
+
+		if rec.Code != test.expected || rec.Header().Get("Location") != "/extensions/" {
+			t.Errorf("Expected redirect with status %d, got %d to %s", test.expected, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
+
 func TestGetExtensions(t *testing.T) {
 	req, err := http.NewRequest("GET", "/extensions/", nil)
 	if err != nil {
@@ -85,8 +125,8 @@ func TestGetExtensions(t *testing.T) {
 	}
 }
 
-func TestServeAssets(t *testing.T) {
-	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+func TestGetExtensionsFlatFormat(t *testing.T) {
+	req, err := http.NewRequest("GET", "/extensions/?format=flat", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -95,63 +135,2066 @@ func TestServeAssets(t *testing.T) {
 	api := New(config)
 	api.ServeHTTP(rec, req)
 
-	if rec.Body.String() != "console.log(\"Hello World!\");\n" {
-		t.Error("Unexpected body")
-		t.Log(rec.Body)
+	extensions := []core.Extension{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &extensions); err != nil {
+		t.Fatalf("Expected a bare array of extensions: %v", err)
+	}
+
+	if len(extensions) != 1 || extensions[0].UUID != "00000000-0000-0000-0000-000000000000" {
+		t.Errorf("Unexpected extensions %+v", extensions)
 	}
 }
 
-func TestWebsocketNotify(t *testing.T) {
+func TestGetExtensionsFilteredByType(t *testing.T) {
+	typesConfig := *config
+	typesConfig.Extensions = []core.Extension{
+		{UUID: "1", Type: "checkout_ui_extension", Labels: []string{"team-checkout"}},
+		{UUID: "2", Type: "product_subscription", Labels: []string{"experimental"}},
+		{UUID: "3", Type: "checkout_post_purchase", Labels: []string{"team-checkout"}},
+	}
+	api := New(&typesConfig)
+
+	tests := []struct {
+		query string
+		uuids []string
+	}{
+		{"", []string{"1", "2", "3"}},
+		{"?type=checkout_ui_extension", []string{"1"}},
+		{"?type=checkout_ui_extension&type=checkout_post_purchase", []string{"1", "3"}},
+		{"?type=unknown", []string{}},
+		{"?type=product_subscription&format=flat", []string{"2"}},
+		{"?label=team-checkout", []string{"1", "3"}},
+		{"?label=experimental&label=team-checkout", []string{"1", "2", "3"}},
+		{"?label=team-checkout&type=checkout_post_purchase", []string{"3"}},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "/extensions/"+test.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected ok status for %q, got %d", test.query, rec.Code)
+			continue
+		}
+
+		response := extensionsResponse{}
+		if strings.Contains(test.query, "format=flat") {
+			err = json.Unmarshal(rec.Body.Bytes(), &response.Extensions)
+		} else {
+			err = json.Unmarshal(rec.Body.Bytes(), &response)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		uuids := make([]string, 0)
+		for _, extension := range response.Extensions {
+			uuids = append(uuids, extension.UUID)
+		}
+		if strings.Join(uuids, ",") != strings.Join(test.uuids, ",") || response.Extensions == nil {
+			t.Errorf("Expected extensions %v for %q, got %v", test.uuids, test.query, uuids)
+		}
+	}
+}
+
+func TestGetExtensionsGroupedByGroup(t *testing.T) {
+	groupsConfig := *config
+	groupsConfig.Extensions = []core.Extension{
+		{UUID: "1", Type: "checkout_ui_extension", Group: "upsell"},
+		{UUID: "2", Type: "product_subscription"},
+		{UUID: "3", Type: "checkout_post_purchase", Group: "upsell"},
+	}
+	api := New(&groupsConfig)
+
+	req, err := http.NewRequest("GET", "/extensions/?group_by=group&type=checkout_ui_extension&type=checkout_post_purchase&type=product_subscription", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	response := groupedExtensionsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{"upsell": {"1", "3"}, "default": {"2"}}
+	if len(response.Groups) != len(expected) {
+		t.Fatalf("Expected groups %v, got %v", expected, response.Groups)
+	}
+	for group, uuids := range expected {
+		actual := make([]string, 0)
+		for _, extension := range response.Groups[group] {
+			actual = append(actual, extension.UUID)
+		}
+		if strings.Join(actual, ",") != strings.Join(uuids, ",") {
+			t.Errorf("Expected extensions %v in group %q, got %v", uuids, group, actual)
+		}
+	}
+
+	req, err = http.NewRequest("GET", "/extensions/?group_by=type", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request for an unsupported group_by, got %d", rec.Code)
+	}
+}
+
+func TestGetExtensionsCompressed(t *testing.T) {
 	api := New(config)
-	server := httptest.NewServer(api)
 
-	firstConnection, err := createWebsocket(server)
+	for _, acceptEncoding := range []string{"", "gzip, deflate, br", "gzip;q=0"} {
+		req, err := http.NewRequest("GET", "/extensions/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if vary := rec.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Accept-Encoding" {
+			t.Errorf("Expected Vary: Accept-Encoding for %q, got %v", acceptEncoding, vary)
+		}
+
+		body := io.Reader(rec.Body)
+		if acceptEncoding == "gzip, deflate, br" {
+			if rec.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("Expected a gzipped response, got %q", rec.Header().Get("Content-Encoding"))
+			}
+			if body, err = gzip.NewReader(rec.Body); err != nil {
+				t.Fatal(err)
+			}
+		} else if rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected no compression for %q, got %q", acceptEncoding, rec.Header().Get("Content-Encoding"))
+		}
+
+		response := extensionsResponse{}
+		if err := json.NewDecoder(body).Decode(&response); err != nil {
+			t.Fatalf("Unable to decode the response for %q: %v", acceptEncoding, err)
+		}
+		if len(response.Extensions) != len(config.Extensions) {
+			t.Errorf("Expected %d extensions, got %d", len(config.Extensions), len(response.Extensions))
+		}
+	}
+}
+
+func TestGetExtensionsAssetLastModified(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootDir, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mainFile := filepath.Join(rootDir, "build", "main.js")
+	if err := os.WriteFile(mainFile, []byte("console.log('main');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2022, time.March, 4, 12, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(mainFile, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	lastModifiedConfig := *config
+	lastModifiedConfig.AssetLastModified = true
+	lastModifiedConfig.Extensions = []core.Extension{{
+		UUID: "123",
+		Type: "checkout_ui_extension",
+		Development: core.Development{
+			RootDir:  rootDir,
+			BuildDir: "build",
+			Entries:  map[string]string{"main": "src/index.js", "unbuilt": "src/unbuilt.js"},
+		},
+	}}
+
+	req, err := http.NewRequest("GET", "/extensions/", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	rec := httptest.NewRecorder()
+	New(&lastModifiedConfig).ServeHTTP(rec, req)
 
-	secondConnection, err := createWebsocket(server)
+	response := extensionsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	assets := response.Extensions[0].Assets
+	if len(assets) != 2 {
+		t.Fatalf("Expected 2 assets, got %v", assets)
+	}
+	for _, asset := range assets {
+		switch asset.Name {
+		case "main":
+			if asset.LastModified == nil || !asset.LastModified.Equal(modified) {
+				t.Errorf("Expected main to be last modified at %s, got %v", modified, asset.LastModified)
+			}
+		case "unbuilt":
+			if asset.LastModified != nil {
+				t.Errorf("Expected no modification time for an asset that wasn't built, got %s", asset.LastModified)
+			}
+		}
+	}
+
+	if !strings.Contains(rec.Body.String(), `"lastModified":"2022-03-04T12:30:00Z"`) {
+		t.Errorf("Expected lastModified to be serialized as RFC 3339, got %s", rec.Body.String())
+	}
+}
+
+func TestGetExtensionsYAML(t *testing.T) {
+	api := New(config)
+
+	for _, accept := range []string{"application/yaml", "text/yaml", "application/json;q=0.5, application/yaml"} {
+		req, err := http.NewRequest("GET", "/extensions/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if contentType := rec.Header().Get("Content-Type"); !strings.HasSuffix(contentType, "/yaml") {
+			t.Errorf("Expected a YAML content type for %q, got %s", accept, contentType)
+		}
+
+		response := struct {
+			Extensions []struct {
+				UUID   string `yaml:"uuid"`
+				Assets []struct {
+					Name string `yaml:"name"`
+					Url  string `yaml:"url"`
+				} `yaml:"assets"`
+			} `yaml:"extensions"`
+			Version string `yaml:"version"`
+		}{}
+		if err := yaml.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Unable to decode YAML for %q: %v\n%s", accept, err, rec.Body)
+		}
+
+		if len(response.Extensions) != 1 || response.Extensions[0].UUID != config.Extensions[0].UUID || len(response.Extensions[0].Assets) == 0 || response.Version == "" {
+			t.Errorf("Expected the manifest as YAML, got %s", rec.Body)
+		}
+	}
+
+	for _, accept := range []string{"", "*/*", "application/json", "text/html"} {
+		req, err := http.NewRequest("GET", "/extensions/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected JSON for %q, got %s", accept, contentType)
+		}
+	}
+}
+
+func TestGetExtensionsJSONEncoding(t *testing.T) {
+	escapeHTML := false
+	tests := []struct {
+		query      string
+		escapeHTML *bool
+		indented   bool
+		escaped    bool
+	}{
+		{"", nil, false, true},
+		{"?pretty=true", nil, true, true},
+		{"?pretty=false", &escapeHTML, false, false},
+	}
+
+	for _, test := range tests {
+		encodingConfig := *config
+		encodingConfig.EscapeHTML = test.escapeHTML
+		encodingConfig.Extensions = []core.Extension{{UUID: "123", Type: "checkout_ui_extension", Description: "Upsell <b>&</b> cross-sell"}}
+
+		req, err := http.NewRequest("GET", "/extensions/"+test.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		New(&encodingConfig).ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		if indented := strings.Contains(body, "\n  \"extensions\": ["); indented != test.indented {
+			t.Errorf("Expected indentation: %v for %q, got %s", test.indented, test.query, body)
+		}
+
+		if escaped := strings.Contains(body, `\u003cb\u003e\u0026`); escaped != test.escaped {
+			t.Errorf("Expected HTML escaping: %v with escape_html %v, got %s", test.escaped, test.escapeHTML, body)
+		}
+	}
+}
+
+func TestGetAppExtensions(t *testing.T) {
+	appsConfig := *config
+	appsConfig.Apps = []core.AppConfig{{
+		Name:  "shop-a",
+		Store: "shop-a.myshopify.com",
+		Extensions: []core.Extension{{
+			UUID:        "00000000-0000-0000-0000-00000000000a",
+			Type:        "checkout_ui_extension",
+			Development: core.Development{Entries: map[string]string{"main": "src/index.tsx"}},
+		}},
+	}}
+
+	req, err := http.NewRequest("GET", "/apps/shop-a/extensions/", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	rec := httptest.NewRecorder()
+
+	api := New(&appsConfig)
+	api.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected ok status – received: %d", rec.Code)
+	}
 
-	// First message received is the connected message which can be ignored
-	firstConnection.ReadJSON(&StatusUpdate{})
-	secondConnection.ReadJSON(&StatusUpdate{})
+	response := extensionsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
 
-	expectedUpdate := StatusUpdate{Type: "Some message", Extensions: config.Extensions}
-	api.Notify(expectedUpdate)
+	if response.Store != "shop-a.myshopify.com" {
+		t.Errorf("Expected the app's store, got %q", response.Store)
+	}
 
-	if err := verifyWebsocketMessage(firstConnection, expectedUpdate); err != nil {
-		t.Error(err)
+	if len(response.Extensions) != 1 || response.Extensions[0].UUID != "00000000-0000-0000-0000-00000000000a" {
+		t.Fatalf("Expected only the app's extension, got %+v", response.Extensions)
 	}
 
-	if err = verifyWebsocketMessage(secondConnection, expectedUpdate); err != nil {
-		t.Error(err)
+	expectedUrl := "http://localhost:8000/apps/shop-a/extensions/00000000-0000-0000-0000-00000000000a/assets/main.js"
+	if response.Extensions[0].Assets[0].Url != expectedUrl {
+		t.Errorf("Expected asset url %s, got %s", expectedUrl, response.Extensions[0].Assets[0].Url)
 	}
 }
 
-func TestWebsocketConnectionStartAndShutdown(t *testing.T) {
+func TestGetSingleExtension(t *testing.T) {
 	api := New(config)
-	server := httptest.NewServer(api)
-	ws, err := createWebsocket(server)
+
+	for _, accept := range []string{"", "*/*", "application/json"} {
+		req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected ok status – received: %d", rec.Code)
+		}
+
+		response := singleExtensionResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Expected JSON for Accept %q: %v", accept, err)
+		}
+
+		if response.Extension.UUID != "00000000-0000-0000-0000-000000000000" {
+			t.Errorf("Unexpected extension %s", response.Extension.UUID)
+		}
+	}
+}
+
+func TestGetSingleExtensionHtmlContentType(t *testing.T) {
+	defer func(original *template.Template) { indexTemplate = original }(indexTemplate)
+
+	// Pages that content sniffing would take for plain text
+	for _, page := range []string{"\n\n  Extension {{ .Extension.UUID }}", "{{ .Extension.UUID }} <b>preview</b>", "%PDF {{ .Nonce }}"} {
+		indexTemplate = template.Must(template.New("index.html.tpl").Parse(page))
+
+		req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+		New(config).ServeHTTP(rec, req)
+
+		if contentType := rec.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+			t.Errorf("Expected text/html; charset=utf-8 for %q, got %s", page, contentType)
+		}
+	}
+}
+
+func TestGetSingleExtensionHtml(t *testing.T) {
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	rec := httptest.NewRecorder()
 
-	if err := verifyWebsocketMessage(ws, StatusUpdate{Type: "connected", Extensions: api.Extensions}); err != nil {
-		t.Error(err)
+	api := New(config)
+	api.ServeHTTP(rec, req)
+
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected HTML response, got %s", rec.Header().Get("Content-Type"))
 	}
 
-	ws.SetCloseHandler(func(code int, text string) error {
-		ws.Close()
-		return nil
-	})
+	if !strings.Contains(rec.Body.String(), "00000000-0000-0000-0000-000000000000") {
+		t.Errorf("Expected the rendered page to mention the extension, got %s", rec.Body.String())
+	}
 
-	api.Shutdown()
+	policy := rec.Header().Get("Content-Security-Policy")
+	scriptSrc := strings.SplitN(policy, "; ", 2)[0]
+	nonce := strings.TrimSuffix(strings.TrimPrefix(scriptSrc, "script-src 'self' 'nonce-"), "'")
+	if nonce == "" || nonce == scriptSrc {
+		t.Fatalf("Expected a nonce in the Content-Security-Policy, got %q", policy)
+	}
 
-	if err := verifyConnectionShutdown(api, ws); err != nil {
-		t.Error(err)
+	if !strings.Contains(rec.Body.String(), fmt.Sprintf(`<script nonce="%s">`, nonce)) {
+		t.Errorf("Expected inline scripts to carry the nonce %s", nonce)
+	}
+}
+
+func TestGetSingleExtensionHtmlFramingHeaders(t *testing.T) {
+	tests := []struct {
+		extensionType  string
+		framing        map[string]core.FramingPolicy
+		frameAncestors string
+		xFrameOptions  string
+	}{
+		{"checkout_ui_extension", nil, "frame-ancestors 'self' https://*.myshopify.com https://checkout.shopify.com", ""},
+		{"product_subscription", nil, "frame-ancestors 'self' https://admin.shopify.com https://*.myshopify.com", ""},
+		{"unknown_extension", nil, "frame-ancestors 'self'", "SAMEORIGIN"},
+		{
+			"checkout_ui_extension",
+			map[string]core.FramingPolicy{"checkout": {FrameAncestors: []string{"'none'"}}},
+			"frame-ancestors 'none'",
+			"DENY",
+		},
+		{
+			"product_subscription",
+			map[string]core.FramingPolicy{"admin": {FrameAncestors: []string{"https://example.com"}, XFrameOptions: "SAMEORIGIN"}},
+			"frame-ancestors https://example.com",
+			"SAMEORIGIN",
+		},
+	}
+
+	for _, test := range tests {
+		framingConfig := *config
+		framingConfig.Framing = test.framing
+		framingConfig.Extensions = []core.Extension{{UUID: "123", Type: test.extensionType}}
+
+		req, err := http.NewRequest("GET", "/extensions/123", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+		New(&framingConfig).ServeHTTP(rec, req)
+
+		if policy := rec.Header().Get("Content-Security-Policy"); !strings.HasSuffix(policy, "; "+test.frameAncestors) {
+			t.Errorf("Expected %q for %s, got %q", test.frameAncestors, test.extensionType, policy)
+		}
+
+		if xFrameOptions := rec.Header().Get("X-Frame-Options"); xFrameOptions != test.xFrameOptions {
+			t.Errorf("Expected X-Frame-Options %q for %s, got %q", test.xFrameOptions, test.extensionType, xFrameOptions)
+		}
+	}
+}
+
+func TestGetSingleExtensionHtmlSurfaceOverride(t *testing.T) {
+	surfaceConfig := *config
+	surfaceConfig.Extensions = []core.Extension{{UUID: "123", Type: "checkout_ui_extension"}}
+	api := New(&surfaceConfig)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/extensions/123"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	if !strings.Contains(rec.Body.String(), `data-surface="checkout"`) {
+		t.Errorf("Expected the surface to be derived from the type, got %s", rec.Body.String())
+	}
+
+	rec = get("?surface=Admin")
+	if !strings.Contains(rec.Body.String(), `data-surface="admin"`) {
+		t.Errorf("Expected the surface to be overridden, got %s", rec.Body.String())
+	}
+	if policy := rec.Header().Get("Content-Security-Policy"); !strings.HasSuffix(policy, "; frame-ancestors 'self' https://*.myshopify.com https://checkout.shopify.com") {
+		t.Errorf("Expected the framing policy to stay the one of checkout, got %q", policy)
+	}
+
+	rec = get("?surface=storefront")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown surfaces to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestGetExtensionIcon(t *testing.T) {
+	rootDir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if err := os.WriteFile(filepath.Join(rootDir, "icon"), png, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	iconConfig := *config
+	iconConfig.Extensions = []core.Extension{
+		{UUID: "123", Type: "checkout_ui_extension", Development: core.Development{RootDir: rootDir, Icon: "icon"}},
+		{UUID: "456", Type: "checkout_ui_extension"},
+	}
+	api := New(&iconConfig)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/extensions/123/icon")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("Expected the icon to be served as image/png, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	if rec := get("/extensions/456/icon"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an extension without icon, got %d", rec.Code)
+	}
+
+	response := singleExtensionResponse{}
+	if err := json.Unmarshal(get("/extensions/123").Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Extension.Icon == nil || response.Extension.Icon.Url != "http://localhost:8000/extensions/123/icon" {
+		t.Errorf("Expected the icon URL in the manifest, got %+v", response.Extension.Icon)
+	}
+
+	if body := get("/extensions/456").Body.String(); strings.Contains(body, `"icon"`) {
+		t.Errorf("Expected the manifest to omit the icon, got %s", body)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	statusConfig := *config
+	statusConfig.ServerVersion = "v1.2.3"
+	statusConfig.ServerCommit = "abc123"
+
+	req, err := http.NewRequest("GET", "/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	New(&statusConfig).ServeHTTP(rec, req)
+
+	response := statusResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Version != "v1.2.3" || response.Commit != "abc123" || response.ApiVersion == "" {
+		t.Errorf("Expected the versions of the server, got %+v", response)
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, response.StartedAt)
+	if err != nil || startedAt.After(time.Now()) {
+		t.Errorf("Expected the start time of the server, got %q", response.StartedAt)
+	}
+
+	if response.UptimeSeconds < 0 || response.Uptime == "" {
+		t.Errorf("Expected the uptime of the server, got %+v", response)
+	}
+}
+
+func TestUpstreamProxy(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			connection, err := upgrader.Upgrade(rw, r, nil)
+			if err != nil {
+				return
+			}
+			defer connection.Close()
+			connection.WriteMessage(websocket.TextMessage, []byte("hmr "+r.URL.Path))
+			return
+		}
+		fmt.Fprintf(rw, "upstream %s", r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	proxyConfig := *config
+	proxyConfig.UpstreamProxy = upstream.URL
+	server := httptest.NewServer(New(&proxyConfig))
+	defer server.Close()
+
+	for path, expected := range map[string]string{"/": "upstream /", "/@vite/client": "upstream /@vite/client"} {
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if string(body) != expected {
+			t.Errorf("Expected %s to be proxied, got %d %q", path, response.StatusCode, body)
+		}
+	}
+
+	response, err := http.Get(server.URL + "/extensions/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected extension routes to be served locally, got %s", response.Header.Get("Content-Type"))
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/hmr", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if _, message, err := ws.ReadMessage(); err != nil || string(message) != "hmr /hmr" {
+		t.Errorf("Expected websocket upgrades to be proxied, got %q, %v", message, err)
+	}
+}
+
+type fakeRequestTracer struct {
+	parents  []*core.TraceParent
+	statuses []int
+}
+
+func (tracer *fakeRequestTracer) StartRequest(r *http.Request, parent *core.TraceParent) RequestSpan {
+	tracer.parents = append(tracer.parents, parent)
+	return tracer
+}
+
+func (tracer *fakeRequestTracer) TraceParent() *core.TraceParent {
+	return nil
+}
+
+func (tracer *fakeRequestTracer) End(status int) {
+	tracer.statuses = append(tracer.statuses, status)
+}
+
+func TestTraceContext(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "%s;%s", r.Header.Get("traceparent"), r.Header.Get("tracestate"))
+	}))
+	defer upstream.Close()
+
+	proxyConfig := *config
+	proxyConfig.UpstreamProxy = upstream.URL
+	tracer := &fakeRequestTracer{}
+	server := httptest.NewServer(NewTraceContext(New(&proxyConfig), tracer))
+	defer server.Close()
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	for _, test := range []struct {
+		traceParent string
+		expected    string
+	}{
+		{traceParent, traceParent + ";vendor=value"},
+		{"00-invalid-00f067aa0ba902b7-01", ";"},
+	} {
+		req, err := http.NewRequest("GET", server.URL+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("traceparent", test.traceParent)
+		req.Header.Set("tracestate", "vendor=value")
+
+		response, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if string(body) != test.expected {
+			t.Errorf("Expected the upstream to receive %q, got %q", test.expected, body)
+		}
+	}
+
+	if len(tracer.parents) != 2 || tracer.parents[0] == nil || tracer.parents[0].String() != traceParent || tracer.parents[1] != nil {
+		t.Errorf("Expected the valid trace context to be the parent of the request span, got %v", tracer.parents)
+	}
+	if len(tracer.statuses) != 2 || tracer.statuses[0] != http.StatusOK {
+		t.Errorf("Expected the spans to end with the status of the responses, got %v", tracer.statuses)
+	}
+}
+
+func TestCheckTemplates(t *testing.T) {
+	if err := New(config).CheckTemplates(); err != nil {
+		t.Errorf("Expected templates to render, got %v", err)
+	}
+}
+
+func TestGetUnknownExtension(t *testing.T) {
+	req, err := http.NewRequest("GET", "/extensions/unknown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+
+	api := New(config)
+	api.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected not found status – received: %d", rec.Code)
+	}
+}
+
+func TestGetUnknownExtensionNotFoundRedirect(t *testing.T) {
+	redirectConfig := *config
+	redirectConfig.NotFoundRedirect = "https://example.com/extensions"
+
+	tests := []struct {
+		config   *core.Config
+		accept   string
+		expected int
+	}{
+		{config, "text/html", http.StatusNotFound},
+		{&redirectConfig, "text/html", http.StatusTemporaryRedirect},
+		{&redirectConfig, "application/json", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "/extensions/unknown", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", test.accept)
+		rec := httptest.NewRecorder()
+		New(test.config).ServeHTTP(rec, req)
+
+		if rec.Code != test.expected {
+			t.Errorf("Expected %d for %s with not_found_redirect %q, got %d", test.expected, test.accept, test.config.NotFoundRedirect, rec.Code)
+		}
+		if test.expected == http.StatusTemporaryRedirect && rec.Header().Get("Location") != redirectConfig.NotFoundRedirect {
+			t.Errorf("Expected a redirect to %s, got %s", redirectConfig.NotFoundRedirect, rec.Header().Get("Location"))
+		}
+	}
+}
+
+func TestGetExtensionWithPreviewToken(t *testing.T) {
+	tokenConfig := *config
+	tokenConfig.Extensions = append([]core.Extension{}, config.Extensions...)
+	tokenConfig.Extensions[0].Development.PreviewToken = "secret"
+
+	api := New(&tokenConfig)
+
+	tests := []struct {
+		url    string
+		header string
+		status int
+	}{
+		{"/extensions/00000000-0000-0000-0000-000000000000", "", http.StatusForbidden},
+		{"/extensions/00000000-0000-0000-0000-000000000000?token=wrong", "", http.StatusForbidden},
+		{"/extensions/00000000-0000-0000-0000-000000000000?token=secret", "", http.StatusOK},
+		{"/extensions/00000000-0000-0000-0000-000000000000", "secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.header != "" {
+			req.Header.Set("X-Preview-Token", test.header)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("Expected status %d for %s, received: %d", test.status, test.url, rec.Code)
+		}
+	}
+}
+
+func TestPreviewTokenHidesExtension(t *testing.T) {
+	tokenConfig := *config
+	tokenConfig.Extensions = append([]core.Extension{}, config.Extensions...)
+	tokenConfig.Extensions[0].Development.PreviewToken = "secret"
+	protected := tokenConfig.Extensions[0].UUID
+
+	api := New(&tokenConfig)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, url := range []string{"/extensions/", "/manifest/full", "/metrics", "/extensions/poll"} {
+		if body := get(url).Body.String(); strings.Contains(body, protected) {
+			t.Errorf("Expected %s to hide the protected extension, got %s", url, body)
+		}
+	}
+
+	var response extensionsResponse
+	if err := json.Unmarshal(get("/extensions/?token=secret").Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Extensions) != len(tokenConfig.Extensions) {
+		t.Fatalf("Expected the protected extension to be listed with its token, got %v", response.Extensions)
+	}
+	for _, extension := range response.Extensions {
+		if extension.UUID != protected {
+			continue
+		}
+		if !strings.HasSuffix(extension.Assets[0].Url, "?token=secret") {
+			t.Errorf("Expected the asset URLs to carry the token, got %s", extension.Assets[0].Url)
+		}
+	}
+
+	for _, path := range []string{"/assets/main.js", "/assets.zip"} {
+		if rec := get("/extensions/" + protected + path); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected %s to require the token, got %d", path, rec.Code)
+		}
+		if rec := get("/extensions/" + protected + path + "?token=secret"); rec.Code == http.StatusForbidden {
+			t.Errorf("Expected %s to be served with the token, got %d", path, rec.Code)
+		}
+	}
+
+	update, visible := visibleUpdate(httptest.NewRequest("GET", "/extensions/", nil), StatusUpdate{Type: "success", Extensions: tokenConfig.Extensions[:1]})
+	if visible {
+		t.Errorf("Expected updates of protected extensions to be withheld, got %v", update)
+	}
+}
+
+func TestExtensionDebugEndpoint(t *testing.T) {
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/debug", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	New(config).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected debug endpoint to be disabled by default, received: %d", rec.Code)
+	}
+
+	debugConfig := *config
+	debugConfig.DebugEndpoints = true
+	debugConfig.Extensions = append([]core.Extension{}, config.Extensions...)
+	debugConfig.Extensions[0].Development.PreviewToken = "secret"
+	req.Header.Set("X-Preview-Token", "secret")
+
+	rec = httptest.NewRecorder()
+	New(&debugConfig).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected ok status – received: %d", rec.Code)
+	}
+
+	response := extensionDebugResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	development, ok := response.Config["development"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected the development config, got %+v", response.Config)
+	}
+
+	if development["root_dir"] != "testdata" || development["build_dir"] != "build" {
+		t.Errorf("Expected hidden development fields, got %+v", development)
+	}
+
+	if development["preview_token"] != "" || !response.HasPreviewToken {
+		t.Errorf("Expected the preview token to be blanked out, got %v", development["preview_token"])
+	}
+
+	for _, key := range yamlKeys(reflect.TypeOf(core.Extension{})) {
+		if _, found := response.Config[key]; !found {
+			t.Errorf("Expected the config to include %s", key)
+		}
+	}
+
+	for _, key := range yamlKeys(reflect.TypeOf(core.Development{})) {
+		if _, found := development[key]; !found {
+			t.Errorf("Expected the development config to include %s", key)
+		}
+	}
+
+	if !filepath.IsAbs(response.ResolvedBuildDir) {
+		t.Errorf("Expected an absolute build directory, got %s", response.ResolvedBuildDir)
+	}
+}
+
+// yamlKeys lists the keys the fields of a config struct are read from
+func yamlKeys(configType reflect.Type) []string {
+	keys := []string{}
+	for index := 0; index < configType.NumField(); index++ {
+		field := configType.Field(index)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestAccessLogCLF(t *testing.T) {
+	var output strings.Builder
+	handler, err := NewAccessLog(New(config), "clf", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/extensions/?format=flat", "/extensions/unknown"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "127.0.0.1:51234"
+		req.Proto = "HTTP/1.1"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line per request, got %q", output.String())
+	}
+
+	clf := regexp.MustCompile(`^127\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET (\S+) HTTP/1\.1" (\d{3}) (\d+|-)$`)
+	expected := [][]string{{"/extensions/?format=flat", "200"}, {"/extensions/unknown", "404"}}
+	for index, line := range lines {
+		match := clf.FindStringSubmatch(line)
+		if match == nil {
+			t.Errorf("Expected a Common Log Format line, got %q", line)
+			continue
+		}
+		if match[1] != expected[index][0] || match[2] != expected[index][1] || match[3] == "-" {
+			t.Errorf("Expected %v with a size, got %q", expected[index], line)
+		}
+	}
+
+	if _, err := NewAccessLog(New(config), "json", &output); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	tests := []struct {
+		origins []string
+		maxAge  time.Duration
+		allowed bool
+		header  string
+	}{
+		{nil, 0, false, ""},
+		{[]string{"https://admin.shopify.com"}, 0, true, "600"},
+		{[]string{"*"}, time.Hour, true, "3600"},
+		{[]string{"https://example.com"}, 0, false, ""},
+		{[]string{"*"}, -1, true, ""},
+	}
+
+	for _, test := range tests {
+		corsConfig := *config
+		corsConfig.Cors = core.CorsConfig{AllowedOrigins: test.origins, MaxAge: test.maxAge}
+
+		req, err := http.NewRequest("OPTIONS", "/shutdown", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", "https://admin.shopify.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rec := httptest.NewRecorder()
+		New(&corsConfig).ServeHTTP(rec, req)
+
+		allowed := rec.Header().Get("Access-Control-Allow-Origin") == "https://admin.shopify.com"
+		if allowed != test.allowed {
+			t.Errorf("Expected origin to be allowed: %v with %v, got headers %v", test.allowed, test.origins, rec.Header())
+		}
+
+		if maxAge := rec.Header().Get("Access-Control-Max-Age"); maxAge != test.header {
+			t.Errorf("Expected max age %q with %s, got %q", test.header, test.maxAge, maxAge)
+		}
+
+		if test.allowed && rec.Code != http.StatusNoContent {
+			t.Errorf("Expected preflight to succeed, got %d", rec.Code)
+		}
+	}
+}
+
+func TestStrictSlash(t *testing.T) {
+	strictConfig := *config
+	strictConfig.StrictSlash = true
+
+	api := New(&strictConfig)
+	api.HandleCommand("/command", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+	})
+
+	req, err := http.NewRequest("GET", "/extensions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/extensions/" {
+		t.Errorf("Expected a redirect to /extensions/, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	req, err = http.NewRequest("POST", "/command/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusMovedPermanently {
+		t.Error("Expected commands not to be redirected")
+	}
+
+	req, err = http.NewRequest("POST", "/command", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected command to be handled, got %d", rec.Code)
+	}
+}
+
+func TestMaxRequestBodySize(t *testing.T) {
+	limitConfig := *config
+	limitConfig.MaxRequestBodySize = 8
+
+	api := New(&limitConfig)
+	api.HandleCommand("/command", func(rw http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(rw, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		body   io.Reader
+		status int
+	}{
+		{"POST", "/command", strings.NewReader("small"), http.StatusAccepted},
+		{"POST", "/command", strings.NewReader("too large for the limit"), http.StatusRequestEntityTooLarge},
+		// Without a length, the limit applies while the handler reads the body
+		{"POST", "/command", io.MultiReader(strings.NewReader("too large for the limit")), http.StatusRequestEntityTooLarge},
+		{"GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", strings.NewReader("too large for the limit"), http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.path, test.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("Expected status %d for %s %s, got %d", test.status, test.method, test.path, rec.Code)
+		}
+	}
+}
+
+func TestServeAssets(t *testing.T) {
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+
+	api := New(config)
+	api.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "console.log(\"Hello World!\");\n" {
+		t.Error("Unexpected body")
+		t.Log(rec.Body)
+	}
+}
+
+func TestServeAssetsBelowAssetRoot(t *testing.T) {
+	assetRootConfig := *config
+	assetRootConfig.AssetRoot = "/cdn"
+	// Keeps the asset URLs of the shared config
+	assetRootConfig.Extensions = append([]core.Extension{}, config.Extensions...)
+	api := New(&assetRootConfig)
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/cdn/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", http.StatusOK},
+		{"/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != test.expected {
+			t.Errorf("Expected %d for %s, got %d", test.expected, test.path, rec.Code)
+		}
+	}
+}
+
+func TestServeAssetsWithAssetHeaders(t *testing.T) {
+	headersConfig := *config
+	headersConfig.AssetHeaders = map[string]string{
+		"Cross-Origin-Resource-Policy": "same-site",
+		"Cache-Control":                "no-cache",
+	}
+	headersConfig.Extensions = append([]core.Extension{}, config.Extensions...)
+	headersConfig.Extensions[0].Development.AssetHeaders = map[string]string{
+		"cross-origin-resource-policy": "cross-origin",
+		"Content-Type":                 "text/plain",
+	}
+
+	openDir := func(dir string) fs.FS {
+		return fstest.MapFS{"main.js": {Data: []byte("console.log('headers');")}}
+	}
+	api := configureExtensionsApi(&headersConfig, mux.NewRouter(), openDir)
+
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	expected := map[string]string{
+		"Cross-Origin-Resource-Policy": "cross-origin",
+		"Cache-Control":                "no-cache",
+		"Content-Type":                 "text/plain",
+	}
+	for name, value := range expected {
+		if actual := rec.Header().Get(name); actual != value {
+			t.Errorf("Expected %s: %s, got %q", name, value, actual)
+		}
+	}
+}
+
+func TestDownloadAssetsArchive(t *testing.T) {
+	openDir := func(dir string) fs.FS {
+		return fstest.MapFS{
+			"main.js":              {Data: []byte("console.log('archived');")},
+			"nested/style.css":     {Data: []byte("body {}")},
+			".shopify-build-cache": {Data: []byte("hash")},
+		}
+	}
+	api := configureExtensionsApi(config, mux.NewRouter(), openDir)
+
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets.zip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if disposition := rec.Header().Get("Content-Disposition"); disposition != `attachment; filename="00000000-0000-0000-0000-000000000000-assets.zip"` {
+		t.Errorf("Expected the archive to be downloaded as an attachment, got %q", disposition)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("Expected a zip archive, got %d %q: %v", rec.Code, rec.Body.String(), err)
+	}
+
+	contents := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[file.Name] = string(content)
+	}
+
+	expected := map[string]string{"main.js": "console.log('archived');", "nested/style.css": "body {}"}
+	if !reflect.DeepEqual(contents, expected) {
+		t.Errorf("Expected the archive to hold %v, got %v", expected, contents)
+	}
+}
+
+func TestServeAssetsFromMemory(t *testing.T) {
+	buildDirs := make([]string, 0)
+	openDir := func(dir string) fs.FS {
+		buildDirs = append(buildDirs, dir)
+		return fstest.MapFS{
+			"main.js":          {Data: []byte("console.log('in memory');")},
+			"module.wasm":      {Data: []byte("\x00asm")},
+			"module.wasm.gz":   {Data: []byte("gzipped")},
+			"nested/style.css": {Data: []byte("body {}")},
+		}
+	}
+	api := configureExtensionsApi(config, mux.NewRouter(), openDir)
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		body           string
+	}{
+		{"main.js", "", "console.log('in memory');"},
+		{"nested/style.css", "", "body {}"},
+		{"module.wasm", "gzip", "gzipped"},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/"+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Body.String() != test.body {
+			t.Errorf("Expected %q for %s, got %d %q", test.body, test.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	if len(buildDirs) == 0 || buildDirs[0] != filepath.Join("testdata", "build") {
+		t.Errorf("Expected the build directory to be opened, got %v", buildDirs)
+	}
+}
+
+func TestFullManifest(t *testing.T) {
+	openDir := func(dir string) fs.FS {
+		return fstest.MapFS{
+			"main.js":              {Data: []byte("console.log('built');")},
+			"chunks/1.js":          {Data: []byte("console.log('chunk');")},
+			".shopify-build-cache": {Data: []byte("hash")},
+		}
+	}
+	api := configureExtensionsApi(config, mux.NewRouter(), openDir)
+
+	req, err := http.NewRequest("GET", "/manifest/full", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	response := fullManifestResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Expected a JSON manifest, got %q: %v", rec.Body.String(), err)
+	}
+	if len(response.Extensions) != len(api.Extensions) {
+		t.Fatalf("Expected %d extensions, got %d", len(api.Extensions), len(response.Extensions))
+	}
+
+	if !strings.Contains(rec.Body.String(), `"path":`) {
+		t.Errorf("Expected every asset to have a path, got %s", rec.Body.String())
+	}
+
+	hashed, chunk := false, false
+	for _, asset := range response.Extensions[0].Assets {
+		switch {
+		case asset.Path == "chunks/1.js":
+			chunk = true
+			if asset.Hash == nil || !strings.HasSuffix(asset.Url, "/assets/chunks/1.js") {
+				t.Errorf("Expected the chunk to be hashed and linked, got %+v", asset)
+			}
+		case strings.HasPrefix(asset.Path, "."):
+			t.Errorf("Expected hidden files to be left out, got %s", asset.Path)
+		case asset.Name == "main":
+			hashed = true
+			expected := "fe48d4c49998cab02dd55264a541fe1c2817d0dae87dd9e0c356b82e4d510aa1"
+			if asset.Hash == nil || asset.Path != "main.js" {
+				t.Errorf("Expected main.js to be hashed, got %v", asset)
+			} else if *asset.Hash != expected {
+				t.Errorf("Expected the hash of main.js, got %s", *asset.Hash)
+			}
+		case asset.Hash != nil:
+			t.Errorf("Expected no hash for the missing asset %s, got %s", asset.Name, *asset.Hash)
+		}
+	}
+	if !hashed {
+		t.Error("Expected the main asset in the manifest")
+	}
+	if !chunk {
+		t.Error("Expected the files of the build directory besides the entries in the manifest")
+	}
+}
+
+func TestMaxConcurrentAssetReads(t *testing.T) {
+	limitConfig := *config
+	limitConfig.MaxConcurrentAssetReads = 1
+	api := New(&limitConfig)
+
+	// Another request is being served
+	api.assetReads <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected the request to wait for a free slot, got %s", rec.Body)
+	}
+
+	served := make(chan string)
+	go func() {
+		req, _ := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		served <- rec.Body.String()
+	}()
+
+	<-api.assetReads
+
+	select {
+	case body := <-served:
+		if body != "console.log(\"Hello World!\");\n" {
+			t.Errorf("Unexpected body %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the queued request to be served once a slot is free")
+	}
+}
+
+func TestMetricsReportBytesServed(t *testing.T) {
+	api := New(config)
+
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, err = http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	metrics := metricsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics.Extensions) != 1 {
+		t.Fatalf("Expected metrics for one extension got %d", len(metrics.Extensions))
+	}
+
+	expectedBytes := uint64(len("console.log(\"Hello World!\");\n"))
+	if metrics.Extensions[0].BytesServed != expectedBytes {
+		t.Errorf("Expected %d bytes served, got %d", expectedBytes, metrics.Extensions[0].BytesServed)
+	}
+}
+
+func TestServeAssetsOnly(t *testing.T) {
+	assetsConfig := *config
+	assetsConfig.AssetsOnly = true
+	api := New(&assetsConfig)
+
+	for _, test := range []struct {
+		url    string
+		status int
+	}{
+		{"/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", http.StatusOK},
+		{"/", http.StatusNotFound},
+		{"/extensions/", http.StatusNotFound},
+		{"/extensions/00000000-0000-0000-0000-000000000000", http.StatusNotFound},
+		{"/metrics", http.StatusNotFound},
+	} {
+		req, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("Expected status %d for %s, received: %d", test.status, test.url, rec.Code)
+		}
+	}
+}
+
+func TestServeAssetsWithMimeTypeOverride(t *testing.T) {
+	extension := config.Extensions[0]
+	extension.Development.MimeTypes = map[string]string{"js": "text/plain"}
+	mimeConfig := *config
+	mimeConfig.Extensions = []core.Extension{extension}
+
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	New(&mimeConfig).ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected overridden content type, got %s", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestServeSourceMaps(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootDir, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "build", "main.js.map"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+
+	for _, serveSourceMaps := range []bool{true, false} {
+		sourceMapConfig := *config
+		sourceMapConfig.Extensions = []core.Extension{extension}
+		sourceMapConfig.ServeSourceMaps = &serveSourceMaps
+
+		req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js.map", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		New(&sourceMapConfig).ServeHTTP(rec, req)
+
+		expectedStatus := http.StatusOK
+		if !serveSourceMaps {
+			expectedStatus = http.StatusNotFound
+		}
+
+		if rec.Code != expectedStatus {
+			t.Errorf("serve_source_maps %v: expected status %d, got %d", serveSourceMaps, expectedStatus, rec.Code)
+		}
+	}
+}
+
+func TestServeWasm(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootDir, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "build", "module.wasm"), []byte("\x00asm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "build", "module.wasm.br"), []byte("brotli"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+
+	wasmConfig := *config
+	wasmConfig.Extensions = []core.Extension{extension}
+	api := New(&wasmConfig)
+
+	for _, test := range []struct {
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"", "", "\x00asm"},
+		{"gzip", "", "\x00asm"},
+		{"gzip, br", "br", "brotli"},
+		{"br;q=0", "", "\x00asm"},
+	} {
+		req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/module.wasm", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Header().Get("Content-Type") != "application/wasm" {
+			t.Errorf("Expected application/wasm content type, got %s", rec.Header().Get("Content-Type"))
+		}
+
+		if rec.Header().Get("Content-Encoding") != test.encoding {
+			t.Errorf("Expected encoding %q for Accept-Encoding %q, got %q", test.encoding, test.acceptEncoding, rec.Header().Get("Content-Encoding"))
+		}
+
+		if rec.Body.String() != test.body {
+			t.Errorf("Expected body %q for Accept-Encoding %q, got %q", test.body, test.acceptEncoding, rec.Body.String())
+		}
+	}
+}
+
+func TestListAssets(t *testing.T) {
+	listingConfig := *config
+	listingConfig.ListAssets = true
+
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	New(&listingConfig).ServeHTTP(rec, req)
+
+	listing := assetListResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listing.Assets) != 1 || listing.Assets[0].Name != "main.js" {
+		t.Fatalf("Expected main.js to be listed, got %+v", listing.Assets)
+	}
+
+	if listing.Assets[0].Size != int64(len("console.log(\"Hello World!\");\n")) {
+		t.Errorf("Unexpected size %d", listing.Assets[0].Size)
+	}
+}
+
+func TestListAssetsDisabledByDefault(t *testing.T) {
+	req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	New(config).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected not found status – received: %d", rec.Code)
+	}
+}
+
+func TestWebsocketNotify(t *testing.T) {
+	api := New(config)
+	server := httptest.NewServer(api)
+
+	firstConnection, err := createWebsocket(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secondConnection, err := createWebsocket(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First message received is the connected message which can be ignored
+	firstConnection.ReadJSON(&StatusUpdate{})
+	secondConnection.ReadJSON(&StatusUpdate{})
+
+	expectedUpdate := StatusUpdate{Type: "Some message", Extensions: config.Extensions}
+	api.Notify(expectedUpdate)
+
+	if err := verifyWebsocketMessage(firstConnection, expectedUpdate); err != nil {
+		t.Error(err)
+	}
+
+	if err = verifyWebsocketMessage(secondConnection, expectedUpdate); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNotifyDebounce(t *testing.T) {
+	sent := make(chan StatusUpdate, 10)
+	send := func(statusUpdate StatusUpdate) { sent <- statusUpdate }
+
+	d := debouncer{}
+	d.debounce("1", 50*time.Millisecond, StatusUpdate{Type: "success", Duration: 1}, send)
+	d.debounce("1", 50*time.Millisecond, StatusUpdate{Type: "error", Duration: 2}, send)
+	d.debounce("2", 50*time.Millisecond, StatusUpdate{Type: "success", Duration: 3}, send)
+	d.debounce("1", 50*time.Millisecond, StatusUpdate{Type: "success", Duration: 4}, send)
+
+	durations := make(map[int64]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case statusUpdate := <-sent:
+			durations[statusUpdate.Duration] = true
+		case <-time.After(time.Second):
+			t.Fatal("Expected the debounced updates to be sent")
+		}
+	}
+
+	if !durations[4] || !durations[3] {
+		t.Errorf("Expected only the latest update of each extension, got %v", durations)
+	}
+
+	select {
+	case statusUpdate := <-sent:
+		t.Errorf("Expected coalesced updates not to be sent, got %+v", statusUpdate)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotifyDoesNotDebounceProgress(t *testing.T) {
+	debounceConfig := *config
+	debounceConfig.NotifyDebounce = 50 * time.Millisecond
+	api := New(&debounceConfig)
+
+	progress := 10
+	api.Notify(StatusUpdate{Type: "success", Extensions: config.Extensions[:1]})
+	api.Notify(StatusUpdate{Type: "build_progress", Extensions: config.Extensions[:1], Progress: &progress})
+	time.Sleep(150 * time.Millisecond)
+
+	updates, _, _, _ := api.pollLog.since(0)
+	types := make([]string, 0, len(updates))
+	for _, update := range updates {
+		types = append(types, update.Type)
+	}
+	if !reflect.DeepEqual(types, []string{"build_progress", "success"}) {
+		t.Errorf("Expected the success to be sent after the progress update, got %v", types)
+	}
+}
+
+func TestLongPoll(t *testing.T) {
+	defer func(original time.Duration) { longPollTimeout = original }(longPollTimeout)
+	longPollTimeout = 50 * time.Millisecond
+
+	api := New(config)
+	poll := func(query string) (int, pollResponse) {
+		req, err := http.NewRequest("GET", "/extensions/poll"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		response := pollResponse{}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, response
+	}
+
+	if _, response := poll(""); response.Cursor != "0" || len(response.Updates) != 0 || response.SessionId == "" {
+		t.Fatalf("Expected the initial cursor without updates, got %+v", response)
+	}
+
+	polled := make(chan pollResponse)
+	go func() {
+		_, response := poll("?since=0")
+		polled <- response
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	api.Notify(StatusUpdate{Type: "Some message"})
+
+	response := <-polled
+	if response.Cursor != "1" || len(response.Updates) != 1 || response.Updates[0].Type != "Some message" {
+		t.Errorf("Expected the update to end the poll, got %+v", response)
+	}
+
+	// Updates sent between polls aren't missed
+	api.Notify(StatusUpdate{Type: "Another message"})
+	if _, response := poll("?since=1"); response.Cursor != "2" || len(response.Updates) != 1 || response.Updates[0].Type != "Another message" {
+		t.Errorf("Expected the update sent since the last poll, got %+v", response)
+	}
+
+	if _, response := poll("?since=2"); response.Cursor != "2" || len(response.Updates) != 0 {
+		t.Errorf("Expected an empty response once the poll timed out, got %+v", response)
+	}
+
+	if code, _ := poll("?since=abc"); code != http.StatusBadRequest {
+		t.Errorf("Expected bad request for an invalid cursor, got %d", code)
+	}
+
+	// Cursors that fell out of the buffer are told to reload
+	for index := 0; index < pollBufferSize+1; index++ {
+		api.pollLog.add(StatusUpdate{Type: "success"})
+	}
+	if _, response := poll("?since=2"); !response.Reset || len(response.Updates) != pollBufferSize {
+		t.Errorf("Expected a reset along with the buffered updates, got %d updates, reset %v", len(response.Updates), response.Reset)
+	}
+	if _, response := poll("?since=3"); response.Reset || len(response.Updates) != pollBufferSize {
+		t.Errorf("Expected the cursor before the oldest buffered update not to be reset, got %d updates, reset %v", len(response.Updates), response.Reset)
+	}
+}
+
+func TestPollWait(t *testing.T) {
+	for _, test := range []struct {
+		readTimeout time.Duration
+		expected    time.Duration
+	}{
+		{0, longPollTimeout},
+		{-1, longPollTimeout},
+		{time.Minute, longPollTimeout},
+		{20 * time.Second, 15 * time.Second},
+		{4 * time.Second, 2 * time.Second},
+	} {
+		if wait := pollWait(test.readTimeout); wait != test.expected {
+			t.Errorf("Expected a read_timeout of %s to wait %s, got %s", test.readTimeout, test.expected, wait)
+		}
+	}
+}
+
+func TestWebsocketConnectionStartAndShutdown(t *testing.T) {
+	api := New(config)
+	server := httptest.NewServer(api)
+	ws, err := createWebsocket(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyWebsocketMessage(ws, StatusUpdate{Type: "connected", Extensions: api.Extensions}); err != nil {
+		t.Error(err)
+	}
+
+	ws.SetCloseHandler(func(code int, text string) error {
+		ws.Close()
+		return nil
+	})
+
+	api.Shutdown()
+
+	if err := verifyConnectionShutdown(api, ws); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWebsocketShutdownTimeout(t *testing.T) {
+	shutdownConfig := *config
+	shutdownConfig.ShutdownTimeout = 100 * time.Millisecond
+
+	api := New(&shutdownConfig)
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	connections := make([]*websocket.Conn, 0)
+	for i := 0; i < 3; i++ {
+		ws, err := createWebsocket(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyWebsocketMessage(ws, StatusUpdate{Type: "connected", Extensions: api.Extensions}); err != nil {
+			t.Fatal(err)
+		}
+		connections = append(connections, ws)
+	}
+
+	start := time.Now()
+	api.Shutdown()
+
+	// Clients are closed concurrently, each within the grace period
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected shutdown to respect the shutdown timeout, took %s", elapsed)
+	}
+
+	for _, ws := range connections {
+		if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("Expected the connection to be closed, got %v", err)
+		}
+	}
+}
+
+func TestWebsocketConnectedMessageIdentifiesSession(t *testing.T) {
+	api := New(config)
+	server := httptest.NewServer(api)
+
+	connectedMessages := make([]StatusUpdate, 2)
+	for i := range connectedMessages {
+		ws, err := createWebsocket(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+		ws.ReadJSON(&connectedMessages[i])
+	}
+
+	if connectedMessages[0].SessionId == "" || connectedMessages[0].SessionId != connectedMessages[1].SessionId {
+		t.Errorf("Expected connections to the same server to share a session id, got %q and %q", connectedMessages[0].SessionId, connectedMessages[1].SessionId)
+	}
+
+	if connectedMessages[0].ReconnectBackoff <= 0 {
+		t.Errorf("Expected a reconnect backoff, got %d", connectedMessages[0].ReconnectBackoff)
+	}
+
+	if New(config).sessionId == api.sessionId {
+		t.Error("Expected a new server to use a new session id")
+	}
+}
+
+func TestWebsocketSubprotocols(t *testing.T) {
+	api := New(config)
+	server := httptest.NewServer(api)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/extensions/"
+
+	for _, test := range []struct {
+		requested []string
+		expected  string
+		versioned bool
+	}{
+		{nil, "", true},
+		{[]string{"shopify-extensions-v1"}, "shopify-extensions-v1", false},
+		{[]string{"shopify-extensions-v1", "shopify-extensions-v2"}, "shopify-extensions-v2", true},
+	} {
+		dialer := websocket.Dialer{Subprotocols: test.requested}
+		ws, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+
+		if ws.Subprotocol() != test.expected {
+			t.Errorf("Expected protocol %q for %v, got %q", test.expected, test.requested, ws.Subprotocol())
+		}
+
+		message := make(map[string]interface{})
+		if err := ws.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, found := message["sessionId"]; found != test.versioned {
+			t.Errorf("Expected sessionId to be sent: %v for %v, got %v", test.versioned, test.requested, message)
+		}
+	}
+
+	dialer := websocket.Dialer{Subprotocols: []string{"shopify-extensions-v99"}}
+	_, response, err := dialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("Expected unsupported protocol to be rejected")
+	}
+
+	if response == nil || response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected bad request status, got %v", response)
+	}
+}
+
+func TestWebsocketAssetChanged(t *testing.T) {
+	api := New(config)
+	server := httptest.NewServer(api)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/extensions/"
+
+	for _, test := range []struct {
+		protocol string
+		expected string
+	}{
+		{"shopify-extensions-v2", "asset_changed"},
+		// Version 1 clients fall back to reloading the extension
+		{"shopify-extensions-v1", "success"},
+	} {
+		dialer := websocket.Dialer{Subprotocols: []string{test.protocol}}
+		ws, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+
+		message := StatusUpdate{}
+		if err := ws.ReadJSON(&message); err != nil || message.Type != "connected" {
+			t.Fatalf("Expected the connected message, got %+v, %v", message, err)
+		}
+
+		extension := config.Extensions[0]
+		go api.Notify(StatusUpdate{Type: "asset_changed", Extensions: []core.Extension{extension}, Asset: &ChangedAsset{Name: "main.css"}})
+
+		message = StatusUpdate{}
+		if err := ws.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+
+		if message.Type != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.protocol, message.Type)
+		}
+
+		expectedUrl := fmt.Sprintf("http://localhost:8000/extensions/%s/assets/main.css", extension.UUID)
+		if test.expected == "asset_changed" && (message.Asset == nil || message.Asset.Url != expectedUrl) {
+			t.Errorf("Expected the URL of the changed asset, got %+v", message.Asset)
+		}
+	}
+}
+
+func TestWebsocketFocus(t *testing.T) {
+	focusConfig := *config
+	second := config.Extensions[0]
+	second.UUID = "00000000-0000-0000-0000-000000000001"
+	focusConfig.Extensions = []core.Extension{config.Extensions[0], second}
+
+	api := New(&focusConfig)
+	server := httptest.NewServer(api)
+
+	ws, err := createWebsocket(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	connected := StatusUpdate{}
+	ws.ReadJSON(&connected)
+
+	if err := ws.WriteJSON(clientMessage{Type: "focus", UUID: second.UUID}); err != nil {
+		t.Fatal(err)
+	}
+
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	update := StatusUpdate{}
+	if err := ws.ReadJSON(&update); err != nil {
+		t.Fatal(err)
+	}
+
+	if update.Type != "focus" || len(update.Extensions) != 2 {
+		t.Fatalf("Expected a focus update with all extensions, got %+v", update)
+	}
+
+	if update.Extensions[0].UUID != second.UUID || !update.Extensions[0].Development.Focused || update.Extensions[1].Development.Focused {
+		t.Errorf("Expected the focused extension first, got %+v", update.Extensions)
+	}
+
+	req, err := http.NewRequest("GET", "/extensions/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	response := extensionsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Extensions[0].UUID != second.UUID || !response.Extensions[0].Development.Focused {
+		t.Errorf("Expected the manifest to list the focused extension first, got %+v", response.Extensions)
+	}
+}
+
+func TestGetExtensionsWarnings(t *testing.T) {
+	req, err := http.NewRequest("GET", "/extensions/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	New(config).ServeHTTP(rec, req)
+
+	response := extensionsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test configuration has checkout extensions but no store
+	expected := "extension 00000000-0000-0000-0000-000000000000 renders in checkout, set store to preview it on a shop"
+	if len(response.Warnings) == 0 || response.Warnings[0] != expected {
+		t.Errorf("Expected the warnings of the configuration, got %v", response.Warnings)
+	}
+}
+
+func TestStreamLargeManifest(t *testing.T) {
+	largeConfig := &core.Config{Port: 8000, Store: "my-shop.myshopify.com"}
+	for index := 0; index < 500; index++ {
+		largeConfig.Extensions = append(largeConfig.Extensions, core.Extension{
+			UUID:        fmt.Sprintf("extension-%03d", index),
+			Type:        "checkout_ui_extension",
+			Development: core.Development{Entries: map[string]string{"main": "src/index.js"}},
+		})
+	}
+	api := configureExtensionsApi(largeConfig, mux.NewRouter(), os.DirFS)
+
+	req, err := http.NewRequest("GET", "/extensions/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Error("Expected the manifest to be flushed while streaming")
+	}
+
+	expected, err := json.Marshal(api.extensionsResponse(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Body.String() != string(expected)+"\n" {
+		t.Errorf("Expected the streamed manifest to match the encoded one, got %.200s...", rec.Body.String())
+	}
+}
+
+func TestReloadableApi(t *testing.T) {
+	reloadable := NewReloadableApi(New(config))
+
+	serve := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		reloadable.ServeHTTP(rec, req)
+		return rec
+	}
+
+	err := reloadable.Reload(func() (*ExtensionsApi, error) {
+		if rec := serve(); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("Expected a 503 with Retry-After during the reload, got %d %v", rec.Code, rec.Header())
+		}
+		return nil, errors.New("invalid configuration")
+	})
+	if err == nil {
+		t.Error("Expected the failed reload to be reported")
+	}
+
+	if rec := serve(); rec.Code != http.StatusOK {
+		t.Errorf("Expected the previous API to keep serving after a failed reload, got %d", rec.Code)
+	}
+
+	reloadedConfig := *config
+	reloadedConfig.Extensions = []core.Extension{}
+	if err := reloadable.Reload(func() (*ExtensionsApi, error) { return New(&reloadedConfig), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve(); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the reloaded API to serve requests, got %d", rec.Code)
+	}
+}
+
+func TestWebsocketConnectionLimit(t *testing.T) {
+	limitedConfig := *config
+	limitedConfig.MaxConnections = 1
+
+	api := New(&limitedConfig)
+	server := httptest.NewServer(api)
+
+	ws, err := createWebsocket(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/extensions/"
+	_, response, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("Expected connection beyond the limit to be rejected")
+	}
+
+	if response == nil || response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected service unavailable status, got %v", response)
 	}
 }
 
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// extensionArchiveHandler streams the build directory of an extension as a
+// zip archive, to share the built assets in one download. Hidden files, e.g.
+// the build cache, aren't included, nor are source maps when they aren't
+// served. Like assets, archives require the preview token of protected
+// extensions.
+func (api *ExtensionsApi) extensionArchiveHandler(rw http.ResponseWriter, r *http.Request) {
+	extension, found := api.findExtension(mux.Vars(r)["uuid"])
+	if !found {
+		http.NotFound(rw, r)
+		return
+	}
+
+	if !requirePreviewToken(rw, r, extension) {
+		return
+	}
+
+	buildDir := api.openDir(filepath.Join(extension.Development.RootDir, extension.Development.BuildDir))
+	if info, err := fs.Stat(buildDir, "."); err != nil || !info.IsDir() {
+		http.Error(rw, "the extension hasn't been built yet", http.StatusNotFound)
+		return
+	}
+
+	if !api.acquireAssetRead(r) {
+		return
+	}
+	defer api.releaseAssetRead()
+
+	rw.Header().Set("Content-Type", "application/zip")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", extension.UUID+"-assets.zip"))
+
+	// The status is sent with the first file, errors can only be logged
+	if err := api.writeArchive(rw, buildDir); err != nil {
+		log.Printf("[Assets] Unable to archive the assets of extension %s: %v", extension.UUID, err)
+	}
+}
+
+func (api *ExtensionsApi) writeArchive(w io.Writer, buildDir fs.FS) error {
+	archive := zip.NewWriter(w)
+
+	err := api.walkBuildDir(buildDir, func(name string, entry fs.DirEntry) error {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		header.Method = zip.Deflate
+
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		file, err := buildDir.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(writer, file)
+		return err
+	})
+
+	if err != nil {
+		archive.Close()
+		return err
+	}
+	return archive.Close()
+}
+
+// walkBuildDir calls visit with the name of each file of the build directory
+// that is served. Hidden files, e.g. the build cache, are skipped, as are
+// source maps when they aren't served.
+func (api *ExtensionsApi) walkBuildDir(buildDir fs.FS, visit func(name string, entry fs.DirEntry) error) error {
+	serveSourceMaps := api.config.ServeSourceMaps == nil || *api.config.ServeSourceMaps
+
+	return fs.WalkDir(buildDir, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || (!serveSourceMaps && strings.HasSuffix(name, ".map")) {
+			return nil
+		}
+		return visit(name, entry)
+	})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// getAssetHeaders merges the global asset_headers with the ones of the
+// extension, which win for headers set in both
+func getAssetHeaders(config *core.Config, extension core.Extension) http.Header {
+	headers := make(http.Header)
+	for name, value := range config.AssetHeaders {
+		headers.Set(name, value)
+	}
+	for name, value := range extension.Development.AssetHeaders {
+		headers.Set(name, value)
+	}
+	return headers
+}
+
+// headerResponseWriter sets the configured headers right before the status
+// is written, so that they replace headers of the same name the file server
+// set, e.g. Cache-Control or Content-Type.
+type headerResponseWriter struct {
+	http.ResponseWriter
+	headers     http.Header
+	wroteHeader bool
+}
+
+func (w *headerResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for name, values := range w.headers {
+			w.Header()[name] = values
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *headerResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
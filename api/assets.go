@@ -0,0 +1,276 @@
+package api
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// assetHandler serves the build artifacts of an extension and keeps track of
+// how many bytes were served for it.
+func (api *ExtensionsApi) assetHandler(extension core.Extension, prefix string) http.Handler {
+	assets := api.openDir(filepath.Join(extension.Development.RootDir, extension.Development.BuildDir))
+	fileServer := http.StripPrefix(prefix, http.FileServer(http.FS(assets)))
+
+	bytesServed := new(uint64)
+	api.bytesServed[extension.UUID] = bytesServed
+
+	mimeTypes := make(map[string]string)
+	for fileExtension, contentType := range extension.Development.MimeTypes {
+		if !strings.HasPrefix(fileExtension, ".") {
+			fileExtension = "." + fileExtension
+		}
+		mimeTypes[fileExtension] = contentType
+	}
+
+	serveSourceMaps := api.config.ServeSourceMaps == nil || *api.config.ServeSourceMaps
+	headers := getAssetHeaders(api.config, extension)
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !requirePreviewToken(rw, r, extension) {
+			return
+		}
+
+		if !serveSourceMaps && strings.HasSuffix(r.URL.Path, ".map") {
+			http.NotFound(rw, r)
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/") {
+			api.listAssets(rw, r, assets, r.URL.Path == prefix)
+			return
+		}
+
+		// The file server only guesses the content type when none is set
+		if contentType, ok := mimeTypes[filepath.Ext(r.URL.Path)]; ok {
+			rw.Header().Set("Content-Type", contentType)
+		}
+
+		if filepath.Ext(r.URL.Path) == ".wasm" {
+			r = servePrecompressedWasm(rw, r, assets, prefix)
+		}
+
+		if !api.acquireAssetRead(r) {
+			return
+		}
+		defer api.releaseAssetRead()
+
+		writer := &countingResponseWriter{ResponseWriter: rw}
+		if len(headers) > 0 {
+			writer.ResponseWriter = &headerResponseWriter{ResponseWriter: rw, headers: headers}
+		}
+		fileServer.ServeHTTP(writer, r)
+
+		atomic.AddUint64(bytesServed, writer.bytes)
+
+		if api.config.Verbose {
+			log.Printf("[Assets] Served %s (%d bytes) for extension: %s", r.URL.Path, writer.bytes, extension.UUID)
+		}
+	})
+}
+
+// withLastModified returns a copy of the assets of an extension with the
+// modification time of the files they are served from. Assets are stat'ed on
+// every call, so the time is current after rebuilds.
+func (api *ExtensionsApi) withLastModified(extension core.Extension) []core.Asset {
+	buildDir := api.openDir(filepath.Join(extension.Development.RootDir, extension.Development.BuildDir))
+
+	assets := make([]core.Asset, len(extension.Assets))
+	for index, asset := range extension.Assets {
+		assets[index] = asset
+
+		name, ok := api.assetFileName(extension, asset)
+		if !ok {
+			continue
+		}
+
+		info, err := fs.Stat(buildDir, name)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		lastModified := info.ModTime().UTC()
+		assets[index].LastModified = &lastModified
+	}
+	return assets
+}
+
+// assetName turns a path below the asset route into a name of the build
+// directory's file system, which can't escape the build directory
+func assetName(assetPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+assetPath), "/")
+}
+
+// acquireAssetRead waits for a free slot when max_concurrent_asset_reads is
+// set, which keeps hosts fetching many assets at once from exhausting the
+// file descriptors of constrained environments. It returns false when the
+// client gave up while waiting.
+func (api *ExtensionsApi) acquireAssetRead(r *http.Request) bool {
+	if api.assetReads == nil {
+		return true
+	}
+
+	select {
+	case api.assetReads <- struct{}{}:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (api *ExtensionsApi) releaseAssetRead() {
+	if api.assetReads != nil {
+		<-api.assetReads
+	}
+}
+
+// precompressedEncodings maps content encodings to the file extension of
+// precompressed assets, in order of preference
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// servePrecompressedWasm prepares the response for a WebAssembly module.
+// WebAssembly.instantiateStreaming requires the application/wasm content
+// type. Modules are never compressed on the fly, but a .br or .gz file
+// next to the module is served instead when the client accepts it. The
+// returned request points to the file to serve.
+func servePrecompressedWasm(rw http.ResponseWriter, r *http.Request, assets fs.FS, prefix string) *http.Request {
+	if rw.Header().Get("Content-Type") == "" {
+		rw.Header().Set("Content-Type", "application/wasm")
+	}
+	rw.Header().Add("Vary", "Accept-Encoding")
+
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	assetPath := assetName(strings.TrimPrefix(r.URL.Path, prefix))
+
+	for _, precompressed := range precompressedEncodings {
+		if !accepted[precompressed.encoding] {
+			continue
+		}
+
+		if info, err := fs.Stat(assets, assetPath+precompressed.extension); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		rw.Header().Set("Content-Encoding", precompressed.encoding)
+		precompressedRequest := r.Clone(r.Context())
+		precompressedRequest.URL.Path = r.URL.Path + precompressed.extension
+		return precompressedRequest
+	}
+
+	return r
+}
+
+// acceptedEncodings returns the encodings of an Accept-Encoding header that
+// aren't disabled with q=0
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		encoding := strings.ToLower(strings.TrimSpace(params[0]))
+
+		disabled := false
+		for _, param := range params[1:] {
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(param), "q="), 64); err == nil && q == 0 {
+				disabled = true
+			}
+		}
+
+		if encoding != "" && !disabled {
+			accepted[encoding] = true
+		}
+	}
+	return accepted
+}
+
+// listAssets replaces the HTML directory listing of the file server. Unless
+// enabled through list_assets, directories aren't listed at all.
+func (api *ExtensionsApi) listAssets(rw http.ResponseWriter, r *http.Request, assets fs.FS, isRoot bool) {
+	if !api.config.ListAssets || !isRoot {
+		http.NotFound(rw, r)
+		return
+	}
+
+	files := make([]assetFile, 0)
+	err := fs.WalkDir(assets, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, assetFile{name, info.Size()})
+		return nil
+	})
+
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("unable to list assets: %v", err), http.StatusNotFound)
+		return
+	}
+
+	rw.Header().Add("Content-Type", "application/json")
+	encoder := api.newJSONEncoder(rw, r)
+	encoder.Encode(assetListResponse{files})
+}
+
+func (api *ExtensionsApi) metricsHandler(rw http.ResponseWriter, r *http.Request) {
+	extensions := visibleExtensions(r, api.getExtensions())
+	metrics := make([]extensionMetrics, 0, len(extensions))
+	for _, extension := range extensions {
+		metrics = append(metrics, extensionMetrics{
+			UUID:        extension.UUID,
+			BytesServed: atomic.LoadUint64(api.bytesServed[extension.UUID]),
+		})
+	}
+
+	rw.Header().Add("Content-Type", "application/json")
+	encoder := api.newJSONEncoder(rw, r)
+	encoder.Encode(metricsResponse{metrics})
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	bytes uint64
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += uint64(n)
+	return n, err
+}
+
+type assetListResponse struct {
+	Assets []assetFile `json:"assets"`
+}
+
+type assetFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type metricsResponse struct {
+	Extensions []extensionMetrics `json:"extensions"`
+}
+
+type extensionMetrics struct {
+	UUID        string `json:"uuid"`
+	BytesServed uint64 `json:"bytesServed"`
+}
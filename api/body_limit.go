@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultMaxRequestBodySize int64 = 1 << 20
+
+// assetPath matches the routes serving build output, which stream files
+// rather than read request bodies
+var assetPath = regexp.MustCompile(`/extensions/[^/]+/assets/`)
+
+// limitRequestBody caps the size of request bodies so a client can't make the
+// server buffer an arbitrarily large body. Websocket upgrades and asset
+// downloads are excluded. It returns false after answering requests that
+// announce a body above the limit, bodies without a length fail once the
+// handler reads past it.
+func (api *ExtensionsApi) limitRequestBody(rw http.ResponseWriter, r *http.Request) bool {
+	limit := api.maxRequestBodySize()
+	if limit < 0 || websocket.IsWebSocketUpgrade(r) || assetPath.MatchString(r.URL.Path) {
+		return true
+	}
+
+	if r.ContentLength > limit {
+		http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(rw, r.Body, limit)
+	return true
+}
+
+// maxRequestBodySize defaults to 1 MiB, a negative max_request_body_size
+// disables the limit
+func (api *ExtensionsApi) maxRequestBodySize() int64 {
+	if api.config.MaxRequestBodySize != 0 {
+		return api.config.MaxRequestBodySize
+	}
+	return defaultMaxRequestBodySize
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// compress gzips the responses of a handler for clients accepting gzip.
+// Vary: Accept-Encoding is set either way, otherwise a shared cache could
+// serve a gzipped manifest to a client that can't decode it. Assets aren't
+// compressed on the fly, see servePrecompressedWasm.
+func compress(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			handler(rw, r)
+			return
+		}
+
+		rw.Header().Add("Vary", "Accept-Encoding")
+		if !acceptedEncodings(r.Header.Get("Accept-Encoding"))["gzip"] || r.Method == http.MethodHead {
+			handler(rw, r)
+			return
+		}
+
+		rw.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(rw)
+		defer writer.Close()
+
+		handler(&gzipResponseWriter{rw, writer}, r)
+	}
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	// The length of the uncompressed body doesn't apply
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.writer.Write(b)
+}
+
+// Flush sends what was compressed so far, so streamed responses reach the
+// client incrementally
+func (w *gzipResponseWriter) Flush() {
+	w.writer.Flush()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
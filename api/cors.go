@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultCorsMaxAge = 10 * time.Minute
+
+var defaultCorsMethods = []string{"GET", "POST", "OPTIONS"}
+
+// ServeHTTP applies the body size limit and the CORS configuration before
+// routing the request, so that preflight requests are answered for every
+// route, including commands that only accept POST.
+func (api *ExtensionsApi) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if !api.limitRequestBody(rw, r) {
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !api.isAllowedOrigin(origin) {
+		api.Router.ServeHTTP(rw, r)
+		return
+	}
+
+	cors := api.config.Cors
+	header := rw.Header()
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		api.Router.ServeHTTP(rw, r)
+		return
+	}
+
+	methods := cors.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCorsMethods
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if len(cors.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+
+	if maxAge := api.corsMaxAge(); maxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// isAllowedOrigin checks the origin against allowed_origins, CORS is
+// disabled when no origins are configured
+func (api *ExtensionsApi) isAllowedOrigin(origin string) bool {
+	for _, allowed := range api.config.Cors.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMaxAge is how long browsers may cache the result of a preflight
+// request. Caching saves a round trip for every cross-origin request of
+// hosts, a negative max_age disables it.
+func (api *ExtensionsApi) corsMaxAge() time.Duration {
+	if api.config.Cors.MaxAge != 0 {
+		return api.config.Cors.MaxAge
+	}
+	return defaultCorsMaxAge
+}
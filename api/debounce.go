@@ -0,0 +1,67 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultNotifyDebounce = 150 * time.Millisecond
+
+// debouncer coalesces the status updates of an extension that follow each
+// other within the debounce window, so a burst of rebuilds makes hosts reload
+// once rather than for every build
+type debouncer struct {
+	mutex   sync.Mutex
+	pending map[string]*StatusUpdate
+	timers  map[string]*time.Timer
+}
+
+// debounce sends the update once the window since the first pending update
+// of the extension passed, unless a later update replaced it by then
+func (d *debouncer) debounce(uuid string, window time.Duration, statusUpdate StatusUpdate, send func(StatusUpdate)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.pending == nil {
+		d.pending = make(map[string]*StatusUpdate)
+		d.timers = make(map[string]*time.Timer)
+	}
+
+	if pending, found := d.pending[uuid]; found {
+		*pending = statusUpdate
+		return
+	}
+
+	d.pending[uuid] = &statusUpdate
+	d.timers[uuid] = time.AfterFunc(window, func() {
+		d.mutex.Lock()
+		latest := *d.pending[uuid]
+		delete(d.pending, uuid)
+		delete(d.timers, uuid)
+		d.mutex.Unlock()
+
+		send(latest)
+	})
+}
+
+// stop drops the pending updates
+func (d *debouncer) stop() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for uuid, timer := range d.timers {
+		if timer.Stop() {
+			delete(d.pending, uuid)
+			delete(d.timers, uuid)
+		}
+	}
+}
+
+// notifyDebounce defaults to 150ms, a negative notify_debounce disables
+// debouncing
+func (api *ExtensionsApi) notifyDebounce() time.Duration {
+	if api.config.NotifyDebounce != 0 {
+		return api.config.NotifyDebounce
+	}
+	return defaultNotifyDebounce
+}
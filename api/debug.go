@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// extensionDebugHandler serializes an extension including the development
+// settings that are hidden from the manifest, so that the resolved paths can
+// be checked when something goes wrong. The route only exists with debug
+// endpoints enabled.
+func (api *ExtensionsApi) extensionDebugHandler(rw http.ResponseWriter, r *http.Request) {
+	extension, found := api.findExtension(mux.Vars(r)["uuid"])
+	if !found {
+		http.NotFound(rw, r)
+		return
+	}
+
+	if !requirePreviewToken(rw, r, extension) {
+		return
+	}
+
+	config, err := extensionConfig(extension)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	development := extension.Development
+	buildDir := filepath.Join(development.RootDir, development.BuildDir)
+	absBuildDir, err := filepath.Abs(buildDir)
+	if err != nil {
+		absBuildDir = buildDir
+	}
+
+	rw.Header().Add("Content-Type", "application/json")
+	encoder := api.newJSONEncoder(rw, r)
+	encoder.Encode(extensionDebugResponse{
+		Extension:        extension,
+		Config:           config,
+		ResolvedBuildDir: absBuildDir,
+		HasPreviewToken:  development.PreviewToken != "",
+	})
+}
+
+// extensionConfig serializes the extension with the YAML tags its config is
+// read with, so that new settings show up without being listed here. The
+// preview token itself is never exposed.
+func extensionConfig(extension core.Extension) (map[string]interface{}, error) {
+	extension.Development.PreviewToken = ""
+
+	content, err := yaml.Marshal(extension)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+type extensionDebugResponse struct {
+	Extension        core.Extension         `json:"extension"`
+	Config           map[string]interface{} `json:"config"`
+	ResolvedBuildDir string                 `json:"resolvedBuildDir"`
+	HasPreviewToken  bool                   `json:"hasPreviewToken"`
+}
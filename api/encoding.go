@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+var manifestContentTypes = []string{"application/json", "application/yaml", "text/yaml"}
+
+// encodeManifest writes the manifest as JSON, or as YAML for clients
+// preferring application/yaml or text/yaml, which is easier to read by hand
+func (api *ExtensionsApi) encodeManifest(rw http.ResponseWriter, r *http.Request, manifest interface{}) {
+	contentType := negotiateContentType(r.Header.Get("Accept"), manifestContentTypes, "application/json")
+	rw.Header().Add("Content-Type", contentType)
+	rw.Header().Add("Vary", "Accept")
+
+	if contentType == "application/json" {
+		api.newJSONEncoder(rw, r).Encode(manifest)
+		return
+	}
+
+	// The YAML tags of extensions describe the configuration rather than the
+	// manifest, the YAML mirrors the JSON instead
+	var buffer bytes.Buffer
+	json.NewEncoder(&buffer).Encode(manifest)
+	var document interface{}
+	if err := json.NewDecoder(&buffer).Decode(&document); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	encoder := yaml.NewEncoder(rw)
+	encoder.SetIndent(2)
+	encoder.Encode(document)
+	encoder.Close()
+}
+
+// streamFlushInterval is the number of extensions after which a streamed
+// manifest is flushed to the client
+const streamFlushInterval = 50
+
+// streamExtensionsResponse writes the manifest one extension at a time
+// rather than encoding it at once, so the first bytes reach the client early
+// and the encoded manifest is never held in memory for apps with hundreds of
+// extensions. The output is the same as encoding the response. Indented and
+// YAML responses are encoded at once.
+func (api *ExtensionsApi) streamExtensionsResponse(rw http.ResponseWriter, r *http.Request, response extensionsResponse) {
+	contentType := negotiateContentType(r.Header.Get("Accept"), manifestContentTypes, "application/json")
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); contentType != "application/json" || (err == nil && pretty) {
+		api.encodeManifest(rw, r, response)
+		return
+	}
+
+	rw.Header().Add("Content-Type", contentType)
+	rw.Header().Add("Vary", "Accept")
+	flusher, _ := rw.(http.Flusher)
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(api.config.EscapeHTML == nil || *api.config.EscapeHTML)
+
+	// encode writes a value without the newline of the encoder
+	encode := func(value interface{}) error {
+		buffer.Reset()
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		_, err := rw.Write(bytes.TrimSuffix(buffer.Bytes(), []byte("\n")))
+		return err
+	}
+
+	io.WriteString(rw, `{"extensions":[`)
+	for index, extension := range response.Extensions {
+		if index > 0 {
+			io.WriteString(rw, ",")
+		}
+		if err := encode(extension); err != nil {
+			log.Printf("[Manifest] Unable to stream the manifest: %v", err)
+			return
+		}
+		if flusher != nil && (index+1)%streamFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+	io.WriteString(rw, "],")
+
+	// The remaining fields, without the opening brace of their object
+	buffer.Reset()
+	encoder.Encode(struct {
+		Version  string   `json:"version"`
+		Store    string   `json:"store,omitempty"`
+		Warnings []string `json:"warnings,omitempty"`
+	}{response.Version, response.Store, response.Warnings})
+	rw.Write(bytes.TrimPrefix(buffer.Bytes(), []byte("{")))
+}
+
+// newJSONEncoder returns the encoder of JSON responses. Clients can ask for
+// indented output with ?pretty=true, which is easier to read while debugging.
+func (api *ExtensionsApi) newJSONEncoder(rw http.ResponseWriter, r *http.Request) *json.Encoder {
+	encoder := json.NewEncoder(rw)
+
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil && pretty {
+		encoder.SetIndent("", "  ")
+	}
+
+	if api.config.EscapeHTML != nil && !*api.config.EscapeHTML {
+		encoder.SetEscapeHTML(false)
+	}
+
+	return encoder
+}
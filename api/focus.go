@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// getExtensions returns a snapshot of the extensions with the focused
+// extension first. Extensions are read through this method once the server
+// runs, since focus messages of clients update them concurrently.
+func (api *ExtensionsApi) getExtensions() []core.Extension {
+	api.extensionsMutex.RLock()
+	extensions := append([]core.Extension{}, api.Extensions...)
+	api.extensionsMutex.RUnlock()
+
+	if api.config.AssetLastModified {
+		for index := range extensions {
+			extensions[index].Assets = api.withLastModified(extensions[index])
+		}
+	}
+
+	sort.SliceStable(extensions, func(i, j int) bool {
+		return extensions[i].Development.Focused && !extensions[j].Development.Focused
+	})
+	return extensions
+}
+
+// setFocus focuses the extension with the given UUID and unfocuses all
+// others. An empty UUID unfocuses all extensions.
+func (api *ExtensionsApi) setFocus(uuid string) bool {
+	api.extensionsMutex.Lock()
+	defer api.extensionsMutex.Unlock()
+
+	found := uuid == ""
+	for index := range api.Extensions {
+		focused := api.Extensions[index].UUID == uuid
+		found = found || focused
+		api.Extensions[index].Development.Focused = focused
+	}
+	return found
+}
+
+// handleClientMessage processes focus and unfocus messages of hosts and
+// broadcasts the updated extensions to all clients
+func (api *ExtensionsApi) handleClientMessage(message []byte) {
+	var clientMessage clientMessage
+	if err := json.Unmarshal(message, &clientMessage); err != nil {
+		if api.config.Verbose {
+			log.Printf("[Websocket] Ignoring invalid message: %v", err)
+		}
+		return
+	}
+
+	var focused bool
+	switch clientMessage.Type {
+	case "focus":
+		focused = api.setFocus(clientMessage.UUID)
+	case "unfocus":
+		focused = api.setFocus("")
+	default:
+		return
+	}
+
+	if !focused {
+		log.Printf("[Websocket] Unable to focus unknown extension %s", clientMessage.UUID)
+		return
+	}
+
+	// This runs on the reader of the connection, which has to keep reading
+	// close and ping frames while the clients are notified
+	go api.Notify(StatusUpdate{Type: clientMessage.Type, Extensions: api.getExtensions()})
+}
+
+type clientMessage struct {
+	Type string `json:"type"`
+	UUID string `json:"uuid"`
+}
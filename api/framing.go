@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// defaultFramingPolicies lists the pages allowed to embed the preview of an
+// extension for each surface. Checkout is rendered on the storefront of the
+// shop, while admin extensions are embedded by the admin. Other surfaces can
+// only be framed by the server itself.
+var defaultFramingPolicies = map[string]core.FramingPolicy{
+	"checkout": {FrameAncestors: []string{"'self'", "https://*.myshopify.com", "https://checkout.shopify.com"}},
+	"admin":    {FrameAncestors: []string{"'self'", "https://admin.shopify.com", "https://*.myshopify.com"}},
+}
+
+var defaultFramingPolicy = core.FramingPolicy{FrameAncestors: []string{"'self'"}}
+
+// framingPolicy returns the policy configured for the surface of the
+// extension, falling back to the default one of the surface. It always
+// follows the type of the extension, a previewed surface can't widen it.
+func (api *ExtensionsApi) framingPolicy(extension core.Extension) core.FramingPolicy {
+	surface := core.Surface(extension.Type)
+	if policy, ok := api.config.Framing[surface]; ok {
+		return policy
+	}
+	if policy, ok := defaultFramingPolicies[surface]; ok {
+		return policy
+	}
+	return defaultFramingPolicy
+}
+
+// setFramingHeaders adds the frame-ancestors directive to the
+// Content-Security-Policy and sets X-Frame-Options for browsers that don't
+// support it. X-Frame-Options can't list origins, so it's only derived from
+// policies restricted to 'self' or 'none' unless configured explicitly.
+func setFramingHeaders(header http.Header, policy core.FramingPolicy) {
+	if len(policy.FrameAncestors) > 0 {
+		directive := "frame-ancestors " + strings.Join(policy.FrameAncestors, " ")
+		if existing := header.Get("Content-Security-Policy"); existing != "" {
+			directive = existing + "; " + directive
+		}
+		header.Set("Content-Security-Policy", directive)
+	}
+
+	xFrameOptions := policy.XFrameOptions
+	if xFrameOptions == "" && len(policy.FrameAncestors) == 1 {
+		switch policy.FrameAncestors[0] {
+		case "'self'":
+			xFrameOptions = "SAMEORIGIN"
+		case "'none'":
+			xFrameOptions = "DENY"
+		}
+	}
+	if xFrameOptions != "" {
+		header.Set("X-Frame-Options", xFrameOptions)
+	}
+}
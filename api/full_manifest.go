@@ -0,0 +1,135 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// fullManifestHandler serves all extensions with the SHA-256 hash of every
+// file of their build directories, read on each request. Deploy steps use it
+// to decide which files to upload. The entry assets come first, those that
+// weren't built yet have a null hash.
+func (api *ExtensionsApi) fullManifestHandler(rw http.ResponseWriter, r *http.Request) {
+	extensions := visibleExtensions(r, api.getExtensions())
+	response := fullManifestResponse{
+		Extensions: make([]hashedExtension, 0, len(extensions)),
+		Version:    api.Version,
+		Store:      api.config.Store,
+	}
+
+	for _, extension := range extensions {
+		response.Extensions = append(response.Extensions, hashedExtension{extension, api.hashAssets(extension)})
+	}
+
+	rw.Header().Add("Content-Type", "application/json")
+	encoder := api.newJSONEncoder(rw, r)
+	encoder.Encode(response)
+}
+
+// hashAssets hashes the entry assets of the extension and the other files
+// of its build directory, e.g. chunks, images or locales
+func (api *ExtensionsApi) hashAssets(extension core.Extension) []hashedAsset {
+	buildDir := api.openDir(filepath.Join(extension.Development.RootDir, extension.Development.BuildDir))
+
+	assets := make([]hashedAsset, 0, len(extension.Assets))
+	listed := make(map[string]bool)
+	for _, asset := range extension.Assets {
+		hashed := hashedAsset{Asset: asset}
+		if name, ok := api.assetFileName(extension, asset); ok {
+			hashed.Path = name
+			listed[name] = true
+			if hash, err := hashFile(buildDir, name); err == nil {
+				hashed.Hash = &hash
+			}
+		}
+		assets = append(assets, hashed)
+	}
+
+	err := api.walkBuildDir(buildDir, func(name string, entry fs.DirEntry) error {
+		if listed[name] {
+			return nil
+		}
+
+		hash, err := hashFile(buildDir, name)
+		if err != nil {
+			return err
+		}
+
+		fileUrl := api.assetUrl(extension, name)
+		if token := extension.Development.PreviewToken; token != "" {
+			fileUrl = addToken(fileUrl, token)
+		}
+		assets = append(assets, hashedAsset{core.Asset{Name: name, Url: fileUrl}, name, &hash})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[Assets] Unable to hash the build directory of extension %s: %v", extension.UUID, err)
+	}
+	return assets
+}
+
+// assetFileName returns the name of the file an asset is served from within
+// the build directory. Assets served from elsewhere have no file.
+func (api *ExtensionsApi) assetFileName(extension core.Extension, asset core.Asset) (string, bool) {
+	prefix := fmt.Sprintf("%s/extensions/%s/assets/", api.config.AssetBase(), extension.UUID)
+
+	assetUrl, err := url.Parse(asset.Url)
+	if err != nil || !strings.HasPrefix(assetUrl.Path, prefix) {
+		return "", false
+	}
+	return assetName(strings.TrimPrefix(assetUrl.Path, prefix)), true
+}
+
+func hashFile(buildDir fs.FS, name string) (string, error) {
+	file, err := buildDir.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a file", name)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type fullManifestResponse struct {
+	Extensions []hashedExtension `json:"extensions"`
+	Version    string            `json:"version"`
+	Store      string            `json:"store,omitempty"`
+}
+
+// hashedExtension replaces the assets of the extension in the JSON output
+type hashedExtension struct {
+	core.Extension
+	Assets []hashedAsset `json:"assets"`
+}
+
+type hashedAsset struct {
+	core.Asset
+	// Path is the file of the asset relative to the build directory, empty
+	// for assets served from elsewhere
+	Path string `json:"path"`
+	// Hash is the hex encoded SHA-256 of the file, nil until it's built
+	Hash *string `json:"hash"`
+}
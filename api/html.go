@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+//go:embed templates/*
+var templates embed.FS
+
+var indexTemplate = template.Must(template.ParseFS(templates, "templates/index.html.tpl"))
+
+func (api *ExtensionsApi) handleExtensionHtmlRequest(rw http.ResponseWriter, r *http.Request, extension core.Extension) {
+	surface, err := previewSurface(r, extension)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		http.Error(rw, "failed to render extension", http.StatusInternalServerError)
+		return
+	}
+
+	var content bytes.Buffer
+	if err := renderTemplate(&content, extensionTemplateData{extension, nonce, surface}); err != nil {
+		log.Printf("[HTML] failed to render extension %s: %v", extension.UUID, err)
+		http.Error(rw, "failed to render extension", http.StatusInternalServerError)
+		return
+	}
+
+	// Inline scripts need to carry the nonce, so hosts enforcing a strict CSP don't need unsafe-inline
+	rw.Header().Set("Content-Security-Policy", fmt.Sprintf("script-src 'self' 'nonce-%s'", nonce))
+	setFramingHeaders(rw.Header(), api.framingPolicy(extension))
+	// Set explicitly, sniffing guesses text/plain for pages not starting with a tag
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Write(content.Bytes())
+}
+
+// previewSurface returns the surface the extension is previewed on, derived
+// from its type unless overridden with ?surface=, e.g. to preview a checkout
+// extension in the admin. The override is only passed to the page, the
+// framing policy stays the one of the extension's own surface.
+func previewSurface(r *http.Request, extension core.Extension) (string, error) {
+	override := r.URL.Query().Get("surface")
+	if override == "" {
+		return core.Surface(extension.Type), nil
+	}
+
+	for _, surface := range core.Surfaces {
+		if strings.EqualFold(override, surface) {
+			return surface, nil
+		}
+	}
+	return "", fmt.Errorf("unknown surface %q, expected one of %s", override, strings.Join(core.Surfaces, ", "))
+}
+
+// CheckTemplates renders the preview page of every extension to catch broken
+// templates when the server starts rather than on the first request.
+func (api *ExtensionsApi) CheckTemplates() error {
+	for _, namespace := range api.namespaces() {
+		for _, extension := range namespace.getExtensions() {
+			var content bytes.Buffer
+			if err := renderTemplate(&content, extensionTemplateData{extension, "check", core.Surface(extension.Type)}); err != nil {
+				return fmt.Errorf("unable to render the preview page of extension %s: %w", extension.UUID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// renderTemplate renders the preview page. Panics of methods called by the
+// template are returned as errors by html/template.
+func renderTemplate(content *bytes.Buffer, data extensionTemplateData) error {
+	if err := indexTemplate.Execute(content, data); err != nil {
+		return fmt.Errorf("unable to render %s: %w", indexTemplate.Name(), err)
+	}
+	return nil
+}
+
+// newNonce generates a random value for the script-src directive of the
+// Content-Security-Policy, it's unique per request.
+func newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+type extensionTemplateData struct {
+	Extension core.Extension
+	Nonce     string
+	// Surface is the surface the extension is previewed on
+	Surface string
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// extensionIconHandler serves the icon configured for an extension. The
+// content type is detected from the file extension, or the content when the
+// extension is unknown. Like assets, icons require the preview token of
+// protected extensions.
+func (api *ExtensionsApi) extensionIconHandler(rw http.ResponseWriter, r *http.Request) {
+	extension, found := api.findExtension(mux.Vars(r)["uuid"])
+	if !found || extension.Development.Icon == "" {
+		http.NotFound(rw, r)
+		return
+	}
+
+	if !requirePreviewToken(rw, r, extension) {
+		return
+	}
+
+	iconPath := filepath.Join(extension.Development.RootDir, filepath.FromSlash(extension.Development.Icon))
+	icon, err := os.Open(iconPath)
+	if err != nil {
+		http.NotFound(rw, r)
+		return
+	}
+	defer icon.Close()
+
+	info, err := icon.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(rw, r)
+		return
+	}
+
+	http.ServeContent(rw, r, info.Name(), info.ModTime(), icon)
+}
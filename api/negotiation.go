@@ -0,0 +1,106 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// negotiateContentType picks the offer preferred by the client's Accept header,
+// taking q-values and the specificity of media ranges into account.
+// The default offer wins when the header is missing, when the client only
+// expresses a wildcard preference or when none of the offers are acceptable.
+func negotiateContentType(accept string, offers []string, defaultOffer string) string {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return defaultOffer
+	}
+
+	best := defaultOffer
+	bestQuality := -1.0
+	bestSpecificity := -1
+
+	for _, offer := range offers {
+		quality, specificity := matchMediaRange(ranges, offer)
+		if quality <= 0 {
+			continue
+		}
+
+		if quality > bestQuality ||
+			(quality == bestQuality && specificity > bestSpecificity) ||
+			(quality == bestQuality && specificity == bestSpecificity && offer == defaultOffer) {
+			best = offer
+			bestQuality = quality
+			bestSpecificity = specificity
+		}
+	}
+
+	return best
+}
+
+// matchMediaRange returns the quality of the most specific media range
+// matching the offer along with its specificity:
+// 2 for an exact match, 1 for type/* and 0 for */*.
+func matchMediaRange(ranges []mediaRange, offer string) (quality float64, specificity int) {
+	offerType, offerSubtype := splitMediaType(offer)
+	specificity = -1
+
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mediaType == offerType && r.subtype == offerSubtype:
+			s = 2
+		case r.mediaType == offerType && r.subtype == "*":
+			s = 1
+		case r.mediaType == "*" && r.subtype == "*":
+			s = 0
+		}
+
+		if s > specificity {
+			specificity = s
+			quality = r.quality
+		}
+	}
+
+	return
+}
+
+func parseAccept(accept string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType, subtype := splitMediaType(params[0])
+		if mediaType == "" || subtype == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+				quality = q
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType, subtype, quality})
+	}
+
+	return ranges
+}
+
+func splitMediaType(value string) (string, string) {
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(value)), "/", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+type mediaRange struct {
+	mediaType string
+	subtype   string
+	quality   float64
+}
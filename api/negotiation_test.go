@@ -0,0 +1,32 @@
+package api
+
+import "testing"
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"application/json", "text/html"}
+
+	tests := []struct {
+		accept       string
+		defaultOffer string
+		expected     string
+	}{
+		{"", "application/json", "application/json"},
+		{"", "text/html", "text/html"},
+		{"*/*", "application/json", "application/json"},
+		{"*/*", "text/html", "text/html"},
+		{"text/html", "application/json", "text/html"},
+		{"application/json", "text/html", "application/json"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "application/json", "text/html"},
+		{"application/json;q=0.5, text/html;q=0.9", "application/json", "text/html"},
+		{"text/*, application/json;q=0.1", "application/json", "text/html"},
+		{"image/png", "text/html", "text/html"},
+		{"text/html;q=0, */*", "text/html", "application/json"},
+	}
+
+	for _, test := range tests {
+		actual := negotiateContentType(test.accept, offers, test.defaultOffer)
+		if actual != test.expected {
+			t.Errorf("Accept %q with default %s: expected %s, got %s", test.accept, test.defaultOffer, test.expected, actual)
+		}
+	}
+}
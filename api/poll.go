@@ -0,0 +1,148 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// longPollTimeout is how long a poll waits for updates, below the idle
+// timeout of most proxies
+var longPollTimeout = 25 * time.Second
+
+// pollTimeoutMargin is left between the end of a poll and the read_timeout
+// of the server, which cancels requests still running once it's over
+const pollTimeoutMargin = 5 * time.Second
+
+// pollBufferSize is the number of updates kept for clients to catch up on
+// between polls
+const pollBufferSize = 100
+
+// pollLog keeps the latest status updates of a namespace for clients that
+// can't use websockets. Each update gets the next cursor.
+type pollLog struct {
+	mutex   sync.Mutex
+	cursor  int64
+	updates []polledUpdate
+	// changed is closed and replaced whenever an update is added
+	changed chan struct{}
+}
+
+type polledUpdate struct {
+	cursor int64
+	update StatusUpdate
+}
+
+func (p *pollLog) add(statusUpdate StatusUpdate) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.cursor++
+	p.updates = append(p.updates, polledUpdate{p.cursor, statusUpdate})
+	if len(p.updates) > pollBufferSize {
+		p.updates = p.updates[len(p.updates)-pollBufferSize:]
+	}
+
+	if p.changed != nil {
+		close(p.changed)
+		p.changed = nil
+	}
+}
+
+func (p *pollLog) current() int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.cursor
+}
+
+// since returns the updates after the cursor, the latest cursor and a
+// channel closed once further updates arrive. reset is set when updates after
+// the cursor were already dropped from the buffer.
+func (p *pollLog) since(cursor int64) (updates []StatusUpdate, latest int64, changed <-chan struct{}, reset bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	reset = len(p.updates) > 0 && p.updates[0].cursor > cursor+1
+	updates = make([]StatusUpdate, 0)
+	for _, polled := range p.updates {
+		if polled.cursor > cursor {
+			updates = append(updates, polled.update)
+		}
+	}
+
+	if p.changed == nil {
+		p.changed = make(chan struct{})
+	}
+	return updates, p.cursor, p.changed, reset
+}
+
+// pollWait returns how long a poll may wait for updates, ending it before the
+// read_timeout of the server. Zero is the default read_timeout of 30s, which
+// leaves enough time, and negative disables it.
+func pollWait(readTimeout time.Duration) time.Duration {
+	if readTimeout <= 0 {
+		return longPollTimeout
+	}
+
+	wait := readTimeout - pollTimeoutMargin
+	if wait < readTimeout/2 {
+		wait = readTimeout / 2
+	}
+	if wait > longPollTimeout {
+		wait = longPollTimeout
+	}
+	return wait
+}
+
+// pollHandler is a long-poll fallback for the websocket status updates. It
+// returns the updates after the since cursor, waiting up to longPollTimeout,
+// or less with a short read_timeout, for one to arrive. Polls without a
+// cursor return the current one right away, clients then pass the cursor of
+// each response to the next poll.
+func (api *ExtensionsApi) pollHandler(rw http.ResponseWriter, r *http.Request) {
+	updates, cursor, reset := []StatusUpdate{}, api.pollLog.current(), false
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		sinceCursor, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || sinceCursor < 0 {
+			http.Error(rw, "since has to be a cursor returned by a previous poll", http.StatusBadRequest)
+			return
+		}
+
+		var changed <-chan struct{}
+		updates, cursor, changed, reset = api.pollLog.since(sinceCursor)
+		if len(updates) == 0 {
+			select {
+			case <-changed:
+				updates, cursor, _, reset = api.pollLog.since(sinceCursor)
+			case <-time.After(pollWait(api.config.ReadTimeout)):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	visible := make([]StatusUpdate, 0, len(updates))
+	for _, update := range updates {
+		if update, ok := visibleUpdate(r, update); ok {
+			visible = append(visible, update)
+		}
+	}
+
+	rw.Header().Add("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	encoder := api.newJSONEncoder(rw, r)
+	encoder.Encode(pollResponse{strconv.FormatInt(cursor, 10), visible, api.sessionId, reset})
+}
+
+type pollResponse struct {
+	Cursor  string         `json:"cursor"`
+	Updates []StatusUpdate `json:"updates"`
+	// SessionId changes when the server restarts, cursors of another session
+	// aren't valid anymore and clients should reload everything
+	SessionId string `json:"sessionId"`
+	// Reset is set when updates after the cursor were dropped, the client
+	// missed them and should reload everything
+	Reset bool `json:"reset,omitempty"`
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// hasPreviewToken checks the token of a request against the preview_token of
+// the extension. Extensions without a token are accessible to anyone.
+func hasPreviewToken(r *http.Request, extension core.Extension) bool {
+	expected := extension.Development.PreviewToken
+	if expected == "" {
+		return true
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = r.Header.Get("X-Preview-Token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// requirePreviewToken answers requests for an extension protected by a
+// preview token they don't carry with 403 Forbidden
+func requirePreviewToken(rw http.ResponseWriter, r *http.Request, extension core.Extension) bool {
+	if !hasPreviewToken(r, extension) {
+		http.Error(rw, "a valid preview token is required to access this extension", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// visibleExtensions drops the extensions protected by a preview token the
+// request doesn't carry, so that they can't be enumerated. The asset URLs of
+// protected extensions the request has the token of carry the token, since
+// hosts load them without the header.
+func visibleExtensions(r *http.Request, extensions []core.Extension) []core.Extension {
+	visible := make([]core.Extension, 0, len(extensions))
+	for _, extension := range extensions {
+		if hasPreviewToken(r, extension) {
+			visible = append(visible, withPreviewToken(extension))
+		}
+	}
+	return visible
+}
+
+// visibleUpdate filters the extensions of a status update like
+// visibleExtensions. Updates of extensions that are all hidden aren't sent.
+func visibleUpdate(r *http.Request, statusUpdate StatusUpdate) (StatusUpdate, bool) {
+	if len(statusUpdate.Extensions) == 0 {
+		return statusUpdate, true
+	}
+
+	statusUpdate.Extensions = visibleExtensions(r, statusUpdate.Extensions)
+	return statusUpdate, len(statusUpdate.Extensions) > 0
+}
+
+// visibleWarnings drops the warnings about extensions hidden from the
+// request, they name the extension
+func visibleWarnings(r *http.Request, extensions []core.Extension, warnings []string) []string {
+	visible := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		hidden := false
+		for _, extension := range extensions {
+			if !hasPreviewToken(r, extension) && strings.Contains(warning, extension.UUID) {
+				hidden = true
+				break
+			}
+		}
+		if !hidden {
+			visible = append(visible, warning)
+		}
+	}
+	if len(visible) == 0 {
+		return nil
+	}
+	return visible
+}
+
+// withPreviewToken adds the preview token of a protected extension to the
+// URLs of its assets and icon
+func withPreviewToken(extension core.Extension) core.Extension {
+	token := extension.Development.PreviewToken
+	if token == "" {
+		return extension
+	}
+
+	assets := make([]core.Asset, len(extension.Assets))
+	for index, asset := range extension.Assets {
+		assets[index] = asset
+		assets[index].Url = addToken(asset.Url, token)
+	}
+	extension.Assets = assets
+
+	if extension.Icon != nil {
+		extension.Icon = &core.Url{Url: addToken(extension.Icon.Url, token)}
+	}
+	return extension
+}
+
+func addToken(rawUrl, token string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	query := parsed.Query()
+	query.Set("token", token)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
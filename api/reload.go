@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ReloadableApi serves the current ExtensionsApi and lets the server swap it
+// for one built from a reloaded configuration. Requests arriving while the
+// new API is being built get a 503 asking them to retry shortly, rather than
+// seeing a mix of old and new routes.
+type ReloadableApi struct {
+	current   atomic.Value
+	reloading int32
+	mutex     sync.Mutex
+}
+
+func NewReloadableApi(api *ExtensionsApi) *ReloadableApi {
+	reloadable := &ReloadableApi{}
+	reloadable.current.Store(api)
+	return reloadable
+}
+
+// Current returns the API serving requests
+func (reloadable *ReloadableApi) Current() *ExtensionsApi {
+	return reloadable.current.Load().(*ExtensionsApi)
+}
+
+func (reloadable *ReloadableApi) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&reloadable.reloading) == 1 {
+		rw.Header().Set("Retry-After", "1")
+		http.Error(rw, "configuration is being reloaded", http.StatusServiceUnavailable)
+		return
+	}
+	reloadable.Current().ServeHTTP(rw, r)
+}
+
+// Reload replaces the current API with the one returned by load. When load
+// fails, the current API keeps serving requests. Websocket clients of the
+// replaced API are disconnected, so they reconnect to the new one.
+func (reloadable *ReloadableApi) Reload(load func() (*ExtensionsApi, error)) error {
+	reloadable.mutex.Lock()
+	defer reloadable.mutex.Unlock()
+
+	atomic.StoreInt32(&reloadable.reloading, 1)
+	defer atomic.StoreInt32(&reloadable.reloading, 0)
+
+	api, err := load()
+	if err != nil {
+		return err
+	}
+
+	previous := reloadable.Current()
+	reloadable.current.Store(api)
+	previous.Shutdown()
+	return nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// startTime is when the server process started. It's kept across
+// configuration reloads, so a change means the server restarted.
+var startTime = time.Now()
+
+// statusHandler reports the version of the server and how long it has been
+// running, which helps telling whether it restarted during a session.
+func (api *ExtensionsApi) statusHandler(rw http.ResponseWriter, r *http.Request) {
+	uptime := time.Since(startTime)
+
+	rw.Header().Add("Content-Type", "application/json")
+	encoder := api.newJSONEncoder(rw, r)
+	encoder.Encode(statusResponse{
+		Version:       api.config.ServerVersion,
+		Commit:        api.config.ServerCommit,
+		ApiVersion:    api.Version,
+		StartedAt:     startTime.UTC().Format(time.RFC3339),
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+	})
+}
+
+type statusResponse struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit,omitempty"`
+	ApiVersion    string `json:"apiVersion"`
+	StartedAt     string `json:"startedAt"`
+	Uptime        string `json:"uptime"`
+	UptimeSeconds int64  `json:"uptimeSeconds"`
+}
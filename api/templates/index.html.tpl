@@ -0,0 +1,26 @@
+<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8" />
+    <title>{{ .Extension.Type }} ({{ .Extension.UUID }})</title>
+  </head>
+  <body data-surface="{{ .Surface }}">
+    <h1>{{ .Extension.Type }}</h1>
+    <p>Extension <code>{{ .Extension.UUID }}</code> is being served by the Shopify CLI Extensions Server and previewed on the <code>{{ .Surface }}</code> surface.</p>
+    <h2>Assets</h2>
+    <ul>
+      {{- range .Extension.Assets }}
+      <li><a href="{{ .Url }}">{{ .Name }}</a></li>
+      {{- end }}
+    </ul>
+    <script nonce="{{ .Nonce }}">
+      const socket = new WebSocket(location.origin.replace(/^http/, "ws") + "/extensions/");
+      socket.addEventListener("message", (event) => {
+        const {type} = JSON.parse(event.data);
+        if (type !== "connected") {
+          location.reload();
+        }
+      });
+    </script>
+  </body>
+</html>
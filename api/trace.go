@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// RequestTracer records a span for each request, e.g. with OpenTelemetry
+type RequestTracer interface {
+	// StartRequest starts the span of a request, parent is the trace context
+	// of its traceparent header, if any
+	StartRequest(r *http.Request, parent *core.TraceParent) RequestSpan
+}
+
+// RequestSpan is ended with the status of the response
+type RequestSpan interface {
+	// TraceParent is the trace context of the span, which the upstream proxy
+	// forwards instead of the incoming one. Spans that aren't recorded
+	// return nil.
+	TraceParent() *core.TraceParent
+	End(status int)
+}
+
+// NewTraceContext wraps the handler to pass the W3C trace context of requests
+// on. A valid traceparent header is the parent of the request span and is
+// forwarded by the upstream proxy along with its tracestate, with the request
+// span as parent if it's recorded. Invalid ones are removed, as the spec
+// requires. The tracer is optional.
+func NewTraceContext(handler http.Handler, tracer RequestTracer) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var parent *core.TraceParent
+		if traceParent, ok := core.ParseTraceParent(r.Header.Get("traceparent")); ok {
+			parent = &traceParent
+		} else {
+			r.Header.Del("traceparent")
+			r.Header.Del("tracestate")
+		}
+
+		if tracer == nil {
+			handler.ServeHTTP(rw, r)
+			return
+		}
+
+		span := tracer.StartRequest(r, parent)
+		if traceParent := span.TraceParent(); traceParent != nil {
+			r.Header.Set("traceparent", traceParent.String())
+		}
+		recorder := &accessLogResponseWriter{ResponseWriter: rw}
+		handler.ServeHTTP(recorder, r)
+		span.End(recorder.status())
+	})
+}
+
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// newUpstreamProxy forwards requests that don't match any route to the
+// upstream_proxy, so that a framework dev server can be reached on the same
+// origin as the extensions. Websocket upgrades, e.g. for hot module
+// replacement, are proxied as well. It returns nil without upstream_proxy.
+func newUpstreamProxy(config *core.Config) http.Handler {
+	if config.UpstreamProxy == "" {
+		return nil
+	}
+
+	// The URL is validated when the configuration is loaded
+	upstream, err := url.Parse(config.UpstreamProxy)
+	if err != nil {
+		log.Printf("[Proxy] invalid upstream_proxy %q: %v", config.UpstreamProxy, err)
+		return nil
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	proxy.ErrorHandler = func(rw http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("[Proxy] %s %s failed: %v", r.Method, r.URL.Path, err)
+		http.Error(rw, "the upstream dev server is unavailable", http.StatusBadGateway)
+	}
+	return proxy
+}
@@ -2,77 +2,313 @@ package build
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/shopify-cli-extensions/core"
-	"github.com/fsnotify/fsnotify"
 )
 
-func NewBuilder(extension core.Extension) *Builder {
+func NewBuilder(extension core.Extension, options Options) *Builder {
+	if extension.Development.BuildDir == "" {
+		extension.Development.BuildDir = DetectBuildDir(extension.Development.RootDir)
+	}
+	if options.Mode != "" {
+		// Copied, since builders of other extensions share the options
+		options.Env = append(append([]string{}, options.Env...), options.Mode.env())
+	}
+
 	working_dir := filepath.Join(".", extension.Development.RootDir, extension.Development.BuildDir)
 	pm := FindPackageManager(exec.LookPath, working_dir)
-	return &Builder{pm, extension}
+	pm.env = options.Env
+	if options.Output != nil {
+		pm.stdout = options.Output
+		pm.stderr = options.Output
+	}
+	if options.OnProgress != nil {
+		pm.stdout = newProgressWriter(pm.stdout, options.OnProgress)
+		pm.stderr = newProgressWriter(pm.stderr, options.OnProgress)
+	}
+	return &Builder{pm, extension, true, options.NoCache, options, 0}
+}
+
+// Options configure how the build scripts of an extension are run.
+type Options struct {
+	// Env is added to the environment of the build scripts
+	Env []string
+	// Output receives the output of the build scripts, defaults to stdout
+	// and stderr
+	Output io.Writer
+	// NoCache runs production builds even if the sources didn't change since
+	// the last successful build
+	NoCache bool
+	// OnProgress receives the percentage of builds in steps of 10, as far as
+	// the output of the build tool tells, and IndeterminateProgress when a
+	// production build starts
+	OnProgress func(percent int)
+	// Mode is passed to the build scripts and hooks as NODE_ENV, they
+	// inherit NODE_ENV when it's empty
+	Mode Mode
+	// Tracer records a span for each production build, see Tracer
+	Tracer Tracer
+	// TraceParent is the parent of the build spans, see TraceParentFromEnv
+	TraceParent *core.TraceParent
+}
+
+// ConcurrencyEnv splits the concurrency budget evenly between extensions that
+// are built at the same time and passes each build its share. This keeps
+// build tools that parallelize work themselves from oversubscribing the CPU.
+func ConcurrencyEnv(budget, extensions int) []string {
+	if budget <= 0 {
+		budget = runtime.NumCPU()
+	}
+
+	jobs := 1
+	if extensions > 0 && budget/extensions > 1 {
+		jobs = budget / extensions
+	}
+
+	return []string{
+		fmt.Sprintf("JOBS=%d", jobs),
+		// esbuild is written in Go and honours GOMAXPROCS
+		fmt.Sprintf("GOMAXPROCS=%d", jobs),
+	}
 }
 
 type Builder struct {
 	ScriptRunner
 	Extension core.Extension
+	// cache skips production builds of sources that were already built
+	cache bool
+	// forceBuild runs production builds of cached sources anyway, their hash
+	// is still recorded
+	forceBuild bool
+	// options are passed on to the pre_build and post_build commands
+	options Options
+	// building counts the production builds in progress, Watch attributes
+	// the changes of the build directory to them
+	building int32
 }
 
 type Result struct {
 	Success bool
 	Error   error
 	UUID    string
+	// Duration is the wall-clock time a production build took, zero for
+	// development builds and watch events
+	Duration time.Duration
+	// Cached is set when a production build was skipped because the sources
+	// didn't change since the last successful build
+	Cached bool
+	// Asset is the file that changed, relative to the build directory, when
+	// a watch event isn't caused by a source change, e.g. a stylesheet copied
+	// into the build directory. Hosts can swap such assets without reloading.
+	Asset string
+	// Mode is the mode the extension was built in, empty when none was
+	// requested
+	Mode Mode
 }
 
 // production build
+//
+// The build script is asked to write into a temporary directory whose files
+// are moved into the build directory once the build succeeded, so that assets
+// requested during a rebuild are never half written. On failure the previous
+// build is kept. Build scripts ignoring --build-dir keep writing in place.
+//
+// Builds are skipped when the sources hash to the same value as when they
+// were last built successfully, see hashSources.
+//
+// The pre_build and post_build commands of the extension run before and after
+// the build script, a failing command fails the build. Each of the three
+// steps can have its own timeout.
 func (b *Builder) Build(ctx context.Context, yield func(result Result)) {
-	err := b.RunScript(ctx, "build")
+	yield = b.traced(ctx, yield)
+	start := time.Now()
+	buildDir := filepath.Join(b.Extension.Development.RootDir, b.Extension.Development.BuildDir)
+
+	hash, cached := b.isCached(buildDir)
+	if cached {
+		yield(Result{true, nil, b.Extension.UUID, time.Since(start), true, "", b.options.Mode})
+		return
+	}
+
+	if b.options.OnProgress != nil {
+		b.options.OnProgress(IndeterminateProgress)
+	}
+
+	// The build stays in progress until Watch had the time to see its last
+	// changes of the build directory
+	atomic.AddInt32(&b.building, 1)
+	defer time.AfterFunc(settleDelay, func() {
+		atomic.AddInt32(&b.building, -1)
+	})
+
+	timeouts := b.Extension.Development.Timeouts
+	err := b.checkNodeVersion(ctx)
+	if err == nil {
+		err = runStep(ctx, "pre_build", timeouts.PreBuild, func(ctx context.Context) error {
+			return b.runHooks(ctx, "pre_build", b.Extension.Development.PreBuild)
+		})
+	}
+	if err == nil {
+		err = runStep(ctx, "build", timeouts.Build, b.buildAndSwap)
+	}
+	if err == nil {
+		err = runStep(ctx, "post_build", timeouts.PostBuild, func(ctx context.Context) error {
+			return b.runHooks(ctx, "post_build", b.Extension.Development.PostBuild)
+		})
+	}
+	duration := time.Since(start)
+
+	if err == nil && hash != "" {
+		if cacheErr := writeCache(buildDir, hash); cacheErr != nil {
+			log.Printf("unable to record the build of extension %s: %v", b.Extension.UUID, cacheErr)
+		}
+	}
 
 	if err != nil {
-		yield(Result{false, err, b.Extension.UUID})
+		yield(Result{false, err, b.Extension.UUID, duration, false, "", b.options.Mode})
 	} else {
-		yield(Result{true, nil, b.Extension.UUID})
+		yield(Result{true, nil, b.Extension.UUID, duration, false, "", b.options.Mode})
 	}
 }
 
-// development build
-func (b *Builder) Develop(ctx context.Context, yield func(result Result)) {
-	err := b.RunScript(ctx, "develop")
+func (b *Builder) buildAndSwap(ctx context.Context) error {
+	buildDir := filepath.Join(b.Extension.Development.RootDir, b.Extension.Development.BuildDir)
 
+	tmpDir, err := os.MkdirTemp(filepath.Dir(buildDir), "."+filepath.Base(buildDir)+"-")
 	if err != nil {
-		yield(Result{false, err, b.Extension.UUID})
+		return fmt.Errorf("unable to create temporary build directory: %w", err)
 	}
-}
+	defer os.RemoveAll(tmpDir)
 
-func (b *Builder) Watch(ctx context.Context, yield func(result Result)) {
-	watcher, err := fsnotify.NewWatcher()
+	absTmpDir, err := filepath.Abs(tmpDir)
 	if err != nil {
-		yield(Result{false, err, b.Extension.UUID})
+		return err
 	}
-	defer watcher.Close()
 
-	watch_dir := filepath.Join(".", b.Extension.Development.RootDir, b.Extension.Development.BuildDir)
-	if err = watcher.Add(watch_dir); err != nil {
-		yield(Result{false, err, b.Extension.UUID})
+	runner := b.ScriptRunner
+	isolatedDir := ""
+	if b.Extension.Development.IsolatedBuild {
+		dirRunner, ok := runner.(DirScriptRunner)
+		if !ok {
+			return fmt.Errorf("isolated builds aren't supported by %T", runner)
+		}
+		if isolatedDir, err = b.isolate(); err != nil {
+			return err
+		}
+		defer os.RemoveAll(isolatedDir)
+
+		env, err := isolatedCacheEnv(isolatedDir)
+		if err != nil {
+			return err
+		}
+		runner = dirRunner.InDir(filepath.Join(isolatedDir, b.Extension.Development.BuildDir), env)
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Terminating watcher")
-			yield(Result{true, nil, b.Extension.UUID})
-		case event := <-watcher.Events:
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				log.Printf("file system event: %v\n", event)
-				yield(Result{true, nil, b.Extension.UUID})
+	if err = runner.RunScript(ctx, "build", "--build-dir", absTmpDir); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if (err != nil || len(entries) == 0) && isolatedDir != "" {
+		// The build script built in place, in the isolated copy
+		if err = copyTree(filepath.Join(isolatedDir, b.Extension.Development.BuildDir), tmpDir, ""); err != nil {
+			return err
+		}
+		entries, err = os.ReadDir(tmpDir)
+	}
+	if err != nil || len(entries) == 0 {
+		// The build script doesn't support --build-dir and built in place
+		return nil
+	}
+
+	if err := moveIntoPlace(tmpDir, buildDir); err != nil {
+		return fmt.Errorf("unable to replace build directory: %w", err)
+	}
+	return nil
+}
+
+// moveIntoPlace moves the files of sourceDir into targetDir and removes the
+// files of targetDir that sourceDir doesn't have. Each file is replaced by a
+// rename, which is atomic as both directories share the same parent, so a
+// concurrent request gets either the previous or the new file. targetDir
+// itself is kept, it never goes missing and watches on it stay in place.
+func moveIntoPlace(sourceDir, targetDir string) error {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+
+	built := make(map[string]bool)
+	err := filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == sourceDir {
+			return err
+		}
+
+		name, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		built[name] = true
+
+		target := filepath.Join(targetDir, name)
+		existing, err := os.Lstat(target)
+		if err == nil && existing.IsDir() != entry.IsDir() {
+			if err := os.RemoveAll(target); err != nil {
+				return err
 			}
-		case err = <-watcher.Errors:
-			log.Printf("file system error: %v\n", err)
-			yield(Result{false, err, b.Extension.UUID})
 		}
+
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		// The directory was already read, moving its files doesn't affect the walk
+		return os.Rename(path, target)
+	})
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(targetDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == targetDir {
+			return err
+		}
+
+		name, err := filepath.Rel(targetDir, path)
+		if err != nil {
+			return err
+		}
+		if built[name] {
+			return nil
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// development build
+func (b *Builder) Develop(ctx context.Context, yield func(result Result)) {
+	err := b.checkNodeVersion(ctx)
+	if err == nil {
+		err = b.RunScript(ctx, "develop")
+	}
+
+	if err != nil {
+		yield(Result{false, err, b.Extension.UUID, 0, false, "", b.options.Mode})
 	}
 }
 
@@ -0,0 +1,84 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// DefaultBuildDir is used when neither the configuration nor a bundler
+// configuration names the build directory
+const DefaultBuildDir = "build"
+
+// bundlerConfig is a bundler configuration file the build directory can be
+// read from. The files are matched with patterns rather than evaluated, so
+// only literal paths are recognized.
+type bundlerConfig struct {
+	files []string
+	// outDir matches the build directory in its first group
+	outDir *regexp.Regexp
+	// defaultOutDir is where the bundler writes without an explicit setting
+	defaultOutDir string
+}
+
+var bundlerConfigs = []bundlerConfig{
+	{
+		// build: { outDir: "dist" }
+		[]string{"vite.config.js", "vite.config.ts", "vite.config.mjs", "vite.config.cjs"},
+		regexp.MustCompile(`outDir\s*:\s*["'\x60]([^"'\x60]+)["'\x60]`),
+		"dist",
+	},
+	{
+		// output: { path: path.resolve(__dirname, "dist") }
+		[]string{"webpack.config.js", "webpack.config.ts", "webpack.config.mjs", "webpack.config.cjs"},
+		regexp.MustCompile(`(?s)output\s*:\s*\{.*?path\s*:\s*(?:path\.(?:resolve|join)\(\s*__dirname\s*,\s*)?["'\x60]([^"'\x60]+)["'\x60]`),
+		"dist",
+	},
+}
+
+// DetectBuildDir reads the build directory from the configuration of a
+// recognized bundler in the root directory, see bundlerConfigs. It returns
+// DefaultBuildDir when there is none, and ignores directories outside of the
+// root directory.
+func DetectBuildDir(rootDir string) string {
+	for _, bundler := range bundlerConfigs {
+		for _, file := range bundler.files {
+			content, err := os.ReadFile(filepath.Join(rootDir, file))
+			if err != nil {
+				continue
+			}
+
+			match := bundler.outDir.FindSubmatch(content)
+			if match == nil {
+				return bundler.defaultOutDir
+			}
+
+			outDir := filepath.Clean(filepath.FromSlash(string(match[1])))
+			if filepath.IsAbs(outDir) || outDir == "." || outDir == ".." || strings.HasPrefix(outDir, ".."+string(filepath.Separator)) {
+				continue
+			}
+			return outDir
+		}
+	}
+	return DefaultBuildDir
+}
+
+// ResolveBuildDirs sets the build directory of extensions without a
+// build_dir, see DetectBuildDir
+func ResolveBuildDirs(config *core.Config) {
+	resolve := func(extensions []core.Extension) {
+		for index := range extensions {
+			if development := &extensions[index].Development; development.BuildDir == "" {
+				development.BuildDir = DetectBuildDir(development.RootDir)
+			}
+		}
+	}
+
+	resolve(config.Extensions)
+	for _, app := range config.Apps {
+		resolve(app.Extensions)
+	}
+}
@@ -0,0 +1,65 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func TestDetectBuildDir(t *testing.T) {
+	tests := []struct {
+		file     string
+		content  string
+		expected string
+	}{
+		{"", "", "build"},
+		{"vite.config.ts", "export default defineConfig({\n  build: {\n    outDir: 'public/dist',\n  },\n})\n", filepath.Join("public", "dist")},
+		{"vite.config.js", "export default { plugins: [react()] }\n", "dist"},
+		{"webpack.config.js", "module.exports = {\n  entry: './src/index.js',\n  output: {\n    filename: '[name].js',\n    path: path.resolve(__dirname, \"out\"),\n  },\n}\n", "out"},
+		{"webpack.config.cjs", "module.exports = { output: { path: 'bundle' } }\n", "bundle"},
+		{"webpack.config.js", "module.exports = { output: { path: path.resolve(__dirname, '../shared') } }\n", "build"},
+	}
+
+	for _, test := range tests {
+		rootDir := t.TempDir()
+		if test.file != "" {
+			if err := os.WriteFile(filepath.Join(rootDir, test.file), []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if buildDir := DetectBuildDir(rootDir); buildDir != test.expected {
+			t.Errorf("Expected build directory %s for %s, got %s", test.expected, test.file, buildDir)
+		}
+	}
+}
+
+func TestResolveBuildDirs(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootDir, "vite.config.js"), []byte("export default { build: { outDir: \"dist\" } }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &core.Config{
+		Extensions: []core.Extension{
+			{UUID: "1", Development: core.Development{RootDir: rootDir}},
+			{UUID: "2", Development: core.Development{RootDir: rootDir, BuildDir: "configured"}},
+		},
+		Apps: []core.AppConfig{
+			{Name: "app", Extensions: []core.Extension{{UUID: "3", Development: core.Development{RootDir: rootDir}}}},
+		},
+	}
+	ResolveBuildDirs(config)
+
+	for _, extension := range config.AllExtensions() {
+		expected := "dist"
+		if extension.UUID == "2" {
+			expected = "configured"
+		}
+		if extension.Development.BuildDir != expected {
+			t.Errorf("Expected build directory %s for extension %s, got %s", expected, extension.UUID, extension.Development.BuildDir)
+		}
+	}
+}
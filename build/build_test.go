@@ -1,10 +1,14 @@
 package build
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -41,13 +45,14 @@ func TestBuild(t *testing.T) {
 		}
 
 		runnerWasCalled = true
+		time.Sleep(10 * time.Millisecond)
 		return nil
 	}
 
 	var wg sync.WaitGroup
 	wg.Add(1)
 
-	builder := Builder{ScriptRunnerFunc(fakeRunner), config.Extensions[0]}
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: config.Extensions[0]}
 	builder.Build(context.TODO(), func(result Result) {
 		if !result.Success {
 			t.Error("Expected Build operation to be successful")
@@ -56,6 +61,10 @@ func TestBuild(t *testing.T) {
 		if result.Error != nil {
 			t.Errorf("Expected error to be nil, got %s", result.Error)
 		}
+
+		if result.Duration < 10*time.Millisecond {
+			t.Errorf("Expected the build duration to be recorded, got %s", result.Duration)
+		}
 	})
 
 	if !runnerWasCalled {
@@ -63,6 +72,184 @@ func TestBuild(t *testing.T) {
 	}
 }
 
+func TestNewBuilderWithOutput(t *testing.T) {
+	var output strings.Builder
+
+	builder := NewBuilder(config.Extensions[0], Options{Output: &output})
+	pm := builder.ScriptRunner.(*PackageManager)
+
+	if pm.stdout != &output || pm.stderr != &output {
+		t.Error("Expected the output of build scripts to be redirected")
+	}
+}
+
+func TestBuildReplacesBuildDirectory(t *testing.T) {
+	rootDir := t.TempDir()
+	buildDir := filepath.Join(rootDir, "build")
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(buildDir, "stale.js"), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		if len(args) != 2 || args[0] != "--build-dir" {
+			t.Fatalf("Expected build to be pointed at a temporary directory, got %v", args)
+		}
+
+		if _, err := os.Stat(filepath.Join(buildDir, "stale.js")); err != nil {
+			t.Error("Expected the previous build to be served while building")
+		}
+
+		return os.WriteFile(filepath.Join(args[1], "main.js"), []byte("fresh"), 0644)
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension}
+	builder.Build(context.TODO(), func(result Result) {
+		if !result.Success {
+			t.Errorf("Expected Build operation to be successful, got %v", result.Error)
+		}
+	})
+
+	if content, err := os.ReadFile(filepath.Join(buildDir, "main.js")); err != nil || string(content) != "fresh" {
+		t.Errorf("Expected build directory to contain the new build, got %q, %v", content, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(buildDir, "stale.js")); !os.IsNotExist(err) {
+		t.Error("Expected previous build to be replaced")
+	}
+
+	if entries, _ := os.ReadDir(rootDir); len(entries) != 1 {
+		t.Errorf("Expected temporary directories to be cleaned up, got %d entries", len(entries))
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	rootDir := t.TempDir()
+	source := filepath.Join(rootDir, "src", "index.js")
+	if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(source, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	builds := 0
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		builds++
+		return os.WriteFile(filepath.Join(args[1], "main.js"), []byte("built"), 0644)
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+
+	build := func(builder Builder) (cached bool) {
+		builder.Build(context.TODO(), func(result Result) {
+			if !result.Success {
+				t.Errorf("Expected Build operation to be successful, got %v", result.Error)
+			}
+			cached = result.Cached
+		})
+		return
+	}
+
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension, cache: true}
+
+	if build(builder) || builds != 1 {
+		t.Fatalf("Expected the first build to run, got %d builds", builds)
+	}
+
+	if !build(builder) || builds != 1 {
+		t.Errorf("Expected unchanged sources to be cached, got %d builds", builds)
+	}
+
+	if err := os.WriteFile(source, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if build(builder) || builds != 2 {
+		t.Errorf("Expected changed sources to be built, got %d builds", builds)
+	}
+
+	builder.forceBuild = true
+	if build(builder) || builds != 3 {
+		t.Errorf("Expected a forced build to run, got %d builds", builds)
+	}
+
+	builder.forceBuild = false
+	builder.options.Mode = Production
+	if build(builder) || builds != 4 {
+		t.Errorf("Expected a development build not to be reused in production mode, got %d builds", builds)
+	}
+	if !build(builder) || builds != 4 {
+		t.Errorf("Expected the production build to be cached, got %d builds", builds)
+	}
+
+	if err := os.WriteFile(filepath.Join(rootDir, ".babelrc"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if build(builder) || builds != 5 {
+		t.Errorf("Expected a changed configuration dotfile to be built, got %d builds", builds)
+	}
+
+	if err := os.MkdirAll(filepath.Join(rootDir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, ".git", "HEAD"), []byte("ref"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !build(builder) || builds != 5 {
+		t.Errorf("Expected changes of .git to be ignored, got %d builds", builds)
+	}
+}
+
+func TestNewBuilderMode(t *testing.T) {
+	options := Options{Env: []string{"JOBS=1"}}
+
+	inherited := NewBuilder(config.Extensions[0], options)
+	production := NewBuilder(config.Extensions[0], Options{Env: options.Env, Mode: Production})
+
+	if env := inherited.ScriptRunner.(*PackageManager).env; len(env) != 1 {
+		t.Errorf("Expected builds without a mode to inherit NODE_ENV, got %v", env)
+	}
+	if inherited.options.Mode != "" {
+		t.Errorf("Expected no mode to be recorded, got %q", inherited.options.Mode)
+	}
+	if env := production.ScriptRunner.(*PackageManager).env; env[len(env)-1] != "NODE_ENV=production" {
+		t.Errorf("Expected NODE_ENV=production, got %v", env)
+	}
+	if len(options.Env) != 1 {
+		t.Errorf("Expected the shared environment to be left alone, got %v", options.Env)
+	}
+
+	if _, err := ParseMode("staging"); err == nil {
+		t.Error("Expected unsupported modes to be rejected")
+	}
+}
+
+func TestConcurrencyEnv(t *testing.T) {
+	tests := []struct {
+		budget     int
+		extensions int
+		expected   string
+	}{
+		{8, 2, "JOBS=4"},
+		{8, 3, "JOBS=2"},
+		{2, 4, "JOBS=1"},
+		{4, 0, "JOBS=1"},
+	}
+
+	for _, test := range tests {
+		env := ConcurrencyEnv(test.budget, test.extensions)
+		if env[0] != test.expected {
+			t.Errorf("budget %d for %d extensions: expected %s, got %s", test.budget, test.extensions, test.expected, env[0])
+		}
+	}
+}
+
 func TestBuildErrors(t *testing.T) {
 	fakeRunner := func(ctx context.Context, script string, args ...string) error {
 		return errors.New("Error")
@@ -71,7 +258,7 @@ func TestBuildErrors(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(1)
 
-	builder := Builder{ScriptRunnerFunc(fakeRunner), config.Extensions[0]}
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: config.Extensions[0]}
 	builder.Build(context.TODO(), func(result Result) {
 		if result.Success {
 			t.Error("Expected Build operation to fail with errors")
@@ -83,6 +270,120 @@ func TestBuildErrors(t *testing.T) {
 	})
 }
 
+func TestBuildHooks(t *testing.T) {
+	steps := make([]string, 0)
+	defer func(original func(context.Context, string, string, Options) error) { runHook = original }(runHook)
+	runHook = func(ctx context.Context, dir string, command string, options Options) error {
+		steps = append(steps, command)
+		if command == "exit 1" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		steps = append(steps, script)
+		return nil
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.PreBuild = []string{"generate-types", "copy-locales"}
+	extension.Development.PostBuild = []string{"report-size"}
+
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension}
+	builder.Build(context.TODO(), func(result Result) {
+		if !result.Success {
+			t.Errorf("Expected build to succeed, got %v", result.Error)
+		}
+	})
+
+	if strings.Join(steps, ",") != "generate-types,copy-locales,build,report-size" {
+		t.Errorf("Expected the hooks to run around the build, got %v", steps)
+	}
+
+	steps = steps[:0]
+	extension.Development.PreBuild = []string{"exit 1", "copy-locales"}
+	builder.Extension = extension
+	builder.Build(context.TODO(), func(result Result) {
+		if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), `pre_build command "exit 1" failed`) {
+			t.Errorf("Expected a failing pre_build command to fail the build, got %v", result.Error)
+		}
+	})
+
+	if strings.Join(steps, ",") != "exit 1" {
+		t.Errorf("Expected the build to stop at the failing command, got %v", steps)
+	}
+}
+
+func TestBuildStepTimeouts(t *testing.T) {
+	defer func(original func(context.Context, string, string, Options) error) { runHook = original }(runHook)
+	runHook = func(ctx context.Context, dir string, command string, options Options) error {
+		<-ctx.Done()
+		return errors.New("signal: killed")
+	}
+	built := false
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		built = true
+		return nil
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.PreBuild = []string{"sleep 60"}
+	extension.Development.Timeouts.PreBuild = 10 * time.Millisecond
+
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension}
+	builder.Build(context.TODO(), func(result Result) {
+		if result.Success || result.Error == nil || result.Error.Error() != "pre_build step timed out after 10ms" {
+			t.Errorf("Expected the pre_build step to time out, got %v", result.Error)
+		}
+	})
+
+	if built {
+		t.Error("Expected the build to stop after the timed out step")
+	}
+}
+
+type fakeTracer struct {
+	parent  *core.TraceParent
+	results []Result
+}
+
+func (tracer *fakeTracer) StartBuild(ctx context.Context, extension core.Extension, parent *core.TraceParent) BuildSpan {
+	tracer.parent = parent
+	return tracer
+}
+
+func (tracer *fakeTracer) End(result Result) {
+	tracer.results = append(tracer.results, result)
+}
+
+func TestBuildTracing(t *testing.T) {
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		return nil
+	}
+
+	traceParent := TraceParentFromEnv(func(string) string {
+		return "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	})
+	if traceParent == nil {
+		t.Fatal("Expected the TRACEPARENT of the environment to be parsed")
+	}
+
+	tracer := &fakeTracer{}
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: config.Extensions[0], options: Options{Tracer: tracer, TraceParent: traceParent}}
+	builder.Build(context.TODO(), func(result Result) {
+		if len(tracer.results) != 1 {
+			t.Error("Expected the span to end before the result is yielded")
+		}
+	})
+
+	if tracer.parent != traceParent {
+		t.Errorf("Expected the build span to be a child of the TRACEPARENT, got %v", tracer.parent)
+	}
+	if len(tracer.results) != 1 || !tracer.results[0].Success {
+		t.Errorf("Expected the span to end with the result of the build, got %+v", tracer.results)
+	}
+}
+
 func TestDevelop(t *testing.T) {
 	fakeRunner := func(ctx context.Context, script string, args ...string) error {
 		if script != "develop" {
@@ -91,7 +392,7 @@ func TestDevelop(t *testing.T) {
 		return nil
 	}
 
-	builder := Builder{ScriptRunnerFunc(fakeRunner), config.Extensions[0]}
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: config.Extensions[0]}
 
 	builder.Develop(context.TODO(), func(result Result) {
 		if !result.Success {
@@ -104,12 +405,147 @@ func TestDevelop(t *testing.T) {
 	})
 }
 
+func TestWatchReportsChangedAssets(t *testing.T) {
+	rootDir := t.TempDir()
+	for _, dir := range []string{"src/components", "build/chunks"} {
+		if err := os.MkdirAll(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+	extension.Development.BuildDir = "build"
+	extension.Development.Entries = map[string]string{"main": "src/index.js"}
+	builder := Builder{Extension: extension}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan Result, 10)
+	go builder.Watch(ctx, func(result Result) {
+		results <- result
+	})
+	// Give the watcher time to register the directories
+	time.Sleep(50 * time.Millisecond)
+
+	write := func(name string) Result {
+		if err := os.WriteFile(filepath.Join(rootDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case result := <-results:
+			// Creating a file is reported along with writing it
+			time.Sleep(20 * time.Millisecond)
+			for len(results) > 0 {
+				<-results
+			}
+			return result
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a watch event for %s", name)
+		}
+		return Result{}
+	}
+
+	if result := write("build/main.css"); !result.Success || result.Asset != "main.css" {
+		t.Errorf("Expected a change of the build directory to report the asset, got %+v", result)
+	}
+
+	if result := write("build/chunks/1.js"); !result.Success || result.Asset != "chunks/1.js" {
+		t.Errorf("Expected a nested asset to be reported relative to the build directory, got %+v", result)
+	}
+
+	if err := os.WriteFile(filepath.Join(rootDir, "src", "components", "Button.js"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The rebuild takes longer than the build output needs to settle
+	time.Sleep(settleDelay + 200*time.Millisecond)
+	if result := write("build/main.js"); !result.Success || result.Asset != "" {
+		t.Errorf("Expected a rebuild after a nested source change to be a full update, got %+v", result)
+	}
+
+	time.Sleep(settleDelay + 200*time.Millisecond)
+	if result := write("build/main.css"); !result.Success || result.Asset != "main.css" {
+		t.Errorf("Expected changes after the rebuild settled to report the asset, got %+v", result)
+	}
+}
+
+func TestWatchWhileBuilding(t *testing.T) {
+	rootDir := t.TempDir()
+	buildDir := filepath.Join(rootDir, "build")
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+	extension.Development.BuildDir = "build"
+	extension.Development.Entries = map[string]string{}
+
+	var missing bool
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		return os.WriteFile(filepath.Join(args[1], "main.js"), []byte("built"), 0644)
+	}
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan Result, 10)
+	go builder.Watch(ctx, func(result Result) {
+		results <- result
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	for build := 1; build <= 2; build++ {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := os.Stat(buildDir); err != nil {
+					missing = true
+				}
+			}
+		}()
+
+		builder.Build(context.TODO(), func(result Result) {
+			if !result.Success {
+				t.Errorf("Expected Build operation to be successful, got %v", result.Error)
+			}
+		})
+		done <- struct{}{}
+
+		select {
+		case result := <-results:
+			if !result.Success || result.Asset != "" {
+				t.Errorf("Expected a successful watch event for the whole build, got %+v", result)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the watcher to notice build %d", build)
+		}
+		// Drain the events of the cache file
+		time.Sleep(50 * time.Millisecond)
+		for len(results) > 0 {
+			<-results
+		}
+	}
+
+	if missing {
+		t.Error("Expected the build directory to exist throughout the builds")
+	}
+}
+
 func TestWatch(t *testing.T) {
 	fakeRunner := func(ctx context.Context, script string, args ...string) error {
 		return nil
 	}
 
-	builder := Builder{ScriptRunnerFunc(fakeRunner), config.Extensions[0]}
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: config.Extensions[0]}
 
 	d := time.Now().Add(5 * time.Millisecond)
 	ctx, cancel := context.WithDeadline(context.Background(), d)
@@ -121,3 +557,32 @@ func TestWatch(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildStepTimeoutKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook uses sh")
+	}
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		return nil
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = t.TempDir()
+	// The shell waits for sleep, which keeps the output pipe open unless it
+	// is killed along with the shell
+	extension.Development.PreBuild = []string{"sleep 60; echo done"}
+	extension.Development.Timeouts.PreBuild = 100 * time.Millisecond
+
+	output := bytes.Buffer{}
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension, options: Options{Output: &output}}
+	start := time.Now()
+	builder.Build(context.TODO(), func(result Result) {
+		if result.Success || result.Error == nil || result.Error.Error() != "pre_build step timed out after 100ms" {
+			t.Errorf("Expected the pre_build step to time out, got %v", result.Error)
+		}
+	})
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the timed out hook to be killed right away, took %s", elapsed)
+	}
+}
@@ -0,0 +1,124 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cacheFile is written to the build directory after a successful production
+// build and holds the hash of the sources that were built
+const cacheFile = ".shopify-build-cache"
+
+// ignoredSources never affect the build output. The cache file is part of
+// the output, it's among the sources when building into the root directory.
+var ignoredSources = map[string]bool{".git": true, "node_modules": true, cacheFile: true}
+
+// hashSources hashes the paths and contents of the source files of an
+// extension, see walkSources
+func hashSources(rootDir, buildDir string) (string, error) {
+	hash := sha256.New()
+
+	err := walkSources(rootDir, buildDir, func(path, name string, entry fs.DirEntry) error {
+		io.WriteString(hash, filepath.ToSlash(name)+"\x00")
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(hash, file)
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// walkSources calls visit with the path and the name relative to the root
+// directory of each source file of an extension. .git, node_modules, the
+// build directory, its temporary siblings and isolated build copies are
+// ignored. Other hidden files, e.g. .babelrc or .env, affect the build
+// output and are sources.
+func walkSources(rootDir, buildDir string, visit func(path, name string, entry fs.DirEntry) error) error {
+	absBuildDir, err := filepath.Abs(buildDir)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(rootDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != rootDir && ignoredSource(entry.Name(), buildDir) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() {
+			if absPath, err := filepath.Abs(path); err == nil && path != rootDir && absPath == absBuildDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		return visit(path, name, entry)
+	})
+}
+
+// ignoredSource reports whether files or directories with the name are left
+// out of the sources, see walkSources
+func ignoredSource(name, buildDir string) bool {
+	return ignoredSources[name] || strings.HasPrefix(name, "."+filepath.Base(buildDir)+"-") || strings.HasPrefix(name, isolatedDirPrefix)
+}
+
+// isCached checks the hash of the sources against the one recorded by the
+// last successful build. The hash is returned to be recorded once built.
+func (b *Builder) isCached(buildDir string) (hash string, cached bool) {
+	if !b.cache {
+		return "", false
+	}
+
+	hash, err := hashSources(b.Extension.Development.RootDir, buildDir)
+	if err != nil {
+		return "", false
+	}
+	// Builds of other modes don't produce the same output. Builds without a
+	// mode and development builds keep the plain hash, which was recorded
+	// before modes existed.
+	if b.options.Mode != "" && b.options.Mode != Development {
+		hash += " " + string(b.options.Mode)
+	}
+
+	if b.forceBuild {
+		return hash, false
+	}
+
+	recorded, err := os.ReadFile(filepath.Join(buildDir, cacheFile))
+	return hash, err == nil && strings.TrimSpace(string(recorded)) == hash
+}
+
+func writeCache(buildDir, hash string) error {
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(buildDir, cacheFile), []byte(hash+"\n"), 0644)
+}
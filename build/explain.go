@@ -0,0 +1,76 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretName matches environment variables whose values Explain masks
+var secretName = regexp.MustCompile(`(?i)token|secret|password|passwd|credential|auth|key`)
+
+// Explain describes what Build would run for the extension without running
+// anything: the commands, their working directory and the environment the
+// build script receives, with secrets masked.
+func (b *Builder) Explain(w io.Writer) error {
+	pm, ok := b.ScriptRunner.(*PackageManager)
+	if !ok {
+		return fmt.Errorf("unable to explain the build of extension %s, it isn't run by a package manager", b.Extension.UUID)
+	}
+
+	// The temporary build directory is only created when building
+	cmd := pm.command("build", "--build-dir", "<temporary build directory>")
+	development := b.Extension.Development
+
+	fmt.Fprintf(w, "Extension %s:\n", b.Extension.UUID)
+	for _, command := range development.PreBuild {
+		fmt.Fprintf(w, "  pre_build:  %s (in %s)\n", command, displayDir(development.RootDir))
+	}
+	fmt.Fprintf(w, "  command:    %s (in %s)\n", strings.Join(cmd.Args, " "), displayDir(cmd.Dir))
+	for _, command := range development.PostBuild {
+		fmt.Fprintf(w, "  post_build: %s (in %s)\n", command, displayDir(development.RootDir))
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	fmt.Fprintln(w, "  environment:")
+	for _, variable := range maskSecrets(env) {
+		fmt.Fprintf(w, "    %s\n", variable)
+	}
+	return nil
+}
+
+// maskSecrets sorts the environment variables and replaces the values of
+// variables that look like secrets. Later duplicates win, as with exec.Cmd.
+func maskSecrets(env []string) []string {
+	values := make(map[string]string, len(env))
+	for _, variable := range env {
+		name, value := variable, ""
+		if index := strings.Index(variable, "="); index >= 0 {
+			name, value = variable[:index], variable[index+1:]
+		}
+		if value != "" && secretName.MatchString(name) {
+			value = "****"
+		}
+		values[name] = value
+	}
+
+	masked := make([]string, 0, len(values))
+	for name, value := range values {
+		masked = append(masked, name+"="+value)
+	}
+	sort.Strings(masked)
+	return masked
+}
+
+func displayDir(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
@@ -0,0 +1,42 @@
+package build
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	os.Setenv("SHOPIFY_API_TOKEN", "shpat_secret")
+	defer os.Unsetenv("SHOPIFY_API_TOKEN")
+
+	extension := config.Extensions[0]
+	extension.Development.PreBuild = []string{"npm run generate-types"}
+
+	pm := yarn("testdata/build")
+	pm.env = []string{"JOBS=4", "NPM_AUTH_TOKEN=npm_secret"}
+	builder := Builder{ScriptRunner: pm, Extension: extension}
+
+	var output strings.Builder
+	if err := builder.Explain(&output); err != nil {
+		t.Fatal(err)
+	}
+
+	explanation := output.String()
+	for _, expected := range []string{
+		"Extension " + extension.UUID + ":\n",
+		"  pre_build:  npm run generate-types (in " + extension.Development.RootDir + ")\n",
+		"  command:    yarn build --build-dir <temporary build directory> (in testdata/build)\n",
+		"    JOBS=4\n",
+		"    NPM_AUTH_TOKEN=****\n",
+		"    SHOPIFY_API_TOKEN=****\n",
+	} {
+		if !strings.Contains(explanation, expected) {
+			t.Errorf("Expected explanation to contain %q, got:\n%s", expected, explanation)
+		}
+	}
+
+	if strings.Contains(explanation, "secret") {
+		t.Errorf("Expected secrets to be masked, got:\n%s", explanation)
+	}
+}
@@ -0,0 +1,43 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// runHook runs a pre_build or post_build command with the shell, in the root
+// directory of the extension
+var runHook = func(ctx context.Context, dir string, command string, options Options) error {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	cmd := exec.Command(shell, flag, command)
+	cmd.Dir = dir
+	if len(options.Env) > 0 {
+		cmd.Env = append(os.Environ(), options.Env...)
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if options.Output != nil {
+		cmd.Stdout, cmd.Stderr = options.Output, options.Output
+	}
+	return runCommand(ctx, cmd)
+}
+
+// runHooks runs the commands in order and stops at the first failure or once
+// the context is cancelled
+func (b *Builder) runHooks(ctx context.Context, stage string, commands []string) error {
+	for _, command := range commands {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := runHook(ctx, b.Extension.Development.RootDir, command, b.options); err != nil {
+			return fmt.Errorf("%s command %q failed: %w", stage, command, err)
+		}
+	}
+	return nil
+}
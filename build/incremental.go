@@ -0,0 +1,86 @@
+package build
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// errSourceModified stops walking the sources once a modified one was found
+var errSourceModified = errors.New("source modified after the build")
+
+// OutdatedExtensions returns the UUIDs of the extensions with a source file
+// modified after the newest file of their build directory, or without build
+// output, and of the extensions depending on them. Sources are the files
+// walkSources visits. Unlike ChangedSince and the build cache, it only
+// compares modification times and needs neither git nor a cache file. The
+// extensions have to be sorted by their dependencies.
+func OutdatedExtensions(extensions []core.Extension) (map[string]bool, error) {
+	outdated := make(map[string]bool, len(extensions))
+	for _, extension := range extensions {
+		rootDir := extension.Development.RootDir
+		buildDir := filepath.Join(rootDir, extension.Development.BuildDir)
+
+		built, err := newestModTime(buildDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if built.IsZero() {
+			outdated[extension.UUID] = true
+		} else {
+			err = walkSources(rootDir, buildDir, func(path, name string, entry fs.DirEntry) error {
+				info, err := entry.Info()
+				if err != nil {
+					return err
+				}
+				if info.ModTime().After(built) {
+					return errSourceModified
+				}
+				return nil
+			})
+			if err == errSourceModified {
+				outdated[extension.UUID] = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, dependency := range extension.DependsOn {
+			if outdated[dependency] {
+				outdated[extension.UUID] = true
+			}
+		}
+	}
+	return outdated, nil
+}
+
+// newestModTime returns the modification time of the newest file in a
+// directory, the zero time when there are no files
+func newestModTime(dir string) (time.Time, error) {
+	var newest time.Time
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return newest, err
+}
@@ -0,0 +1,68 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func TestOutdatedExtensions(t *testing.T) {
+	rootDir := t.TempDir()
+	built := time.Now().Add(-time.Hour)
+
+	writeFile := func(name string, modified time.Time) {
+		path := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("unchanged/src/index.js", built.Add(-time.Minute))
+	writeFile("unchanged/build/main.js", built)
+	// Ignored like by the build cache
+	writeFile("unchanged/node_modules/react/index.js", built.Add(time.Minute))
+	writeFile("unchanged/.git/index", built.Add(time.Minute))
+
+	writeFile("changed/src/index.js", built.Add(time.Minute))
+	writeFile("changed/build/main.js", built)
+
+	writeFile("unbuilt/src/index.js", built.Add(-time.Minute))
+
+	writeFile("dependent/src/index.js", built.Add(-time.Minute))
+	writeFile("dependent/build/main.js", built)
+
+	extension := func(uuid string, dependsOn ...string) core.Extension {
+		return core.Extension{
+			UUID:        uuid,
+			DependsOn:   dependsOn,
+			Development: core.Development{RootDir: filepath.Join(rootDir, uuid), BuildDir: "build"},
+		}
+	}
+	extensions := []core.Extension{
+		extension("unchanged"),
+		extension("changed"),
+		extension("unbuilt"),
+		extension("dependent", "changed"),
+	}
+
+	outdated, err := OutdatedExtensions(extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{"changed": true, "unbuilt": true, "dependent": true}
+	for _, extension := range extensions {
+		if outdated[extension.UUID] != expected[extension.UUID] {
+			t.Errorf("Expected extension %s to be outdated: %v", extension.UUID, expected[extension.UUID])
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isolatedDirPrefix names the copies of the root directory that isolated
+// builds run in. They are created inside the root directory, so that
+// dependencies hoisted to a parent directory still resolve, and are hidden,
+// so that they don't count as sources.
+const isolatedDirPrefix = ".shopify-isolated-build-"
+
+// isolatedCacheDir is the directory of the isolated copy that build tools
+// are pointed at for their caches, see isolatedCacheEnv
+const isolatedCacheDir = ".cache"
+
+// DirScriptRunner is implemented by script runners that can run the scripts
+// in another working directory, which isolated builds require
+type DirScriptRunner interface {
+	ScriptRunner
+	// InDir returns a runner running the scripts in dir with env added to
+	// their environment
+	InDir(dir string, env []string) ScriptRunner
+}
+
+// InDir returns a copy of the package manager running scripts in dir
+func (pm *PackageManager) InDir(dir string, env []string) ScriptRunner {
+	runner := *pm
+	runner.workingDir = dir
+	runner.env = append(append([]string{}, pm.env...), env...)
+	return &runner
+}
+
+// isolatedCacheEnv points the caches of build tools into the isolated copy.
+// Tools resolving their cache through find-cache-dir, e.g. babel-loader and
+// terser-webpack-plugin, honour CACHE_DIR, others XDG_CACHE_HOME. Without
+// them, caches would end up in the node_modules of a parent directory, which
+// is shared with other builds.
+func isolatedCacheEnv(isolatedDir string) ([]string, error) {
+	cacheDir, err := filepath.Abs(filepath.Join(isolatedDir, isolatedCacheDir))
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"CACHE_DIR=" + cacheDir,
+		"XDG_CACHE_HOME=" + cacheDir,
+		"BABEL_CACHE_PATH=" + filepath.Join(cacheDir, "babel.json"),
+	}, nil
+}
+
+// isolate copies the root directory of the extension, except for its build
+// directory, to run the build script in. Build tools writing caches into the
+// source tree, e.g. node_modules/.cache, then don't interfere with concurrent
+// builds of extensions sharing the directory. The copy has to be removed once
+// the build finished.
+func (b *Builder) isolate() (string, error) {
+	rootDir := b.Extension.Development.RootDir
+	if rootDir == "" {
+		rootDir = "."
+	}
+	buildDir := filepath.Join(rootDir, b.Extension.Development.BuildDir)
+
+	isolatedDir, err := os.MkdirTemp(rootDir, isolatedDirPrefix)
+	if err != nil {
+		return "", fmt.Errorf("unable to create isolated build directory: %w", err)
+	}
+
+	if err := copyTree(rootDir, isolatedDir, buildDir); err != nil {
+		os.RemoveAll(isolatedDir)
+		return "", fmt.Errorf("unable to copy extension for isolated build: %w", err)
+	}
+
+	// The build script runs in the build directory, which isn't copied
+	if err := os.MkdirAll(filepath.Join(isolatedDir, b.Extension.Development.BuildDir), 0755); err != nil {
+		os.RemoveAll(isolatedDir)
+		return "", err
+	}
+	return isolatedDir, nil
+}
+
+// copyTree copies the files, directories and symlinks in sourceDir to
+// targetDir. The build directory, its temporary siblings, other isolated
+// copies, .git and build tool caches in node_modules/.cache are skipped.
+// Relative symlinks pointing outside of sourceDir, e.g. workspace packages
+// linked into node_modules, are made absolute, since they'd point elsewhere
+// from targetDir.
+func copyTree(sourceDir, targetDir, buildDir string) error {
+	absBuildDir, err := filepath.Abs(buildDir)
+	if err != nil {
+		return err
+	}
+	absTargetDir, err := filepath.Abs(targetDir)
+	if err != nil {
+		return err
+	}
+	absSourceDir, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return err
+	}
+	tmpBuildDirPrefix := "." + filepath.Base(buildDir) + "-"
+
+	return filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == sourceDir {
+			return nil
+		}
+
+		name, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() && (absPath == absBuildDir || absPath == absTargetDir ||
+			entry.Name() == ".git" ||
+			strings.HasPrefix(entry.Name(), isolatedDirPrefix) ||
+			strings.HasPrefix(entry.Name(), tmpBuildDirPrefix) ||
+			filepath.ToSlash(name) == "node_modules/.cache") {
+			return filepath.SkipDir
+		}
+
+		target := filepath.Join(targetDir, name)
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, 0755)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if !filepath.IsAbs(link) {
+				linked := filepath.Join(filepath.Dir(absPath), link)
+				if linked != absSourceDir && !strings.HasPrefix(linked, absSourceDir+string(filepath.Separator)) {
+					link = linked
+				}
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			return copyFile(path, target)
+		default:
+			return nil
+		}
+	})
+}
+
+func copyFile(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,148 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeDirRunner struct {
+	dir string
+	env []string
+	run func(dir string, env []string, args ...string) error
+}
+
+func (r fakeDirRunner) RunScript(ctx context.Context, script string, args ...string) error {
+	return r.run(r.dir, r.env, args...)
+}
+
+func (r fakeDirRunner) InDir(dir string, env []string) ScriptRunner {
+	return fakeDirRunner{dir, env, r.run}
+}
+
+func TestIsolatedBuild(t *testing.T) {
+	workspace := t.TempDir()
+	rootDir := filepath.Join(workspace, "extension")
+	for name, content := range map[string]string{
+		"extension/src/index.js":                    "source",
+		"extension/node_modules/.cache/shared.json": "shared",
+		"extension/build/stale.js":                  "stale",
+		"packages/shared/index.js":                  "package",
+	} {
+		path := filepath.Join(workspace, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A workspace package linked into node_modules, and a link within the
+	// extension
+	if err := os.MkdirAll(filepath.Join(rootDir, "node_modules", "@org"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "..", "..", "packages", "shared"), filepath.Join(rootDir, "node_modules", "@org", "shared")); err != nil {
+		t.Skipf("symlinks aren't supported: %v", err)
+	}
+	if err := os.Symlink("index.js", filepath.Join(rootDir, "src", "main.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	var workingDir string
+	run := func(dir string, env []string, args ...string) error {
+		workingDir = dir
+		isolatedRoot := filepath.Dir(dir)
+
+		if content, err := os.ReadFile(filepath.Join(isolatedRoot, "node_modules", "@org", "shared", "index.js")); err != nil || string(content) != "package" {
+			t.Errorf("Expected linked workspace packages to resolve, got %q, %v", content, err)
+		}
+		if link, err := os.Readlink(filepath.Join(isolatedRoot, "src", "main.js")); err != nil || link != "index.js" {
+			t.Errorf("Expected links within the extension to stay relative, got %q, %v", link, err)
+		}
+
+		absIsolatedRoot, err := filepath.Abs(isolatedRoot)
+		if err != nil {
+			return err
+		}
+		if !containsString(env, "CACHE_DIR="+filepath.Join(absIsolatedRoot, ".cache")) {
+			t.Errorf("Expected the tool caches to be pointed into the copy, got %v", env)
+		}
+
+		if content, err := os.ReadFile(filepath.Join(isolatedRoot, "src", "index.js")); err != nil || string(content) != "source" {
+			t.Errorf("Expected the sources to be copied, got %q, %v", content, err)
+		}
+		if _, err := os.Stat(filepath.Join(isolatedRoot, "build", "stale.js")); !os.IsNotExist(err) {
+			t.Error("Expected the build directory not to be copied")
+		}
+		if _, err := os.Stat(filepath.Join(isolatedRoot, "node_modules", ".cache", "shared.json")); !os.IsNotExist(err) {
+			t.Error("Expected the build tool cache not to be copied")
+		}
+
+		cacheDir := filepath.Join(isolatedRoot, "node_modules", ".cache")
+		if err := os.MkdirAll(cacheDir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(cacheDir, "shared.json"), []byte("trampled"), 0644); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(args[1], "main.js"), []byte("fresh"), 0644)
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+	extension.Development.BuildDir = "build"
+	extension.Development.IsolatedBuild = true
+
+	builder := Builder{ScriptRunner: fakeDirRunner{rootDir, nil, run}, Extension: extension}
+	builder.Build(context.TODO(), func(result Result) {
+		if !result.Success {
+			t.Errorf("Expected Build operation to be successful, got %v", result.Error)
+		}
+	})
+
+	if !strings.HasPrefix(filepath.Base(filepath.Dir(workingDir)), isolatedDirPrefix) {
+		t.Errorf("Expected the build to run in an isolated copy, got %s", workingDir)
+	}
+
+	if content, err := os.ReadFile(filepath.Join(rootDir, "build", "main.js")); err != nil || string(content) != "fresh" {
+		t.Errorf("Expected build directory to contain the new build, got %q, %v", content, err)
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(rootDir, "node_modules", ".cache", "shared.json")); string(content) != "shared" {
+		t.Errorf("Expected the shared cache to be left alone, got %q", content)
+	}
+
+	if _, err := os.Stat(filepath.Dir(workingDir)); !os.IsNotExist(err) {
+		t.Error("Expected the isolated copy to be removed")
+	}
+}
+
+func TestIsolatedBuildUnsupportedRunner(t *testing.T) {
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		return nil
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = t.TempDir()
+	extension.Development.IsolatedBuild = true
+
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension}
+	builder.Build(context.TODO(), func(result Result) {
+		if result.Success {
+			t.Error("Expected isolated builds to fail with a runner that can't change directories")
+		}
+	})
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
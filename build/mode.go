@@ -0,0 +1,26 @@
+package build
+
+import "fmt"
+
+// Mode tells bundlers whether to optimize the output for production or to
+// build fast for development. It's passed to the build scripts and hooks as
+// NODE_ENV. Without a mode, NODE_ENV is inherited from the environment.
+type Mode string
+
+const (
+	Development Mode = "development"
+	Production  Mode = "production"
+)
+
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(value); mode {
+	case Development, Production:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported mode %q, supported modes: %s, %s", value, Development, Production)
+	}
+}
+
+func (mode Mode) env() string {
+	return fmt.Sprintf("NODE_ENV=%s", mode)
+}
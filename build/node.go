@@ -0,0 +1,184 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// nodeVersion returns the version of the installed Node.js, e.g. v18.12.0
+var nodeVersion = func(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, "node", "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine the Node version: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// checkNodeVersion makes sure the installed Node.js satisfies the node_version
+// of the extension or, when not configured, engines.node of its package.json.
+// Build tools failing on an unsupported Node version tend to do so with
+// obscure errors.
+func (b *Builder) checkNodeVersion(ctx context.Context) error {
+	constraint := b.Extension.Development.NodeVersion
+	if constraint == "" {
+		constraint = readEnginesNode(filepath.Join(b.Extension.Development.RootDir, "package.json"))
+	}
+	if constraint == "" {
+		return nil
+	}
+
+	installed, err := nodeVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	satisfied, err := satisfiesVersion(installed, constraint)
+	if err != nil {
+		return err
+	}
+	if !satisfied {
+		return fmt.Errorf("Node %s required, found %s", constraint, installed)
+	}
+	return nil
+}
+
+func readEnginesNode(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	var pkg struct {
+		Engines struct {
+			Node string `json:"node"`
+		} `json:"engines"`
+	}
+	if json.Unmarshal(content, &pkg) != nil {
+		return ""
+	}
+	return pkg.Engines.Node
+}
+
+// satisfiesVersion checks a version against a constraint in the format of
+// engines.node: ranges separated by ||, each made of comparators like >=14,
+// <19, ^16.13, ~16.1, 16.x or an exact version.
+func satisfiesVersion(version, constraint string) (bool, error) {
+	parsed, _, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	for _, versionRange := range strings.Split(constraint, "||") {
+		satisfied := true
+		comparators := make([]string, 0)
+		operator := ""
+		for _, field := range strings.Fields(versionRange) {
+			// Operators may be separated from their version, e.g. >= 14
+			if strings.Trim(field, "<>=^~") == "" {
+				operator += field
+				continue
+			}
+			comparators = append(comparators, operator+field)
+			operator = ""
+		}
+		if len(comparators) == 0 {
+			comparators = []string{"*"}
+		}
+
+		for _, comparator := range comparators {
+			matches, err := matchesComparator(parsed, comparator)
+			if err != nil {
+				return false, fmt.Errorf("invalid Node version constraint %q: %w", constraint, err)
+			}
+			satisfied = satisfied && matches
+		}
+
+		if satisfied {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchesComparator(version [3]int, comparator string) (bool, error) {
+	operator := strings.TrimRight(comparator, "0123456789.xX*v")
+	bound, parts, err := parseVersion(comparator[len(operator):])
+	if err != nil {
+		return false, err
+	}
+	compared := compareVersions(version, bound, parts)
+
+	switch operator {
+	case "", "=":
+		return compared == 0, nil
+	case ">":
+		return compared > 0, nil
+	case ">=":
+		return compared >= 0, nil
+	case "<":
+		return compared < 0, nil
+	case "<=":
+		return compared <= 0, nil
+	case "^":
+		// Compatible with the first non-zero part
+		locked := 1
+		for locked < parts && bound[locked-1] == 0 {
+			locked++
+		}
+		return compared >= 0 && compareVersions(version, bound, locked) == 0, nil
+	case "~":
+		locked := 2
+		if parts < 2 {
+			locked = parts
+		}
+		return compared >= 0 && compareVersions(version, bound, locked) == 0, nil
+	}
+	return false, fmt.Errorf("unsupported operator %q", operator)
+}
+
+// parseVersion parses versions like v18.12.0, 18.12 or 18.x, returning the
+// number of parts that were given. Pre-release and build suffixes are
+// ignored.
+func parseVersion(value string) (version [3]int, parts int, err error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if index := strings.IndexAny(value, "-+"); index >= 0 {
+		value = value[:index]
+	}
+	if value == "" || value == "*" || value == "x" || value == "X" {
+		return version, 0, nil
+	}
+
+	for index, part := range strings.Split(value, ".") {
+		if index >= len(version) {
+			return version, 0, errors.New("too many version parts in " + value)
+		}
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		if version[index], err = strconv.Atoi(part); err != nil {
+			return version, 0, fmt.Errorf("invalid version %q", value)
+		}
+		parts++
+	}
+	return version, parts, nil
+}
+
+// compareVersions compares the first parts of two versions
+func compareVersions(a, b [3]int, parts int) int {
+	for index := 0; index < parts; index++ {
+		if a[index] != b[index] {
+			if a[index] < b[index] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSatisfiesVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		satisfied  bool
+	}{
+		{"v18.12.0", ">=14", true},
+		{"v12.22.0", ">=14", false},
+		{"v18.12.0", ">=14.17.0 <19", true},
+		{"v19.0.0", ">=14.17.0 <19", false},
+		{"v18.12.0", ">= 16", true},
+		{"v16.13.0", "^16.10.0", true},
+		{"v17.0.0", "^16.10.0", false},
+		{"v16.9.0", "^16.10.0", false},
+		{"v16.1.5", "~16.1", true},
+		{"v16.2.0", "~16.1", false},
+		{"v16.4.0", "16.x", true},
+		{"v14.0.0", "12 || 14", true},
+		{"v13.0.0", "12 || 14", false},
+		{"v18.12.0", "*", true},
+		{"v18.12.0", ">18", false},
+		{"v18.12.0", "<=18", true},
+	}
+
+	for _, test := range tests {
+		satisfied, err := satisfiesVersion(test.version, test.constraint)
+		if err != nil {
+			t.Errorf("Unexpected error for %s %s: %v", test.version, test.constraint, err)
+		}
+		if satisfied != test.satisfied {
+			t.Errorf("Expected %s to satisfy %q: %v", test.version, test.constraint, test.satisfied)
+		}
+	}
+
+	if _, err := satisfiesVersion("v18.0.0", "14 - 18"); err == nil {
+		t.Error("Expected an error for an unsupported constraint")
+	}
+}
+
+func TestBuildChecksNodeVersion(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootDir, "package.json"), []byte(`{"engines": {"node": ">=18"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(original func(ctx context.Context) (string, error)) { nodeVersion = original }(nodeVersion)
+	nodeVersion = func(ctx context.Context) (string, error) {
+		return "v16.13.0", nil
+	}
+
+	runnerWasCalled := false
+	fakeRunner := func(ctx context.Context, script string, args ...string) error {
+		runnerWasCalled = true
+		return nil
+	}
+
+	extension := config.Extensions[0]
+	extension.Development.RootDir = rootDir
+	builder := Builder{ScriptRunner: ScriptRunnerFunc(fakeRunner), Extension: extension}
+
+	builder.Build(context.TODO(), func(result Result) {
+		if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "Node >=18 required, found v16.13.0") {
+			t.Errorf("Expected the build to fail with the required Node version, got %v", result.Error)
+		}
+	})
+
+	if runnerWasCalled {
+		t.Error("Expected the build script not to run")
+	}
+
+	builder.Extension.Development.NodeVersion = ">=16"
+	builder.Build(context.TODO(), func(result Result) {
+		if !result.Success {
+			t.Errorf("Expected node_version to take precedence over engines.node, got %v", result.Error)
+		}
+	})
+}
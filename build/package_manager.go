@@ -24,6 +24,7 @@ type PackageManager struct {
 	name       string
 	formatArgs FormatArgs
 	workingDir string
+	env        []string
 	stdout     io.Writer
 	stderr     io.Writer
 }
@@ -54,14 +55,22 @@ func yarn(workingDir string) *PackageManager {
 }
 
 func (pm *PackageManager) RunScript(ctx context.Context, script string, args ...string) error {
-	cmd := exec.CommandContext(ctx, pm.name, pm.formatArgs(script, args...)...)
-	cmd.Dir = pm.workingDir
-	cmd.Stdout = pm.stdout
-	cmd.Stderr = pm.stderr
+	cmd := pm.command(script, args...)
 
 	if _, err := os.Stat(pm.workingDir); os.IsNotExist(err) {
 		return errors.New(err.Error())
 	}
 
-	return cmd.Run()
+	return runCommand(ctx, cmd)
+}
+
+func (pm *PackageManager) command(script string, args ...string) *exec.Cmd {
+	cmd := exec.Command(pm.name, pm.formatArgs(script, args...)...)
+	cmd.Dir = pm.workingDir
+	if len(pm.env) > 0 {
+		cmd.Env = append(os.Environ(), pm.env...)
+	}
+	cmd.Stdout = pm.stdout
+	cmd.Stderr = pm.stderr
+	return cmd
 }
@@ -69,6 +69,28 @@ func TestRunScript(t *testing.T) {
 	}
 }
 
+func TestRunScriptWithEnv(t *testing.T) {
+	var buffer strings.Builder
+
+	pm := PackageManager{
+		name: "bash",
+		formatArgs: func(script string, args ...string) []string {
+			return []string{"-c", "echo $JOBS"}
+		},
+		workingDir: "testdata/build",
+		env:        []string{"JOBS=3"},
+		stdout:     &buffer,
+	}
+
+	if err := pm.RunScript(context.TODO(), "jobs"); err != nil {
+		t.Error("Expected RunScript to be successful")
+	}
+
+	if buffer.String() != "3\n" {
+		t.Errorf("Expected env to be passed to the script, got: %s", buffer.String())
+	}
+}
+
 func TestRunScriptErrorIfDirectoryNotFound(t *testing.T) {
 	pm := PackageManager{
 		name: "bash",
@@ -0,0 +1,66 @@
+package build
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// waitDelay bounds how long a cancelled command may keep its output open
+// after its process group was killed. exec.Cmd.WaitDelay does the same but
+// isn't available before Go 1.20.
+const waitDelay = 5 * time.Second
+
+var running = struct {
+	sync.Mutex
+	commands map[*exec.Cmd]struct{}
+}{commands: map[*exec.Cmd]struct{}{}}
+
+// runCommand runs the command in its own process group and kills the whole
+// group once the context is cancelled, so that the processes started by a
+// build script or hook don't outlive it
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	running.Lock()
+	running.commands[cmd] = struct{}{}
+	running.Unlock()
+	defer func() {
+		running.Lock()
+		delete(running.commands, cmd)
+		running.Unlock()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	killProcessGroup(cmd)
+	select {
+	case <-done:
+	case <-time.After(waitDelay):
+	}
+	return ctx.Err()
+}
+
+// StopCommands kills the process groups of the build scripts and hooks that
+// are still running. Those don't receive the interrupt sent to the CLI, so
+// it has to stop them before exiting.
+func StopCommands() {
+	running.Lock()
+	defer running.Unlock()
+	for cmd := range running.commands {
+		killProcessGroup(cmd)
+	}
+}
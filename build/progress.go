@@ -0,0 +1,71 @@
+package build
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// IndeterminateProgress is reported when a build started but its output
+// doesn't tell how far along it is
+const IndeterminateProgress = -1
+
+// progressStep is the granularity of the reported percentages, so hosts get
+// coarse milestones rather than an update per line of output
+const progressStep = 10
+
+// progressPattern matches the percentages build tools print, e.g. webpack's
+// ProgressPlugin: "[webpack.Progress] 45% building 12/30 modules"
+var progressPattern = regexp.MustCompile(`(?:^|[\s\]])(\d{1,3})%(?:\s|$)`)
+
+// progressWriter passes the output of build scripts through and reports
+// the progress it finds in complete lines
+type progressWriter struct {
+	output     io.Writer
+	onProgress func(percent int)
+	mutex      sync.Mutex
+	line       []byte
+	milestone  int
+}
+
+func newProgressWriter(output io.Writer, onProgress func(percent int)) *progressWriter {
+	return &progressWriter{output: output, onProgress: onProgress, milestone: IndeterminateProgress}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	w.line = append(w.line, p...)
+	for {
+		// Progress bars redraw the line with a carriage return
+		end := bytes.IndexAny(w.line, "\r\n")
+		if end < 0 {
+			break
+		}
+		w.parse(w.line[:end])
+		w.line = w.line[end+1:]
+	}
+	w.mutex.Unlock()
+
+	return w.output.Write(p)
+}
+
+func (w *progressWriter) parse(line []byte) {
+	match := progressPattern.FindSubmatch(line)
+	if match == nil {
+		return
+	}
+
+	percent, err := strconv.Atoi(string(match[1]))
+	if err != nil || percent > 100 {
+		return
+	}
+
+	milestone := percent - percent%progressStep
+	// A lower percentage means that a new build started, e.g. in watch mode
+	if milestone != w.milestone {
+		w.milestone = milestone
+		w.onProgress(milestone)
+	}
+}
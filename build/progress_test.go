@@ -0,0 +1,43 @@
+package build
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var output strings.Builder
+	reported := make([]int, 0)
+	writer := newProgressWriter(&output, func(percent int) {
+		reported = append(reported, percent)
+	})
+
+	chunks := []string{
+		"<s> [webpack.Progress] 0% compiling\n",
+		"<s> [webpack.Progress] 10% building 0/1 modules\r",
+		"<s> [webpack.Progress] 14% building 3/12 mod",
+		"ules\r<s> [webpack.Progress] 65% building 9/12 modules\n",
+		"Hash: 1a2b3c, 100% of the entries are cached\n",
+		"Build finished, 42 modules\n",
+		"<s> [webpack.Progress] 5% compiling\n",
+	}
+	for _, chunk := range chunks {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []int{0, 10, 60, 100, 0}
+	if len(reported) != len(expected) {
+		t.Fatalf("Expected progress %v, got %v", expected, reported)
+	}
+	for index := range expected {
+		if reported[index] != expected[index] {
+			t.Fatalf("Expected progress %v, got %v", expected, reported)
+		}
+	}
+
+	if output.String() != strings.Join(chunks, "") {
+		t.Errorf("Expected the output to be passed through, got %q", output.String())
+	}
+}
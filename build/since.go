@@ -0,0 +1,93 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// changedFiles returns the absolute paths of the files that changed since the
+// given git ref, including uncommitted changes and untracked files that
+// aren't ignored
+var changedFiles = func(ctx context.Context, ref string) ([]string, error) {
+	toplevel, err := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the git repository: %w", err)
+	}
+	root := strings.TrimSpace(string(toplevel))
+
+	changed, err := exec.CommandContext(ctx, "git", "-C", root, "diff", "--name-only", ref, "--").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files changed since %s: %w", ref, err)
+	}
+
+	untracked, err := exec.CommandContext(ctx, "git", "-C", root, "ls-files", "--others", "--exclude-standard").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list untracked files: %w", err)
+	}
+
+	files := make([]string, 0)
+	for _, line := range strings.Split(string(changed)+"\n"+string(untracked), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.Join(root, filepath.FromSlash(line)))
+		}
+	}
+	return files, nil
+}
+
+// resolvePath resolves the symlinks of a path, e.g. /var to /private/var on
+// macOS, so that paths reported by git can be compared with configured ones.
+// Paths that don't exist anymore, like deleted files, are resolved as far as
+// their parents exist.
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(resolvePath(parent), filepath.Base(path))
+}
+
+// ChangedSince returns the UUIDs of the extensions affected by the changes
+// since the given git ref: extensions with changed files below their root
+// directory and the extensions depending on them. The extensions have to be
+// sorted by their dependencies, see core.SortByDependencies.
+func ChangedSince(ctx context.Context, ref string, extensions []core.Extension) (map[string]bool, error) {
+	files, err := changedFiles(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	for index, file := range files {
+		files[index] = resolvePath(file)
+	}
+
+	changed := make(map[string]bool, len(extensions))
+	for _, extension := range extensions {
+		root, err := filepath.Abs(extension.Development.RootDir)
+		if err != nil {
+			return nil, err
+		}
+		root = resolvePath(root)
+
+		for _, file := range files {
+			if file == root || strings.HasPrefix(file, root+string(filepath.Separator)) {
+				changed[extension.UUID] = true
+				break
+			}
+		}
+
+		for _, dependency := range extension.DependsOn {
+			if changed[dependency] {
+				changed[extension.UUID] = true
+			}
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,137 @@
+package build
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func TestChangedSince(t *testing.T) {
+	root, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(original func(context.Context, string) ([]string, error)) { changedFiles = original }(changedFiles)
+	changedFiles = func(ctx context.Context, ref string) ([]string, error) {
+		if ref != "origin/main" {
+			t.Errorf("Expected ref origin/main, got %s", ref)
+		}
+		return []string{
+			filepath.Join(root, "checkout", "src", "index.js"),
+			filepath.Join(root, "checkout-extra", "README.md"),
+			filepath.Join(root, "README.md"),
+		}, nil
+	}
+
+	extensions := []core.Extension{
+		{UUID: "checkout", Development: core.Development{RootDir: "testdata/checkout"}},
+		{UUID: "admin", Development: core.Development{RootDir: "testdata/admin"}},
+		{UUID: "shared", Development: core.Development{RootDir: "testdata/shared"}},
+		{UUID: "uses-checkout", Development: core.Development{RootDir: "testdata/uses-checkout"}, DependsOn: []string{"checkout"}},
+	}
+
+	changed, err := ChangedSince(context.Background(), "origin/main", extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{"checkout": true, "uses-checkout": true}
+	for _, extension := range extensions {
+		if changed[extension.UUID] != expected[extension.UUID] {
+			t.Errorf("Expected extension %s to be changed: %v", extension.UUID, expected[extension.UUID])
+		}
+	}
+}
+
+func TestChangedSinceSymlinkedRoot(t *testing.T) {
+	dir := t.TempDir()
+	checkout := filepath.Join(dir, "checkout")
+	if err := os.MkdirAll(filepath.Join(checkout, "extension", "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	workspace := filepath.Join(dir, "workspace")
+	if err := os.Symlink(checkout, workspace); err != nil {
+		t.Skipf("symlinks aren't supported: %v", err)
+	}
+
+	defer func(original func(context.Context, string) ([]string, error)) { changedFiles = original }(changedFiles)
+	changedFiles = func(ctx context.Context, ref string) ([]string, error) {
+		// git reports paths below the resolved repository root
+		return []string{
+			filepath.Join(checkout, "extension", "src", "index.js"),
+			filepath.Join(checkout, "extension", "src", "deleted.js"),
+		}, nil
+	}
+
+	extensions := []core.Extension{
+		{UUID: "extension", Development: core.Development{RootDir: filepath.Join(workspace, "extension")}},
+	}
+
+	changed, err := ChangedSince(context.Background(), "origin/main", extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed["extension"] {
+		t.Error("Expected the extension in the symlinked workspace to be changed")
+	}
+}
+
+func TestChangedFilesIncludesUntrackedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	write := func(name string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("committed.js")
+	write(".gitignore")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	write("untracked.js")
+	write("ignored.log")
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	files, err := changedFiles(context.Background(), "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{".gitignore", "untracked.js"}) {
+		t.Errorf("Expected the changed and untracked files, got %v", names)
+	}
+}
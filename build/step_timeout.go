@@ -0,0 +1,25 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// runStep runs a step of a production build under its own timeout, so that
+// a hanging step fails with a message naming it. A zero timeout only stops
+// the step when the build is cancelled.
+func runStep(ctx context.Context, stage string, timeout time.Duration, step func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return step(ctx)
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := step(stepCtx)
+	if err != nil && ctx.Err() == nil && stepCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s step timed out after %s", stage, timeout)
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package build
+
+import (
+	"context"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// Tracer records a span for each production build, e.g. with OpenTelemetry
+type Tracer interface {
+	// StartBuild starts the span of a build, parent is the trace context the
+	// CLI was started in, if any
+	StartBuild(ctx context.Context, extension core.Extension, parent *core.TraceParent) BuildSpan
+}
+
+// BuildSpan is ended with the result of the build, including cached ones
+type BuildSpan interface {
+	End(result Result)
+}
+
+// TraceParentFromEnv returns the trace context passed to the CLI in the
+// TRACEPARENT environment variable, nil if it's missing or invalid. Build
+// scripts inherit the variable.
+func TraceParentFromEnv(getenv func(string) string) *core.TraceParent {
+	if traceParent, ok := core.ParseTraceParent(getenv("TRACEPARENT")); ok {
+		return &traceParent
+	}
+	return nil
+}
+
+// traced ends the span of the build, if any, before yielding its result
+func (b *Builder) traced(ctx context.Context, yield func(result Result)) func(result Result) {
+	if b.options.Tracer == nil {
+		return yield
+	}
+
+	span := b.options.Tracer.StartBuild(ctx, b.Extension, b.options.TraceParent)
+	return func(result Result) {
+		span.End(result)
+		yield(result)
+	}
+}
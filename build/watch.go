@@ -0,0 +1,148 @@
+package build
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch reports changes of the build directory. The sources below the
+// directories of the entries are watched as well: a source change or a
+// production build marks a rebuild as in progress, and changes of the build
+// directory are then reported without an asset until the build output
+// settled. Other changes are reported with the asset that changed, relative
+// to the build directory.
+func (b *Builder) Watch(ctx context.Context, yield func(result Result)) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		yield(Result{false, err, b.Extension.UUID, 0, false, "", b.options.Mode})
+		return
+	}
+	defer watcher.Close()
+
+	watchDir := filepath.Join(b.Extension.Development.RootDir, b.Extension.Development.BuildDir)
+	if err = watchTree(watcher, watchDir, ""); err != nil {
+		yield(Result{false, err, b.Extension.UUID, 0, false, "", b.options.Mode})
+	}
+
+	for _, sourceDir := range b.sourceDirs(watchDir) {
+		if err := watchTree(watcher, sourceDir, watchDir); err != nil {
+			log.Printf("unable to watch source directory %s: %v", sourceDir, err)
+		}
+	}
+
+	// rebuilding is set by source changes and production builds, and cleared
+	// once the build directory didn't change for settleDelay
+	rebuilding := false
+	var settled <-chan time.Time
+	// rewatch fires while the build directory is missing, e.g. after the
+	// build tool removed it, to watch it again once it's recreated
+	var rewatch <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Terminating watcher")
+			yield(Result{true, nil, b.Extension.UUID, 0, false, "", b.options.Mode})
+			return
+		case <-rewatch:
+			if err := watchTree(watcher, watchDir, ""); err != nil {
+				rewatch = time.After(rewatchInterval)
+				continue
+			}
+			rewatch = nil
+			yield(Result{true, nil, b.Extension.UUID, 0, false, "", b.options.Mode})
+		case <-settled:
+			rebuilding, settled = false, nil
+		case event := <-watcher.Events:
+			if event.Name == watchDir {
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					rewatch = time.After(rewatchInterval)
+				}
+				continue
+			}
+
+			asset, err := filepath.Rel(watchDir, event.Name)
+			inBuildDir := err == nil && asset != ".." && !strings.HasPrefix(asset, ".."+string(filepath.Separator))
+
+			if !inBuildDir {
+				if !ignoredSource(filepath.Base(event.Name), watchDir) {
+					if event.Op&fsnotify.Create != 0 {
+						watchTree(watcher, event.Name, watchDir)
+					}
+					rebuilding = true
+				}
+				continue
+			}
+
+			// Production builds move their files into the build directory
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 || filepath.Base(event.Name) == cacheFile {
+				continue
+			}
+			log.Printf("file system event: %v\n", event)
+
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watchTree(watcher, event.Name, "")
+					asset = ""
+				}
+			}
+
+			if rebuilding || atomic.LoadInt32(&b.building) > 0 {
+				rebuilding, settled = true, time.After(settleDelay)
+				asset = ""
+			}
+			yield(Result{true, nil, b.Extension.UUID, 0, false, filepath.ToSlash(asset), b.options.Mode})
+		case err = <-watcher.Errors:
+			log.Printf("file system error: %v\n", err)
+			yield(Result{false, err, b.Extension.UUID, 0, false, "", b.options.Mode})
+		}
+	}
+}
+
+// rewatchInterval is how often a removed build directory is checked for
+const rewatchInterval = 100 * time.Millisecond
+
+// settleDelay is how long the build directory has to stay unchanged for a
+// rebuild to be considered finished
+const settleDelay = 500 * time.Millisecond
+
+// watchTree watches dir and the directories below it. Sources ignored by
+// walkSources are skipped, along with buildDir unless it's empty.
+func watchTree(watcher *fsnotify.Watcher, dir, buildDir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if buildDir != "" && path != dir && (path == buildDir || ignoredSource(entry.Name(), buildDir)) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// sourceDirs returns the directories of the entries of the extension
+func (b *Builder) sourceDirs(buildDir string) []string {
+	dirs := make([]string, 0)
+	seen := map[string]bool{buildDir: true}
+	for _, entry := range b.Extension.Development.Entries {
+		dir := filepath.Join(b.Extension.Development.RootDir, filepath.Dir(entry))
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const (
+	red   = "31"
+	green = "32"
+)
+
+var colorsEnabled = false
+
+// addColorFlags registers --color and --no-color. The returned function
+// decides whether output is colorized once the flags have been parsed.
+// By default colors are only used when logging to a terminal, so that output
+// captured by a parent process or redirected to a file stays clean.
+func addColorFlags(flags *flag.FlagSet) func() {
+	color := flags.String("color", "auto", "colorize output: auto, always or never")
+	noColor := flags.Bool("no-color", false, "disable colorized output, same as --color=never")
+
+	return func() {
+		switch {
+		case *noColor || *color == "never":
+			colorsEnabled = false
+		case *color == "always":
+			colorsEnabled = true
+		default:
+			_, noColorEnv := os.LookupEnv("NO_COLOR")
+			colorsEnabled = !noColorEnv && isTerminal(os.Stderr)
+		}
+	}
+}
+
+func colorize(color, text string) string {
+	if !colorsEnabled {
+		return text
+	}
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", color, text)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
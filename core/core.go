@@ -3,10 +3,25 @@ package core
 import (
 	"fmt"
 	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// defaultApiVersion is the version of the manifest format served to hosts.
+// It's independent of the version of the binary.
+const defaultApiVersion = "0.1.0"
+
+// defaultAssetPathTemplate matches bundles named after their entry in the
+// root of the build directory, which is served below assets/
+const defaultAssetPathTemplate = "assets/{name}.js"
+
 func NewExtensionService(config *Config) *ExtensionService {
 	extensions := config.Extensions
 	for index, extension := range extensions {
@@ -17,51 +32,704 @@ func NewExtensionService(config *Config) *ExtensionService {
 
 		for entry := range keys {
 			name := keys[entry]
-			assetUrl := fmt.Sprintf("http://%s:%d/extensions/%s/assets/%s.js", "localhost", config.Port, extension.UUID, name)
+			assetUrl := config.PublicBaseUrl(extension.Type) + getAssetPath(config, extension, name)
 			extensions[index].Assets = append(extensions[index].Assets, Asset{Url: assetUrl, Name: name})
 		}
 
+		// Hosts expect an array even for extensions without entries
+		if extensions[index].Assets == nil {
+			extensions[index].Assets = []Asset{}
+		}
+
 		extensions[index].App = make(App)
+
+		if extension.Capabilities == nil {
+			extensions[index].Capabilities = make(map[string]interface{})
+		}
+		if extension.Labels == nil {
+			extensions[index].Labels = []string{}
+		}
+
+		if extension.Development.Icon != "" {
+			extensions[index].Icon = &Url{fmt.Sprintf("%s%s/extensions/%s/icon", config.PublicBaseUrl(extension.Type), config.ApiRoot, extension.UUID)}
+		}
+
+		// Hosts load the renderer version from the manifest, name@version is
+		// accepted as a shorthand in the configuration
+		if renderer, err := ParseRenderer(extension.Development.Renderer.Name); err == nil && renderer.Version != "" {
+			extensions[index].Development.Renderer = renderer
+		}
+
+		if extension.Title == "" {
+			extensions[index].Title = humanize(extension.Type)
+		}
+	}
+
+	version := defaultApiVersion
+	if config.ApiVersion != "" {
+		version = config.ApiVersion
 	}
 
 	service := ExtensionService{
-		Version:    "0.1.0",
+		Version:    version,
 		Extensions: extensions,
+		Warnings:   Warnings(config),
 	}
 
 	return &service
 }
 
+// Surface returns the surface an extension type is rendered on
+func Surface(extensionType string) string {
+	switch {
+	case strings.HasPrefix(extensionType, "checkout_"):
+		return "checkout"
+	case extensionType == "product_subscription" || strings.HasPrefix(extensionType, "admin_"):
+		return "admin"
+	case strings.HasPrefix(extensionType, "pos_"):
+		return "pos"
+	}
+	return "unknown"
+}
+
+// Surfaces are the known surfaces, previews can be rendered on any of them
+var Surfaces = []string{"checkout", "admin", "pos"}
+
+// secureSurfaces are loaded over https, assets served over http are blocked
+// as mixed content there
+var secureSurfaces = map[string]bool{"checkout": true, "admin": true}
+
+// PublicBaseUrl returns the scheme and host of the URLs in the manifest of
+// an extension of the given type: the public_url, e.g. of a tunnel, or the
+// local server. With insecure_public_url: upgrade, an http public_url is
+// turned into https for extensions on surfaces loaded over https.
+func (config *Config) PublicBaseUrl(extensionType string) string {
+	if config.PublicUrl == "" {
+		return fmt.Sprintf("http://localhost:%d", config.Port)
+	}
+
+	base := strings.TrimSuffix(config.PublicUrl, "/")
+	if config.InsecurePublicUrl == "upgrade" && secureSurfaces[Surface(extensionType)] && strings.HasPrefix(base, "http://") {
+		base = "https://" + strings.TrimPrefix(base, "http://")
+	}
+	return base
+}
+
+// Warnings returns the problems of a configuration that don't keep the
+// extensions from being served, but likely keep them from working as
+// intended. Hosts show them to developers along with the extensions.
+func Warnings(config *Config) []string {
+	warnings := make([]string, 0)
+	for _, extension := range config.Extensions {
+		if len(extension.Development.Entries) == 0 {
+			warnings = append(warnings, fmt.Sprintf("extension %s has no entries, check the entries of its development section", extension.UUID))
+		}
+		if config.Store == "" && Surface(extension.Type) == "checkout" {
+			warnings = append(warnings, fmt.Sprintf("extension %s renders in checkout, set store to preview it on a shop", extension.UUID))
+		}
+		if surface := Surface(extension.Type); config.PublicUrl != "" && secureSurfaces[surface] && strings.HasPrefix(config.PublicBaseUrl(extension.Type), "http://") {
+			warnings = append(warnings, fmt.Sprintf("extension %s renders in %s, which blocks its assets from the http public_url, use https or set insecure_public_url: upgrade", extension.UUID, surface))
+		}
+	}
+	return warnings
+}
+
+// getAssetPath returns the path an entry of an extension is served at
+func getAssetPath(config *Config, extension Extension, name string) string {
+	assetPath := strings.ReplaceAll(getAssetPathTemplate(config, extension), "{name}", name)
+	return fmt.Sprintf("%s/extensions/%s/%s", config.AssetBase(), extension.UUID, assetPath)
+}
+
+// AssetBase returns the path the build directories are served below. It's
+// the ApiRoot unless asset_root moves the assets elsewhere, in which case
+// the assets of apps are served below <asset_root>/apps/<name>.
+func (config *Config) AssetBase() string {
+	if config.AssetRoot == "" {
+		return config.ApiRoot
+	}
+	return strings.TrimSuffix(config.AssetRoot, "/") + config.ApiRoot
+}
+
+// getAssetPathTemplate returns the path of an extension's assets relative
+// to the extension's URL, where {name} is replaced by the entry name. The
+// extension's template takes precedence over the global one.
+func getAssetPathTemplate(config *Config, extension Extension) string {
+	if extension.Development.AssetPathTemplate != "" {
+		return strings.TrimPrefix(extension.Development.AssetPathTemplate, "/")
+	}
+	if config.AssetPathTemplate != "" {
+		return strings.TrimPrefix(config.AssetPathTemplate, "/")
+	}
+	return defaultAssetPathTemplate
+}
+
+// FilterExtensions returns the extensions matching at least one of the filters.
+// A filter is either an extension UUID, which has to match exactly, or a type
+// filter of the form type:<pattern>. Patterns containing glob characters
+// (*, ? or [) are matched against the whole type with path.Match, any other
+// pattern is matched as a prefix of the type, e.g. type:checkout or
+// type:*_ui_extension. All extensions are returned when no filters are given.
+func FilterExtensions(extensions []Extension, filters []string) []Extension {
+	activeFilters := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		if filter = strings.TrimSpace(filter); filter != "" {
+			activeFilters = append(activeFilters, filter)
+		}
+	}
+
+	if len(activeFilters) == 0 {
+		return extensions
+	}
+
+	filtered := make([]Extension, 0)
+	for _, extension := range extensions {
+		for _, filter := range activeFilters {
+			if matchesFilter(extension, filter) {
+				filtered = append(filtered, extension)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func matchesFilter(extension Extension, filter string) bool {
+	if strings.HasPrefix(filter, "label:") {
+		return extension.HasLabel(strings.TrimPrefix(filter, "label:"))
+	}
+
+	if !strings.HasPrefix(filter, "type:") {
+		return extension.UUID == filter
+	}
+
+	pattern := strings.TrimPrefix(filter, "type:")
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, err := path.Match(pattern, extension.Type)
+		return err == nil && matched
+	}
+	return strings.HasPrefix(extension.Type, pattern)
+}
+
+// HasLabel reports whether the extension is tagged with the label
+func (extension Extension) HasLabel(label string) bool {
+	for _, extensionLabel := range extension.Labels {
+		if extensionLabel == label {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRenderer splits a renderer of the form name@version, e.g.
+// @shopify/checkout-ui-extensions-react@0.12.0. The version is optional but
+// has to be a plausible semantic version or range like ^1.2 when present.
+func ParseRenderer(value string) (Renderer, error) {
+	separator := strings.LastIndex(value, "@")
+	// Scoped package names start with @
+	if separator <= 0 {
+		return Renderer{Name: value}, nil
+	}
+
+	renderer := Renderer{Name: value[:separator], Version: value[separator+1:]}
+	if !IsValidVersion(renderer.Version) {
+		return renderer, fmt.Errorf("invalid version %q for renderer %s, expected a version like 1.2.3 or ^1.2", renderer.Version, renderer.Name)
+	}
+	return renderer, nil
+}
+
+var versionPattern = regexp.MustCompile(`^[\^~]?\d+(\.\d+){0,2}(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// IsValidVersion accepts semantic versions, optionally abbreviated or with a
+// caret or tilde range prefix, e.g. 1.2.3, 18, ^1.2 or 1.0.0-rc.1.
+func IsValidVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// SortByDependencies orders extensions so that every extension comes after
+// the extensions it depends on, keeping the configured order otherwise.
+// Dependencies on extensions that aren't part of the list, e.g. because they
+// were filtered out, are ignored. Cycles are reported as an error.
+func SortByDependencies(extensions []Extension) ([]Extension, error) {
+	indexes := make(map[string]int, len(extensions))
+	for index, extension := range extensions {
+		indexes[extension.UUID] = index
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(extensions))
+	sorted := make([]Extension, 0, len(extensions))
+
+	var visit func(index int, path []string) error
+	visit = func(index int, path []string) error {
+		extension := extensions[index]
+		path = append(path, extension.UUID)
+
+		switch state[index] {
+		case visiting:
+			return fmt.Errorf("extensions depend on each other: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[index] = visiting
+		for _, dependency := range extension.DependsOn {
+			if dependencyIndex, ok := indexes[dependency]; ok {
+				if err := visit(dependencyIndex, path); err != nil {
+					return err
+				}
+			}
+		}
+		state[index] = visited
+
+		sorted = append(sorted, extension)
+		return nil
+	}
+
+	for index := range extensions {
+		if err := visit(index, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// humanize turns an extension type like checkout_ui_extension into a title
+// like Checkout UI Extension.
+func humanize(extensionType string) string {
+	words := strings.Fields(strings.ReplaceAll(extensionType, "_", " "))
+	for index, word := range words {
+		if word == "ui" {
+			words[index] = "UI"
+		} else {
+			words[index] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// ForApp returns the configuration of an app section. The app's extensions
+// are served below /apps/<name> and share all other settings. The name is
+// validated when the configuration is loaded, it's used in routes as is.
+func (config *Config) ForApp(app AppConfig) *Config {
+	appConfig := *config
+	appConfig.Extensions = app.Extensions
+	appConfig.Store = app.Store
+	appConfig.Apps = nil
+	appConfig.ApiRoot = fmt.Sprintf("/apps/%s", app.Name)
+	return &appConfig
+}
+
+// AllExtensions returns the extensions of the configuration followed by the
+// extensions of all apps.
+func (config *Config) AllExtensions() []Extension {
+	extensions := append([]Extension{}, config.Extensions...)
+	for _, app := range config.Apps {
+		extensions = append(extensions, app.Extensions...)
+	}
+	return extensions
+}
+
 func LoadConfig(r io.Reader) (config *Config, err error) {
 	config = &Config{}
 	decoder := yaml.NewDecoder(r)
 	err = decoder.Decode(config)
+	if err != nil {
+		return
+	}
+
+	err = validateConfig(config)
 	return
 }
 
+func validateConfig(config *Config) error {
+	if err := validateApps(config.Apps); err != nil {
+		return err
+	}
+	if err := validateDependencies(config.AllExtensions()); err != nil {
+		return err
+	}
+	if err := validateUpstreamProxy(config.UpstreamProxy); err != nil {
+		return err
+	}
+	if err := validateAssetRoot(config.AssetRoot); err != nil {
+		return err
+	}
+	if err := validateRedirectStatus(config.RedirectStatus); err != nil {
+		return err
+	}
+	if err := validateNotFoundRedirect(config.NotFoundRedirect); err != nil {
+		return err
+	}
+	if err := validatePublicUrl(config.PublicUrl, config.InsecurePublicUrl); err != nil {
+		return err
+	}
+	if err := validateAssetHeaders(config); err != nil {
+		return err
+	}
+	if err := validateIcons(config.AllExtensions()); err != nil {
+		return err
+	}
+	if err := validateAssetPaths(config); err != nil {
+		return err
+	}
+	return validateRenderers(config.AllExtensions())
+}
+
+func validateUpstreamProxy(upstream string) error {
+	if upstream == "" {
+		return nil
+	}
+	parsed, err := url.Parse(upstream)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid upstream_proxy %q, expected a URL like http://localhost:5173", upstream)
+	}
+	return nil
+}
+
+func validatePublicUrl(publicUrl, insecurePublicUrl string) error {
+	if insecurePublicUrl != "" && insecurePublicUrl != "warn" && insecurePublicUrl != "upgrade" {
+		return fmt.Errorf("invalid insecure_public_url %q, expected warn or upgrade", insecurePublicUrl)
+	}
+	if publicUrl == "" {
+		return nil
+	}
+	parsed, err := url.Parse(publicUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("invalid public_url %q, expected a URL like https://my-tunnel.example.com", publicUrl)
+	}
+	return nil
+}
+
+// validateRedirectStatus only accepts the status codes of redirects
+func validateRedirectStatus(status int) error {
+	switch status {
+	case 0, 301, 302, 303, 307, 308:
+		return nil
+	}
+	return fmt.Errorf("invalid redirect_status %d, expected 301, 302, 303, 307 or 308", status)
+}
+
+func validateNotFoundRedirect(target string) error {
+	if target == "" {
+		return nil
+	}
+	parsed, err := url.Parse(target)
+	switch {
+	case err != nil:
+	case parsed.Scheme == "http" || parsed.Scheme == "https":
+		if parsed.Host != "" {
+			return nil
+		}
+	case parsed.Scheme == "" && parsed.Host == "" && strings.HasPrefix(parsed.Path, "/"):
+		return nil
+	}
+	return fmt.Errorf("invalid not_found_redirect %q, expected a URL like https://example.com/extensions or an absolute path", target)
+}
+
+func validateAssetRoot(assetRoot string) error {
+	if assetRoot == "" {
+		return nil
+	}
+	cleaned := path.Clean(assetRoot)
+	if !strings.HasPrefix(assetRoot, "/") || (cleaned != assetRoot && cleaned+"/" != assetRoot) {
+		return fmt.Errorf("invalid asset_root %q, expected an absolute path like /cdn", assetRoot)
+	}
+	return nil
+}
+
+// headerName matches the token characters header names consist of
+var headerName = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// validateAssetHeaders rejects header names and values that would produce
+// invalid responses, or inject headers
+func validateAssetHeaders(config *Config) error {
+	check := func(headers map[string]string, owner string) error {
+		for name, value := range headers {
+			if !headerName.MatchString(name) {
+				return fmt.Errorf("invalid asset header name %q %s", name, owner)
+			}
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("invalid value of asset header %s %s, values can't contain line breaks", name, owner)
+			}
+		}
+		return nil
+	}
+
+	if err := check(config.AssetHeaders, "in asset_headers"); err != nil {
+		return err
+	}
+	for _, extension := range config.AllExtensions() {
+		if err := check(extension.Development.AssetHeaders, "of extension "+extension.UUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateIcons makes sure icons can't be used to serve files from outside
+// the directory of their extension
+func validateIcons(extensions []Extension) error {
+	for _, extension := range extensions {
+		icon := extension.Development.Icon
+		if icon == "" {
+			continue
+		}
+
+		cleaned := filepath.Clean(filepath.FromSlash(icon))
+		if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid icon %q of extension %s, icons have to be within the root directory of the extension", icon, extension.UUID)
+		}
+	}
+	return nil
+}
+
+// validateAssetPaths makes sure every entry is served below assets/, where
+// the build directory is served, and that no two entries are served at the
+// same URL, e.g. because of duplicate UUIDs or an asset_path_template without
+// {name}, in which case one of them would silently be shadowed by the other
+func validateAssetPaths(config *Config) error {
+	configs := []*Config{config}
+	for _, app := range config.Apps {
+		configs = append(configs, config.ForApp(app))
+	}
+
+	for _, config := range configs {
+		entries := make(map[string]string)
+		for _, extension := range config.Extensions {
+			names := make([]string, 0, len(extension.Development.Entries))
+			for name := range extension.Development.Entries {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				entry := fmt.Sprintf("entry %s of extension %s", name, extension.UUID)
+				template := getAssetPathTemplate(config, extension)
+				if resolved := path.Clean(strings.ReplaceAll(template, "{name}", name)); !strings.HasPrefix(resolved, "assets/") {
+					return fmt.Errorf("asset_path_template %q puts %s at %s, assets have to be below assets/", template, entry, resolved)
+				}
+
+				assetPath := getAssetPath(config, extension, name)
+				if conflict, found := entries[assetPath]; found {
+					return fmt.Errorf("%s and %s are both served at %s", conflict, entry, assetPath)
+				}
+				entries[assetPath] = entry
+			}
+		}
+	}
+	return nil
+}
+
+func validateDependencies(extensions []Extension) error {
+	uuids := make(map[string]bool, len(extensions))
+	for _, extension := range extensions {
+		uuids[extension.UUID] = true
+	}
+
+	for _, extension := range extensions {
+		for _, dependency := range extension.DependsOn {
+			if !uuids[dependency] {
+				return fmt.Errorf("extension %s depends on unknown extension %s", extension.UUID, dependency)
+			}
+		}
+	}
+
+	_, err := SortByDependencies(extensions)
+	return err
+}
+
+func validateRenderers(extensions []Extension) error {
+	for _, extension := range extensions {
+		renderer := extension.Development.Renderer
+		if _, err := ParseRenderer(renderer.Name); err != nil {
+			return fmt.Errorf("invalid renderer of extension %s: %w", extension.UUID, err)
+		}
+		if renderer.Version != "" && !IsValidVersion(renderer.Version) {
+			return fmt.Errorf("invalid renderer version %q of extension %s", renderer.Version, extension.UUID)
+		}
+	}
+	return nil
+}
+
+// appName restricts app names to slugs, they are part of the routes of the
+// app's extensions
+var appName = regexp.MustCompile(`^[a-z0-9]+(?:[-_][a-z0-9]+)*$`)
+
+func validateApps(apps []AppConfig) error {
+	names := make(map[string]bool)
+	for _, app := range apps {
+		if !appName.MatchString(app.Name) {
+			return fmt.Errorf("invalid app name %q, use lowercase letters, digits, dashes and underscores, e.g. checkout-app", app.Name)
+		}
+		if names[app.Name] {
+			return fmt.Errorf("duplicate app name %q", app.Name)
+		}
+		names[app.Name] = true
+	}
+	return nil
+}
+
 type Config struct {
 	Extensions []Extension `yaml:"extensions"`
 	Port       int
+	Verbose    bool `yaml:"verbose"`
+	// DefaultAccept is either application/json or text/html and decides what
+	// an extension's root URL serves when the client has no preference.
+	DefaultAccept string `yaml:"default_accept"`
+	// StrictSlash redirects requests to /extensions to /extensions/ and vice versa.
+	// It doesn't apply to POST endpoints.
+	StrictSlash bool `yaml:"strict_slash"`
+	// ListAssets exposes a JSON listing of the build directory at /extensions/{uuid}/assets/.
+	ListAssets bool `yaml:"list_assets"`
+	// MaxConnections limits the number of open websocket connections, defaults to 100
+	MaxConnections int `yaml:"max_connections"`
+	// RedirectStatus is the status code used to redirect / to /extensions/, defaults to 307
+	RedirectStatus int `yaml:"redirect_status"`
+	// ServeSourceMaps can be set to false to keep source maps in the build
+	// directory from being served, defaults to true
+	ServeSourceMaps *bool `yaml:"serve_source_maps"`
+	// EscapeHTML can be set to false to keep <, > and & in JSON responses,
+	// e.g. in URLs, rather than escaping them as \u003c and so on
+	EscapeHTML *bool `yaml:"escape_html"`
+	// BuildConcurrency is the number of parallel jobs shared by all extensions
+	// built at the same time, defaults to the number of CPUs
+	BuildConcurrency int `yaml:"build_concurrency"`
+	// ApiVersion overrides the manifest version reported to hosts, defaults to 0.1.0
+	ApiVersion string `yaml:"api_version"`
+	// AssetPathTemplate is the default asset_path_template of all extensions
+	AssetPathTemplate string `yaml:"asset_path_template"`
+	// AssetHeaders are added to the responses of all assets, extensions can
+	// override them with their own asset_headers
+	AssetHeaders map[string]string `yaml:"asset_headers"`
+	// AssetRoot is the path the assets are served below instead of the root
+	// of the manifest, e.g. /cdn for assets at /cdn/extensions/{uuid}/assets/
+	AssetRoot string `yaml:"asset_root"`
+	// ReadHeaderTimeout, ReadTimeout and IdleTimeout configure the HTTP server,
+	// e.g. 10s. Zero picks a default, negative values disable the timeout.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout is the grace period in-flight requests and websocket
+	// clients get to finish once the server shuts down, 5s by default.
+	// Negative values leave no grace period rather than disabling it.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// NotifyDebounce is the window in which status updates of an extension
+	// are coalesced, 150ms by default. Negative values disable debouncing.
+	NotifyDebounce time.Duration `yaml:"notify_debounce"`
+	// MaxConcurrentAssetReads limits the assets served at the same time,
+	// further requests wait for a slot. Zero means no limit.
+	MaxConcurrentAssetReads int `yaml:"max_concurrent_asset_reads"`
+	// AssetLastModified adds the modification time of the built files to the
+	// assets of the manifest, so hosts can tell whether an asset changed
+	AssetLastModified bool `yaml:"asset_last_modified"`
+	// MaxRequestBodySize is the largest request body in bytes the server
+	// accepts, 1 MiB by default. Negative values disable the limit.
+	MaxRequestBodySize int64 `yaml:"max_request_body_size"`
+	// Cors allows hosts on other origins to access the server
+	Cors CorsConfig `yaml:"cors"`
+	// Framing overrides the pages allowed to embed the preview of extensions,
+	// keyed by surface, e.g. checkout or admin
+	Framing map[string]FramingPolicy `yaml:"framing"`
+	// PublicUrl is the URL hosts reach the server at, e.g. of a tunnel, which
+	// the URLs of the manifest start with instead of http://localhost:<port>
+	PublicUrl string `yaml:"public_url"`
+	// InsecurePublicUrl decides what happens when the public_url uses http
+	// for extensions on surfaces loaded over https: warn, the default, or
+	// upgrade their URLs to https
+	InsecurePublicUrl string `yaml:"insecure_public_url"`
+	// NotFoundRedirect is a URL or absolute path browsers requesting the
+	// page of an unknown extension are redirected to instead of a 404
+	NotFoundRedirect string `yaml:"not_found_redirect"`
+	// UpstreamProxy is the URL of a dev server, e.g. Vite, that requests not
+	// matching any route are proxied to instead of redirecting or failing
+	UpstreamProxy string `yaml:"upstream_proxy"`
+	// Store is the shop the extensions are previewed on
+	Store string `yaml:"store"`
+	// Apps are served alongside the extensions above, each under /apps/<name>
+	Apps []AppConfig `yaml:"apps"`
+	// ApiRoot is the path the extensions are served below, empty for the
+	// top level extensions
+	ApiRoot string `yaml:"-"`
+	// DebugEndpoints exposes the complete configuration of each extension at
+	// /extensions/{uuid}/debug, it's enabled with serve --debug-endpoints
+	DebugEndpoints bool `yaml:"-"`
+	// AssetsOnly only serves the build directories, without manifest and
+	// status updates, it's enabled with serve --assets-only
+	AssetsOnly bool `yaml:"-"`
+	// ServerVersion and ServerCommit identify the binary serving the
+	// extensions, they are reported at /status
+	ServerVersion string `yaml:"-"`
+	ServerCommit  string `yaml:"-"`
+}
+
+type CorsConfig struct {
+	// AllowedOrigins lists the origins allowed to access the server, * allows
+	// all origins. CORS is disabled when empty.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// AllowedMethods defaults to GET, POST and OPTIONS
+	AllowedMethods []string `yaml:"allowed_methods"`
+	// AllowedHeaders defaults to the headers requested by the browser
+	AllowedHeaders []string `yaml:"allowed_headers"`
+	// MaxAge is how long browsers cache preflight results, defaults to 10m
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+type FramingPolicy struct {
+	// FrameAncestors are the sources of the frame-ancestors directive of the
+	// Content-Security-Policy, e.g. 'self' or https://*.myshopify.com
+	FrameAncestors []string `yaml:"frame_ancestors"`
+	// XFrameOptions is sent to browsers that don't support frame-ancestors,
+	// it's derived from FrameAncestors when they only allow 'self' or 'none'
+	XFrameOptions string `yaml:"x_frame_options"`
+}
+
+type AppConfig struct {
+	Name       string      `yaml:"name"`
+	Store      string      `yaml:"store"`
+	Extensions []Extension `yaml:"extensions"`
 }
 
 type ExtensionService struct {
 	Extensions []Extension
 	Version    string
+	// Warnings point out likely misconfigurations, see Warnings
+	Warnings []string
 }
 
 type Extension struct {
 	Type        string      `json:"type" yaml:"type"`
 	UUID        string      `json:"uuid" yaml:"uuid"`
+	Title       string      `json:"title" yaml:"title"`
+	Description string      `json:"description" yaml:"description"`
 	Assets      []Asset     `json:"assets" yaml:"-"`
 	Development Development `json:"development" yaml:"development"`
 	User        User        `json:"user" yaml:"user"`
 	App         App         `json:"app" yaml:"-"`
 	Version     string      `json:"version" yaml:"version"`
+	// Icon links to the icon configured in the development settings, it's
+	// omitted when there is none
+	Icon *Url `json:"icon,omitempty" yaml:"-"`
+	// DependsOn lists the UUIDs of extensions that have to be built first
+	DependsOn []string `json:"-" yaml:"depends_on"`
+	// Group names a set of related extensions hosts can present together
+	Group string `json:"group,omitempty" yaml:"group"`
+	// Capabilities are passed to hosts as is, e.g. experimental features the
+	// extension opts into. The server doesn't interpret them.
+	Capabilities map[string]interface{} `json:"capabilities" yaml:"capabilities"`
+	// Labels tag extensions for filtering, e.g. by the team owning them
+	Labels []string `json:"labels" yaml:"labels"`
 }
 
 type Asset struct {
 	Name string `json:"name" yaml:"name"`
 	Url  string `json:"url" yaml:"url"`
+	// LastModified is the modification time of the built file, it's only
+	// reported with asset_last_modified enabled and once the file exists
+	LastModified *time.Time `json:"lastModified,omitempty" yaml:"-"`
 }
 
 type Development struct {
@@ -77,6 +745,40 @@ type Development struct {
 	RootDir  string            `json:"-" yaml:"root_dir"`
 	Template string            `json:"-"`
 	Entries  map[string]string `json:"-"`
+	// MimeTypes maps file extensions, e.g. .liquid, to the content type their assets are served with
+	MimeTypes map[string]string `json:"-" yaml:"mime_types"`
+	// AssetHeaders are added to the responses of the extension's assets,
+	// e.g. Cross-Origin-Resource-Policy, replacing global asset_headers
+	AssetHeaders map[string]string `json:"-" yaml:"asset_headers"`
+	// PreviewToken has to be passed as ?token= or X-Preview-Token header to
+	// access the extension's root URL when set
+	PreviewToken string `json:"-" yaml:"preview_token"`
+	// AssetPathTemplate is the path of the assets relative to the extension's
+	// URL, e.g. assets/js/{name}.js, defaults to assets/{name}.js
+	AssetPathTemplate string `json:"-" yaml:"asset_path_template"`
+	// Icon is the path of an image relative to the root directory, e.g.
+	// build/icon.png, which hosts show next to the extension
+	Icon string `json:"-" yaml:"icon"`
+	// NodeVersion is the Node.js version required to build the extension,
+	// e.g. >=16, defaults to engines.node of its package.json
+	NodeVersion string `json:"-" yaml:"node_version"`
+	// PreBuild and PostBuild are shell commands run in the root directory
+	// before and after the build script, e.g. to generate GraphQL types
+	PreBuild  []string `json:"-" yaml:"pre_build"`
+	PostBuild []string `json:"-" yaml:"post_build"`
+	// Timeouts limit how long each step of a production build may take
+	Timeouts StepTimeouts `json:"-" yaml:"timeouts"`
+	// IsolatedBuild runs the build script of production builds in a copy of
+	// the root directory, so that caches the build tool writes into the
+	// source tree aren't shared with concurrent builds
+	IsolatedBuild bool `json:"-" yaml:"isolated_build"`
+}
+
+// StepTimeouts are durations like 2m, zero means no timeout
+type StepTimeouts struct {
+	PreBuild  time.Duration `yaml:"pre_build"`
+	Build     time.Duration `yaml:"build"`
+	PostBuild time.Duration `yaml:"post_build"`
 }
 
 type Renderer struct {
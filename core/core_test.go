@@ -1,8 +1,12 @@
 package core_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Shopify/shopify-cli-extensions/core"
 )
@@ -35,6 +39,436 @@ extensions:
 	}
 }
 
+func TestLoadConfigTimeouts(t *testing.T) {
+	config, err := core.LoadConfig(strings.NewReader("read_header_timeout: 5s\nidle_timeout: 2m\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.ReadHeaderTimeout != 5*time.Second || config.IdleTimeout != 2*time.Minute || config.ReadTimeout != 0 {
+		t.Errorf("Unexpected timeouts %s, %s, %s", config.ReadHeaderTimeout, config.ReadTimeout, config.IdleTimeout)
+	}
+}
+
+func TestLoadConfigRejectsInvalidAppNames(t *testing.T) {
+	for _, names := range [][]string{{""}, {"a/b"}, {"a", "a"}, {"{uuid}"}, {"my app"}, {"Admin"}, {"../admin"}, {"-admin"}, {"a%2Fb"}} {
+		serializedConfig := "apps:\n"
+		for _, name := range names {
+			serializedConfig += fmt.Sprintf("  - name: %q\n", name)
+		}
+
+		if _, err := core.LoadConfig(strings.NewReader(serializedConfig)); err == nil {
+			t.Errorf("Expected an error for app names %q", names)
+		}
+	}
+}
+
+func TestLoadConfigAcceptsAppSlugs(t *testing.T) {
+	serializedConfig := "apps:\n  - name: checkout-app\n  - name: admin_2\n"
+	if _, err := core.LoadConfig(strings.NewReader(serializedConfig)); err != nil {
+		t.Errorf("Expected slugs to be valid app names, got %v", err)
+	}
+}
+
+func TestLoadConfigRejectsIconsOutsideTheExtension(t *testing.T) {
+	for _, icon := range []string{"../icon.png", "build/../../icon.png", "/etc/icon.png"} {
+		serializedConfig := fmt.Sprintf("extensions:\n  - uuid: \"123\"\n    development:\n      icon: %q\n", icon)
+		if _, err := core.LoadConfig(strings.NewReader(serializedConfig)); err == nil {
+			t.Errorf("Expected an error for icon %q", icon)
+		}
+	}
+
+	config, err := core.LoadConfig(strings.NewReader("extensions:\n  - uuid: \"123\"\n    development:\n      icon: build/../icon.png\n"))
+	if err != nil {
+		t.Fatalf("Expected icons within the extension to be accepted, got %v", err)
+	}
+
+	if icon := core.NewExtensionService(config).Extensions[0].Icon; icon == nil || icon.Url != "http://localhost:0/extensions/123/icon" {
+		t.Errorf("Expected the icon URL to be part of the extension, got %+v", icon)
+	}
+}
+
+func TestLoadConfigRejectsInvalidUpstreamProxy(t *testing.T) {
+	for _, upstream := range []string{"localhost:5173", "ftp://localhost", "http://"} {
+		if _, err := core.LoadConfig(strings.NewReader(fmt.Sprintf("upstream_proxy: %q\n", upstream))); err == nil {
+			t.Errorf("Expected an error for upstream_proxy %q", upstream)
+		}
+	}
+}
+
+func TestLoadConfigRejectsDuplicateAssetPaths(t *testing.T) {
+	tests := []struct {
+		config   string
+		conflict string
+	}{
+		{
+			"extensions:\n  - uuid: \"123\"\n    development:\n      entries:\n        main: src/index.js\n  - uuid: \"123\"\n    development:\n      entries:\n        main: src/other.js\n",
+			"entry main of extension 123 and entry main of extension 123 are both served at /extensions/123/assets/main.js",
+		},
+		{
+			"extensions:\n  - uuid: \"123\"\n    development:\n      asset_path_template: assets/bundle.js\n      entries:\n        main: src/index.js\n        polyfills: src/polyfills.js\n",
+			"entry main of extension 123 and entry polyfills of extension 123 are both served at /extensions/123/assets/bundle.js",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := core.LoadConfig(strings.NewReader(test.config))
+		if err == nil || !strings.Contains(err.Error(), test.conflict) {
+			t.Errorf("Expected error about %q, got %v", test.conflict, err)
+		}
+	}
+
+	// The same UUID in different apps is served below different roots
+	serializedConfig := "extensions:\n  - uuid: \"123\"\n    development:\n      entries:\n        main: src/index.js\napps:\n  - name: other\n    extensions:\n      - uuid: \"123\"\n        development:\n          entries:\n            main: src/index.js\n"
+	if _, err := core.LoadConfig(strings.NewReader(serializedConfig)); err != nil {
+		t.Errorf("Expected entries of different apps not to conflict, got %v", err)
+	}
+}
+
+func TestLoadConfigRejectsAssetPathsOutsideAssets(t *testing.T) {
+	for _, template := range []string{"{name}.js", "/js/{name}.js", "assets/../{name}.js", "assets", "assets/"} {
+		serializedConfig := fmt.Sprintf("asset_path_template: %q\nextensions:\n  - uuid: \"123\"\n    development:\n      entries:\n        main: src/index.js\n", template)
+		_, err := core.LoadConfig(strings.NewReader(serializedConfig))
+		if err == nil || !strings.Contains(err.Error(), "assets have to be below assets/") {
+			t.Errorf("Expected asset_path_template %q to be rejected, got %v", template, err)
+		}
+	}
+
+	serializedConfig := "extensions:\n  - uuid: \"123\"\n    development:\n      entries:\n        ../main: src/index.js\n"
+	if _, err := core.LoadConfig(strings.NewReader(serializedConfig)); err == nil {
+		t.Error("Expected an entry name leaving assets/ to be rejected")
+	}
+}
+
+func TestCapabilitiesArePassedThrough(t *testing.T) {
+	serializedConfig := "extensions:\n  - uuid: \"123\"\n    capabilities:\n      network_access: true\n      block_progress: false\n      api_access:\n        scopes: [read_products]\n  - uuid: \"456\"\n"
+	config, err := core.LoadConfig(strings.NewReader(serializedConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized, err := json.Marshal(core.NewExtensionService(config).Extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extensions := []core.Extension{}
+	if err := json.Unmarshal(serialized, &extensions); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"network_access": true,
+		"block_progress": false,
+		"api_access":     map[string]interface{}{"scopes": []interface{}{"read_products"}},
+	}
+	if !reflect.DeepEqual(extensions[0].Capabilities, expected) {
+		t.Errorf("Expected capabilities %v, got %v", expected, extensions[0].Capabilities)
+	}
+
+	if !strings.Contains(string(serialized), `"capabilities":{}`) || extensions[1].Capabilities == nil {
+		t.Errorf("Expected capabilities to default to an empty object, got %s", serialized)
+	}
+}
+
+func TestNewExtensionServiceDefaultsTitle(t *testing.T) {
+	config := &core.Config{Extensions: []core.Extension{
+		{UUID: "1", Type: "checkout_ui_extension"},
+		{UUID: "2", Type: "product_subscription", Title: "Subscriptions", Description: "Manage plans"},
+	}}
+
+	service := core.NewExtensionService(config)
+
+	if service.Extensions[0].Title != "Checkout UI Extension" {
+		t.Errorf("Expected title to default to the humanized type, got %q", service.Extensions[0].Title)
+	}
+
+	if service.Extensions[1].Title != "Subscriptions" || service.Extensions[1].Description != "Manage plans" {
+		t.Errorf("Expected configured title and description to be kept, got %+v", service.Extensions[1])
+	}
+}
+
+func TestNewExtensionServiceWithoutEntries(t *testing.T) {
+	config := &core.Config{Extensions: []core.Extension{{UUID: "1", Type: "checkout_ui_extension"}}}
+
+	service := core.NewExtensionService(config)
+
+	manifest, err := json.Marshal(service.Extensions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest), `"assets":[]`) {
+		t.Errorf("Expected an empty assets array, got %s", manifest)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	config := &core.Config{Extensions: []core.Extension{
+		{UUID: "1", Type: "checkout_ui_extension", Development: core.Development{Entries: map[string]string{"main": "src/index.js"}}},
+		{UUID: "2", Type: "product_subscription"},
+	}}
+
+	expected := []string{
+		"extension 1 renders in checkout, set store to preview it on a shop",
+		"extension 2 has no entries, check the entries of its development section",
+	}
+	if warnings := core.Warnings(config); !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, warnings)
+	}
+
+	config.Store = "my-shop.myshopify.com"
+	if warnings := core.Warnings(config); len(warnings) != 1 {
+		t.Errorf("Expected no store warning with a store, got %v", warnings)
+	}
+}
+
+func TestNewExtensionServiceApiVersion(t *testing.T) {
+	if version := core.NewExtensionService(&core.Config{}).Version; version != "0.1.0" {
+		t.Errorf("Expected default version 0.1.0, got %s", version)
+	}
+
+	if version := core.NewExtensionService(&core.Config{ApiVersion: "2.0.0"}).Version; version != "2.0.0" {
+		t.Errorf("Expected configured version 2.0.0, got %s", version)
+	}
+}
+
+func TestNewExtensionServiceAssetPathTemplate(t *testing.T) {
+	config := &core.Config{
+		Port:              8000,
+		AssetPathTemplate: "assets/js/{name}.js",
+		Extensions: []core.Extension{
+			{UUID: "1", Development: core.Development{Entries: map[string]string{"main": "src/index.js"}}},
+			{UUID: "2", Development: core.Development{
+				Entries:           map[string]string{"main": "src/index.js"},
+				AssetPathTemplate: "assets/{name}.abc123.js",
+			}},
+		},
+	}
+
+	service := core.NewExtensionService(config)
+
+	if url := service.Extensions[0].Assets[0].Url; url != "http://localhost:8000/extensions/1/assets/js/main.js" {
+		t.Errorf("Expected the global template to be used, got %s", url)
+	}
+
+	if url := service.Extensions[1].Assets[0].Url; url != "http://localhost:8000/extensions/2/assets/main.abc123.js" {
+		t.Errorf("Expected the extension's template to be used, got %s", url)
+	}
+}
+
+func TestNewExtensionServiceAssetRoot(t *testing.T) {
+	config := &core.Config{
+		Port:      8000,
+		AssetRoot: "/cdn/",
+		Extensions: []core.Extension{
+			{UUID: "1", Development: core.Development{Entries: map[string]string{"main": "src/index.js"}}},
+		},
+		Apps: []core.AppConfig{{Name: "admin"}},
+	}
+
+	service := core.NewExtensionService(config)
+
+	if url := service.Extensions[0].Assets[0].Url; url != "http://localhost:8000/cdn/extensions/1/assets/main.js" {
+		t.Errorf("Expected the asset to be served below the asset root, got %s", url)
+	}
+
+	if base := config.ForApp(config.Apps[0]).AssetBase(); base != "/cdn/apps/admin" {
+		t.Errorf("Expected the assets of apps to be served below the asset root, got %s", base)
+	}
+}
+
+func TestLoadConfigRejectsInvalidAssetHeaders(t *testing.T) {
+	configs := []string{
+		"asset_headers:\n  \"Cache Control\": no-cache\n",
+		"asset_headers:\n  X-Custom: \"a\\r\\nSet-Cookie: b\"\n",
+		"extensions:\n  - uuid: \"1\"\n    development:\n      asset_headers:\n        \"X:Custom\": value\n",
+	}
+	for _, config := range configs {
+		if _, err := core.LoadConfig(strings.NewReader(config)); err == nil {
+			t.Errorf("Expected an error for %q", config)
+		}
+	}
+}
+
+func TestLoadConfigRejectsInvalidRedirectStatus(t *testing.T) {
+	for _, status := range []int{200, 304, 404, -1} {
+		_, err := core.LoadConfig(strings.NewReader(fmt.Sprintf("redirect_status: %d\n", status)))
+		if err == nil || !strings.Contains(err.Error(), "invalid redirect_status") {
+			t.Errorf("Expected an error for redirect_status %d, got %v", status, err)
+		}
+	}
+
+	if _, err := core.LoadConfig(strings.NewReader("redirect_status: 308\n")); err != nil {
+		t.Errorf("Expected redirect_status 308 to be accepted, got %v", err)
+	}
+}
+
+func TestLoadConfigRejectsInvalidNotFoundRedirect(t *testing.T) {
+	for _, target := range []string{"extensions", "//example.com", "javascript:alert(1)", "https://"} {
+		if _, err := core.LoadConfig(strings.NewReader(fmt.Sprintf("not_found_redirect: %q\n", target))); err == nil {
+			t.Errorf("Expected an error for not_found_redirect %q", target)
+		}
+	}
+}
+
+func TestPublicUrl(t *testing.T) {
+	config := &core.Config{
+		Port:      8000,
+		Store:     "my-shop.myshopify.com",
+		PublicUrl: "http://my-tunnel.example.com/",
+		Extensions: []core.Extension{
+			{UUID: "1", Type: "checkout_ui_extension", Development: core.Development{Entries: map[string]string{"main": "src/index.js"}}},
+			{UUID: "2", Type: "pos_ui_extension", Development: core.Development{Entries: map[string]string{"main": "src/index.js"}}},
+		},
+	}
+
+	expected := []string{"extension 1 renders in checkout, which blocks its assets from the http public_url, use https or set insecure_public_url: upgrade"}
+	if warnings := core.Warnings(config); !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Expected warnings %v, got %v", expected, warnings)
+	}
+
+	config.InsecurePublicUrl = "upgrade"
+	if warnings := core.Warnings(config); len(warnings) != 0 {
+		t.Errorf("Expected no warnings once upgraded, got %v", warnings)
+	}
+
+	service := core.NewExtensionService(config)
+	if url := service.Extensions[0].Assets[0].Url; url != "https://my-tunnel.example.com/extensions/1/assets/main.js" {
+		t.Errorf("Expected the asset of the checkout extension to be upgraded to https, got %s", url)
+	}
+	if url := service.Extensions[1].Assets[0].Url; url != "http://my-tunnel.example.com/extensions/2/assets/main.js" {
+		t.Errorf("Expected the asset of the POS extension to keep the public URL, got %s", url)
+	}
+}
+
+func TestLoadConfigRejectsInvalidPublicUrl(t *testing.T) {
+	configs := []string{
+		"public_url: my-tunnel.example.com\n",
+		"public_url: ftp://my-tunnel.example.com\n",
+		"public_url: https://my-tunnel.example.com?token=1\n",
+		"insecure_public_url: ignore\n",
+	}
+	for _, config := range configs {
+		if _, err := core.LoadConfig(strings.NewReader(config)); err == nil {
+			t.Errorf("Expected an error for %q", config)
+		}
+	}
+}
+
+func TestLoadConfigRejectsInvalidAssetRoot(t *testing.T) {
+	for _, assetRoot := range []string{"cdn", "/cdn/../assets", "//cdn"} {
+		if _, err := core.LoadConfig(strings.NewReader(fmt.Sprintf("asset_root: %q\n", assetRoot))); err == nil {
+			t.Errorf("Expected an error for asset_root %q", assetRoot)
+		}
+	}
+}
+
+func TestParseRenderer(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected core.Renderer
+		valid    bool
+	}{
+		{"@shopify/checkout-ui-extensions", core.Renderer{Name: "@shopify/checkout-ui-extensions"}, true},
+		{"@shopify/checkout-ui-extensions@0.12.0", core.Renderer{Name: "@shopify/checkout-ui-extensions", Version: "0.12.0"}, true},
+		{"react@18", core.Renderer{Name: "react", Version: "18"}, true},
+		{"react@^17.0.0-rc.1", core.Renderer{Name: "react", Version: "^17.0.0-rc.1"}, true},
+		{"react@latest", core.Renderer{}, false},
+		{"react@1.2.3.4", core.Renderer{}, false},
+	}
+
+	for _, test := range tests {
+		renderer, err := core.ParseRenderer(test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("Expected %s to be valid: %v, got %v", test.value, test.valid, err)
+			continue
+		}
+		if test.valid && renderer != test.expected {
+			t.Errorf("Expected %s to be parsed as %+v, got %+v", test.value, test.expected, renderer)
+		}
+	}
+
+	config := &core.Config{Extensions: []core.Extension{
+		{UUID: "1", Development: core.Development{Renderer: core.Renderer{Name: "react@18"}}},
+	}}
+	if renderer := core.NewExtensionService(config).Extensions[0].Development.Renderer; renderer.Name != "react" || renderer.Version != "18" {
+		t.Errorf("Expected the renderer version to be split off in the manifest, got %+v", renderer)
+	}
+}
+
+func TestSortByDependencies(t *testing.T) {
+	extensions := []core.Extension{
+		{UUID: "1", DependsOn: []string{"3"}},
+		{UUID: "2"},
+		{UUID: "3", DependsOn: []string{"2", "filtered"}},
+	}
+
+	sorted, err := core.SortByDependencies(extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make([]string, 0)
+	for _, extension := range sorted {
+		order = append(order, extension.UUID)
+	}
+	if strings.Join(order, ",") != "2,3,1" {
+		t.Errorf("Expected dependencies to be built first, got %v", order)
+	}
+
+	extensions[1].DependsOn = []string{"1"}
+	if _, err := core.SortByDependencies(extensions); err == nil || !strings.Contains(err.Error(), "1 -> 3 -> 2 -> 1") {
+		t.Errorf("Expected the cycle to be reported, got %v", err)
+	}
+}
+
+func TestLoadConfigRejectsUnknownDependencies(t *testing.T) {
+	serializedConfig := "extensions:\n  - uuid: \"1\"\n    depends_on: [\"2\"]\n"
+	if _, err := core.LoadConfig(strings.NewReader(serializedConfig)); err == nil {
+		t.Error("Expected an error for a dependency on an unknown extension")
+	}
+}
+
+func TestFilterExtensions(t *testing.T) {
+	extensions := []core.Extension{
+		{UUID: "1", Type: "checkout_ui_extension", Labels: []string{"team-checkout"}},
+		{UUID: "2", Type: "checkout_post_purchase", Labels: []string{"team-checkout", "experimental"}},
+		{UUID: "3", Type: "product_subscription"},
+		{UUID: "4", Type: "admin_ui_extension", Labels: []string{"experimental"}},
+	}
+
+	tests := []struct {
+		filters  []string
+		expected []string
+	}{
+		{[]string{}, []string{"1", "2", "3", "4"}},
+		{[]string{""}, []string{"1", "2", "3", "4"}},
+		{[]string{"3"}, []string{"3"}},
+		{[]string{"type:checkout"}, []string{"1", "2"}},
+		{[]string{"type:*_ui_extension"}, []string{"1", "4"}},
+		{[]string{"type:checkout_ui_extension"}, []string{"1"}},
+		{[]string{"type:checkout", "3"}, []string{"1", "2", "3"}},
+		{[]string{"type:checkout", "1"}, []string{"1", "2"}},
+		{[]string{"type:unknown"}, []string{}},
+		{[]string{"label:team-checkout"}, []string{"1", "2"}},
+		{[]string{"label:experimental", "3"}, []string{"2", "3", "4"}},
+		{[]string{"label:team"}, []string{}},
+	}
+
+	for _, test := range tests {
+		filtered := core.FilterExtensions(extensions, test.filters)
+
+		uuids := make([]string, 0, len(filtered))
+		for _, extension := range filtered {
+			uuids = append(uuids, extension.UUID)
+		}
+
+		if strings.Join(uuids, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("filters %v: expected extensions %v, got %v", test.filters, test.expected, uuids)
+		}
+	}
+}
+
 func formatYAML(s string) string {
 	return strings.Replace(s, "\t", "  ", -1)
 }
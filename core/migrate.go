@@ -0,0 +1,158 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configVersionKey records the last migration applied to a configuration
+const configVersionKey = "config_version"
+
+// Migration rewrites a configuration written for an older schema. Migrations
+// work on the YAML document rather than on Config, so that comments and the
+// order of keys survive.
+type Migration struct {
+	// Version is recorded as config_version once the migration is applied,
+	// versions have to increase with each migration
+	Version     int
+	Description string
+	Apply       func(document *yaml.Node) error
+}
+
+// Migrations are applied in order to configurations with a lower
+// config_version. Add a migration whenever a field is renamed or moved.
+var Migrations = []Migration{
+	{1, "split renderer name@version shorthands into name and version", migrateRendererShorthand},
+}
+
+// MigrateConfig applies the migrations the YAML configuration is missing and
+// returns the updated configuration along with the applied migrations.
+// Configurations that are up to date are returned unchanged.
+func MigrateConfig(content []byte) ([]byte, []Migration, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, nil, err
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New("the configuration has to be a YAML mapping")
+	}
+	root := document.Content[0]
+
+	version := 0
+	if node := mappingValue(root, configVersionKey); node != nil {
+		var err error
+		if version, err = strconv.Atoi(node.Value); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s %q", configVersionKey, node.Value)
+		}
+	}
+
+	applied := make([]Migration, 0)
+	for _, migration := range Migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err := migration.Apply(root); err != nil {
+			return nil, nil, fmt.Errorf("migration %d failed: %w", migration.Version, err)
+		}
+		applied = append(applied, migration)
+		version = migration.Version
+	}
+
+	if len(applied) == 0 {
+		return content, applied, nil
+	}
+
+	setMappingValue(root, configVersionKey, strconv.Itoa(version), true)
+
+	var migrated bytes.Buffer
+	encoder := yaml.NewEncoder(&migrated)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return nil, nil, err
+	}
+	return migrated.Bytes(), applied, encoder.Close()
+}
+
+// migrateRendererShorthand rewrites renderers configured as name@version,
+// which hosts received as is before the version was split off when serving.
+func migrateRendererShorthand(root *yaml.Node) error {
+	for _, extension := range extensionNodes(root) {
+		renderer := mappingValue(mappingValue(extension, "development"), "renderer")
+		name := mappingValue(renderer, "name")
+		if name == nil {
+			continue
+		}
+
+		parsed, err := ParseRenderer(name.Value)
+		if err != nil {
+			return err
+		}
+		if parsed.Version == "" || mappingValue(renderer, "version") != nil {
+			continue
+		}
+
+		name.Value = parsed.Name
+		// Quoted so versions like 1.10 aren't read as numbers
+		setMappingValue(renderer, "version", parsed.Version, false).Style = yaml.DoubleQuotedStyle
+	}
+	return nil
+}
+
+// extensionNodes returns the extensions at the top level and of all apps
+func extensionNodes(root *yaml.Node) []*yaml.Node {
+	extensions := make([]*yaml.Node, 0)
+	if list := mappingValue(root, "extensions"); list != nil {
+		extensions = append(extensions, list.Content...)
+	}
+	if apps := mappingValue(root, "apps"); apps != nil {
+		for _, app := range apps.Content {
+			if list := mappingValue(app, "extensions"); list != nil {
+				extensions = append(extensions, list.Content...)
+			}
+		}
+	}
+	return extensions
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for index := 0; index+1 < len(mapping.Content); index += 2 {
+		if mapping.Content[index].Value == key {
+			return mapping.Content[index+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue updates a scalar of the mapping or adds it, either as the
+// first or the last key, and returns the value node
+func setMappingValue(mapping *yaml.Node, key, value string, first bool) *yaml.Node {
+	if node := mappingValue(mapping, key); node != nil {
+		node.Kind = yaml.ScalarNode
+		node.Tag = ""
+		node.Value = value
+		return node
+	}
+
+	pair := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: key},
+		{Kind: yaml.ScalarNode, Value: value},
+	}
+	if first {
+		// Keep the comment at the top of the file above the new key
+		if len(mapping.Content) > 0 {
+			pair[0].HeadComment = mapping.Content[0].HeadComment
+			mapping.Content[0].HeadComment = ""
+		}
+		mapping.Content = append(pair, mapping.Content...)
+	} else {
+		mapping.Content = append(mapping.Content, pair...)
+	}
+	return pair[1]
+}
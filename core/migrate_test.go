@@ -0,0 +1,71 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	original := `# local development
+port: 8000
+extensions:
+  - uuid: "123"
+    type: checkout_ui_extension
+    development:
+      renderer:
+        name: "@shopify/checkout-ui-extensions-react@^0.12.0" # pinned
+apps:
+  - name: shop-a
+    extensions:
+      - uuid: "456"
+        development:
+          renderer:
+            name: "@shopify/checkout-ui-extensions"
+`
+
+	migrated, applied, err := core.MigrateConfig([]byte(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != len(core.Migrations) {
+		t.Errorf("Expected all migrations to be applied, got %d", len(applied))
+	}
+
+	for _, expected := range []string{
+		"# local development\nconfig_version: 1\nport: 8000\n",
+		`name: "@shopify/checkout-ui-extensions-react" # pinned`,
+		`version: "^0.12.0"`,
+		`name: "@shopify/checkout-ui-extensions"`,
+	} {
+		if !strings.Contains(string(migrated), expected) {
+			t.Errorf("Expected the migrated configuration to contain %q, got\n%s", expected, migrated)
+		}
+	}
+
+	config, err := core.LoadConfig(strings.NewReader(string(migrated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renderer := config.Extensions[0].Development.Renderer; renderer.Name != "@shopify/checkout-ui-extensions-react" || renderer.Version != "^0.12.0" {
+		t.Errorf("Unexpected renderer %+v", renderer)
+	}
+
+	again, applied, err := core.MigrateConfig(migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 || string(again) != string(migrated) {
+		t.Errorf("Expected an up to date configuration to be left alone, got\n%s", again)
+	}
+}
+
+func TestMigrateConfigErrors(t *testing.T) {
+	for _, content := range []string{"- not a mapping", "config_version: latest", "port: ["} {
+		if _, _, err := core.MigrateConfig([]byte(content)); err == nil {
+			t.Errorf("Expected an error migrating %q", content)
+		}
+	}
+}
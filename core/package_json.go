@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+
+	"gopkg.in/yaml.v3"
+)
+
+// packageJSONKey is the package.json field holding the configuration
+const packageJSONKey = "shopify"
+
+// LoadPackageJSONConfig loads the configuration kept under the shopify key of
+// a package.json, which lets projects keep a single source of truth instead
+// of a separate shopifile.yml. The field uses the same keys as the YAML
+// format:
+//
+//	{
+//	  "name": "my-extension",
+//	  "shopify": {
+//	    "port": 8000,
+//	    "extensions": [{"uuid": "123", "type": "checkout_ui_extension", ...}]
+//	  }
+//	}
+func LoadPackageJSONConfig(r io.Reader) (config *Config, err error) {
+	var pkg map[string]interface{}
+	if err = json.NewDecoder(r).Decode(&pkg); err != nil {
+		return
+	}
+
+	document, ok := pkg[packageJSONKey].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("package.json has no shopify configuration")
+	}
+
+	// Like for TOML, the document has the same shape as the YAML one, which
+	// lets us reuse the yaml tags of the configuration.
+	content, err := yaml.Marshal(normalizeJSONNumbers(document))
+	if err != nil {
+		return
+	}
+
+	config = &Config{}
+	if err = yaml.Unmarshal(content, config); err != nil {
+		return
+	}
+
+	err = validateConfig(config)
+	return
+}
+
+// normalizeJSONNumbers turns whole numbers, which JSON decodes as floats,
+// back into integers so that they can be loaded into integer settings.
+func normalizeJSONNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range value {
+			value[key] = normalizeJSONNumbers(nested)
+		}
+	case []interface{}:
+		for index, nested := range value {
+			value[index] = normalizeJSONNumbers(nested)
+		}
+	case float64:
+		if value == math.Trunc(value) && math.Abs(value) < 1<<53 {
+			return int64(value)
+		}
+	}
+	return value
+}
@@ -0,0 +1,84 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadPackageJSONConfigRoundTrip(t *testing.T) {
+	original, err := core.LoadConfig(strings.NewReader(`
+port: 8000
+list_assets: true
+read_timeout: 1m
+extensions:
+  - type: checkout_ui_extension
+    uuid: "123"
+    title: My Extension
+    development:
+      root_dir: extensions/my-extension
+      build_dir: build
+      entries:
+        main: src/index.js
+      renderer:
+        name: "@shopify/checkout-ui-extensions"
+  - type: product_subscription
+    uuid: "456"
+    depends_on: ["123"]
+    user:
+      metafields:
+        - namespace: my-namespace
+          key: my-key
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := yaml.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	packageJSON, err := json.Marshal(map[string]interface{}{
+		"name":    "my-extension",
+		"license": "MIT",
+		"shopify": document,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := core.LoadPackageJSONConfig(bytes.NewReader(packageJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty lists and maps are serialized, so compare the serialized forms
+	// rather than nil and empty values
+	roundTripped, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(roundTripped) != string(content) {
+		t.Errorf("Expected the configuration to survive the round trip\n%s\ngot\n%s", content, roundTripped)
+	}
+
+	if config.Port != 8000 || config.ReadTimeout != time.Minute || config.Extensions[1].DependsOn[0] != "123" {
+		t.Errorf("Unexpected configuration %+v", config)
+	}
+}
+
+func TestLoadPackageJSONConfigWithoutShopifyKey(t *testing.T) {
+	if _, err := core.LoadPackageJSONConfig(strings.NewReader(`{"name": "my-extension"}`)); err == nil {
+		t.Error("Expected an error loading a package.json without configuration")
+	}
+}
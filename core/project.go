@@ -0,0 +1,101 @@
+package core
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile marks the root of an extension project, create writes it next
+// to the extension's shopifile.yml:
+//
+//	project_type: :extension
+//	EXTENSION_TYPE: CHECKOUT_UI_EXTENSION
+const ProjectFile = ".shopify-cli.yml"
+
+// projectExtensionFile holds the settings of the extension of a project
+const projectExtensionFile = "shopifile.yml"
+
+type projectFile struct {
+	ProjectType   string `yaml:"project_type"`
+	ExtensionType string `yaml:"EXTENSION_TYPE"`
+}
+
+// FindProjectFile looks for a .shopify-cli.yml in dir and its parents and
+// returns the path of the closest one
+func FindProjectFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for current := dir; ; current = filepath.Dir(current) {
+		path := filepath.Join(current, ProjectFile)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("no %s found in %s or its parents", ProjectFile, dir)
+		}
+	}
+}
+
+// LoadProjectConfig loads an extension project created by create: the type
+// is read from the .shopify-cli.yml and the other settings of the extension
+// from the shopifile.yml next to it. The directory of the project is the
+// root directory of the extension. Since projects don't record a UUID, one
+// is derived from the path of the project, which stays the same across
+// restarts.
+func LoadProjectConfig(path string) (config *Config, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	project := projectFile{}
+	if err = yaml.Unmarshal(content, &project); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	if strings.TrimPrefix(project.ProjectType, ":") != "extension" || project.ExtensionType == "" {
+		return nil, fmt.Errorf("%s doesn't describe an extension project", path)
+	}
+
+	rootDir := filepath.Dir(path)
+	extension := Extension{}
+	content, err = os.ReadFile(filepath.Join(rootDir, projectExtensionFile))
+	if err == nil {
+		if err = yaml.Unmarshal(content, &extension); err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", filepath.Join(rootDir, projectExtensionFile), err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	absRootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return
+	}
+
+	extension.Type = strings.ToLower(project.ExtensionType)
+	extension.Development.RootDir = rootDir
+	if extension.UUID == "" {
+		extension.UUID = projectUUID(absRootDir)
+	}
+
+	config = &Config{Extensions: []Extension{extension}}
+	err = validateConfig(config)
+	return
+}
+
+// projectUUID formats a hash of the project directory like a name-based UUID
+func projectUUID(dir string) string {
+	hash := sha256.Sum256([]byte(dir))
+	hash[6] = (hash[6] & 0x0f) | 0x50
+	hash[8] = (hash[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:16])
+}
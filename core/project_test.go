@@ -0,0 +1,58 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func TestLoadProjectConfig(t *testing.T) {
+	rootDir := t.TempDir()
+	files := map[string]string{
+		".shopify-cli.yml": "---\nproject_type: :extension\norganization_id: 0\nEXTENSION_TYPE: CHECKOUT_UI_EXTENSION\n",
+		"shopifile.yml":    "---\ndevelopment:\n  entries:\n    main: \"src/index.js\"\n  build_dir: \"dist\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(rootDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sourceDir := filepath.Join(rootDir, "src", "components")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := core.FindProjectFile(sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(rootDir, core.ProjectFile); path != expected {
+		t.Errorf("Expected to find %s, got %s", expected, path)
+	}
+
+	config, err := core.LoadProjectConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extension := config.Extensions[0]
+	if extension.Type != "checkout_ui_extension" || extension.Development.RootDir != rootDir || extension.Development.BuildDir != "dist" || extension.Development.Entries["main"] != "src/index.js" {
+		t.Errorf("Expected the extension of the project, got %+v", extension)
+	}
+
+	reloaded, err := core.LoadProjectConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extension.UUID == "" || reloaded.Extensions[0].UUID != extension.UUID {
+		t.Errorf("Expected a stable UUID, got %q and %q", extension.UUID, reloaded.Extensions[0].UUID)
+	}
+}
+
+func TestFindProjectFileWithoutProject(t *testing.T) {
+	if _, err := core.FindProjectFile(t.TempDir()); err == nil {
+		t.Error("Expected an error without a project file")
+	}
+}
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadTOMLConfig loads a configuration written in TOML, e.g. a
+// shopify.extension.toml generated by create. Keys are the same as in the
+// YAML format.
+//
+// Only the subset of TOML used by configuration files is supported: tables,
+// arrays of tables, dotted keys and single line values, i.e. strings,
+// integers, floats, booleans, arrays and inline tables.
+func LoadTOMLConfig(r io.Reader) (config *Config, err error) {
+	document, err := parseTOML(r)
+	if err != nil {
+		return
+	}
+
+	// The decoded document has the same shape as the YAML one, which lets
+	// us reuse the yaml tags of the configuration.
+	content, err := yaml.Marshal(document)
+	if err != nil {
+		return
+	}
+
+	config = &Config{}
+	if err = yaml.Unmarshal(content, config); err != nil {
+		return
+	}
+
+	err = validateConfig(config)
+	return
+}
+
+func parseTOML(r io.Reader) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	current := root
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(text, "[["):
+			current, err = parseArrayTableHeader(root, text)
+		case strings.HasPrefix(text, "["):
+			current, err = parseTableHeader(root, text)
+		default:
+			err = parseKeyValue(current, text)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOML on line %d: %w", line, err)
+		}
+	}
+
+	return root, scanner.Err()
+}
+
+func parseTableHeader(root map[string]interface{}, text string) (map[string]interface{}, error) {
+	p := &tomlParser{text: text, pos: 1}
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("]"); err != nil {
+		return nil, err
+	}
+	if err = p.expectEnd(); err != nil {
+		return nil, err
+	}
+	return descend(root, keys)
+}
+
+func parseArrayTableHeader(root map[string]interface{}, text string) (map[string]interface{}, error) {
+	p := &tomlParser{text: text, pos: 2}
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("]]"); err != nil {
+		return nil, err
+	}
+	if err = p.expectEnd(); err != nil {
+		return nil, err
+	}
+
+	parent, err := descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	last := keys[len(keys)-1]
+	table := make(map[string]interface{})
+	switch existing := parent[last].(type) {
+	case nil:
+		parent[last] = []interface{}{table}
+	case []interface{}:
+		parent[last] = append(existing, table)
+	default:
+		return nil, fmt.Errorf("%s is not an array of tables", strings.Join(keys, "."))
+	}
+	return table, nil
+}
+
+func parseKeyValue(table map[string]interface{}, text string) error {
+	p := &tomlParser{text: text}
+	if err := p.parseKeyValue(table); err != nil {
+		return err
+	}
+	return p.expectEnd()
+}
+
+// descend returns the table at the given path, creating missing tables.
+// The last element of an array of tables is used when the path crosses one.
+func descend(table map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for index, key := range keys {
+		switch value := table[key].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			table[key] = next
+			table = next
+		case map[string]interface{}:
+			table = value
+		case []interface{}:
+			next, ok := value[len(value)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(keys[:index+1], "."))
+			}
+			table = next
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(keys[:index+1], "."))
+		}
+	}
+	return table, nil
+}
+
+type tomlParser struct {
+	text string
+	pos  int
+}
+
+func (p *tomlParser) parseKeyValue(table map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if err = p.expect("="); err != nil {
+		return err
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+
+	last := keys[len(keys)-1]
+	if _, exists := parent[last]; exists {
+		return fmt.Errorf("duplicate key %s", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+func (p *tomlParser) parseKey() (keys []string, err error) {
+	for {
+		p.skipWhitespace()
+
+		var key string
+		switch p.peek() {
+		case '"', '\'':
+			key, err = p.parseString()
+			if err != nil {
+				return
+			}
+		default:
+			start := p.pos
+			for p.pos < len(p.text) && isBareKeyChar(p.text[p.pos]) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, fmt.Errorf("expected a key at %q", p.text[start:])
+			}
+			key = p.text[start:p.pos]
+		}
+
+		keys = append(keys, key)
+		p.skipWhitespace()
+		if p.peek() != '.' {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	p.skipWhitespace()
+
+	switch p.peek() {
+	case '"', '\'':
+		return p.parseString()
+	case '[':
+		return p.parseArray()
+	case '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for p.pos < len(p.text) && !strings.ContainsRune(" \t,]}#", rune(p.text[p.pos])) {
+		p.pos++
+	}
+	literal := p.text[start:p.pos]
+
+	switch literal {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	return parseNumber(literal)
+}
+
+var (
+	// tomlInteger matches decimal integers without leading zeros and
+	// unsigned hexadecimal, octal and binary integers. Underscores have to
+	// be surrounded by digits.
+	tomlInteger = regexp.MustCompile(`^(?:[+-]?(?:0|[1-9](?:_?[0-9])*)|0x[0-9A-Fa-f](?:_?[0-9A-Fa-f])*|0o[0-7](?:_?[0-7])*|0b[01](?:_?[01])*)$`)
+	// tomlFloat matches floats with a fractional part, an exponent or both,
+	// and the special values inf and nan
+	tomlFloat = regexp.MustCompile(`^(?:[+-]?(?:0|[1-9](?:_?[0-9])*)(?:\.[0-9](?:_?[0-9])*(?:[eE][+-]?[0-9](?:_?[0-9])*)?|[eE][+-]?[0-9](?:_?[0-9])*)|[+-]?(?:inf|nan))$`)
+)
+
+// parseNumber parses the integers and floats of the TOML spec. Go accepts
+// more, e.g. 0X1F, 0755 or Inf, which are rejected.
+func parseNumber(literal string) (interface{}, error) {
+	number := strings.ReplaceAll(literal, "_", "")
+
+	if tomlInteger.MatchString(literal) {
+		base := 10
+		switch {
+		case strings.HasPrefix(number, "0x"):
+			base = 16
+		case strings.HasPrefix(number, "0o"):
+			base = 8
+		case strings.HasPrefix(number, "0b"):
+			base = 2
+		}
+		if base != 10 {
+			number = number[2:]
+		}
+
+		integer, err := strconv.ParseInt(number, base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("integer %s is out of range", literal)
+		}
+		return integer, nil
+	}
+
+	if tomlFloat.MatchString(literal) {
+		// Go doesn't accept a sign before nan
+		if strings.HasSuffix(number, "nan") {
+			number = "nan"
+		}
+		return strconv.ParseFloat(number, 64)
+	}
+	return nil, fmt.Errorf("unsupported value %q", literal)
+}
+
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.text[p.pos]
+	if strings.HasPrefix(p.text[p.pos:], strings.Repeat(string(quote), 3)) {
+		return "", fmt.Errorf("multi-line strings are not supported")
+	}
+
+	end := p.pos + 1
+	for end < len(p.text) && p.text[end] != quote {
+		if quote == '"' && p.text[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(p.text) {
+		return "", fmt.Errorf("unterminated string %s", p.text[p.pos:])
+	}
+
+	raw := p.text[p.pos+1 : end]
+	p.pos = end + 1
+
+	for _, c := range raw {
+		if c != '\t' && (c < 0x20 || c == 0x7f) {
+			return "", fmt.Errorf("control character %U in string %q", c, raw)
+		}
+	}
+
+	if quote == '\'' {
+		return raw, nil
+	}
+	return unescape(raw)
+}
+
+// unescape replaces the escape sequences of a basic string. Only the ones
+// of the TOML spec are supported, not e.g. \a or \x41.
+func unescape(raw string) (string, error) {
+	var unescaped strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			unescaped.WriteByte(raw[i])
+			continue
+		}
+
+		i++
+		if i == len(raw) {
+			return "", fmt.Errorf("unterminated escape sequence in string %q", raw)
+		}
+
+		switch raw[i] {
+		case 'b':
+			unescaped.WriteByte('\b')
+		case 't':
+			unescaped.WriteByte('\t')
+		case 'n':
+			unescaped.WriteByte('\n')
+		case 'f':
+			unescaped.WriteByte('\f')
+		case 'r':
+			unescaped.WriteByte('\r')
+		case '"':
+			unescaped.WriteByte('"')
+		case '\\':
+			unescaped.WriteByte('\\')
+		case 'u', 'U':
+			digits := 4
+			if raw[i] == 'U' {
+				digits = 8
+			}
+			if i+digits >= len(raw) {
+				return "", fmt.Errorf("invalid escape sequence %q in string %q", raw[i-1:], raw)
+			}
+			code, err := strconv.ParseUint(raw[i+1:i+1+digits], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid escape sequence %q in string %q", raw[i-1:i+1+digits], raw)
+			}
+			unescaped.WriteRune(rune(code))
+			i += digits
+		default:
+			return "", fmt.Errorf("invalid escape sequence %q in string %q", raw[i-1:i+1], raw)
+		}
+	}
+	return unescaped.String(), nil
+}
+
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	values := make([]interface{}, 0)
+
+	for {
+		p.skipWhitespace()
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipWhitespace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++
+	table := make(map[string]interface{})
+
+	p.skipWhitespace()
+	if p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipWhitespace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
+
+func (p *tomlParser) expect(token string) error {
+	p.skipWhitespace()
+	if !strings.HasPrefix(p.text[p.pos:], token) {
+		return fmt.Errorf("expected %s at %q", token, p.text[p.pos:])
+	}
+	p.pos += len(token)
+	return nil
+}
+
+// expectEnd makes sure nothing but a comment follows
+func (p *tomlParser) expectEnd() error {
+	p.skipWhitespace()
+	if p.pos < len(p.text) && p.text[p.pos] != '#' {
+		return fmt.Errorf("unexpected %q", p.text[p.pos:])
+	}
+	return nil
+}
+
+func (p *tomlParser) skipWhitespace() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
@@ -0,0 +1,126 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func TestLoadTOMLConfig(t *testing.T) {
+	serializedConfig := `# generated by create
+port = 8000
+list_assets = true
+
+[[extensions]]
+type = "checkout_ui_extension"
+uuid = "123"
+title = 'My "Extension"'
+
+[extensions.development]
+root_dir = "extensions/my-extension"
+build_dir = "build"
+mime_types = { ".liquid" = "text/plain" }
+
+[extensions.development.entries]
+"main" = "src/index.js"
+
+[[extensions]]
+type = "product_subscription"
+uuid = "456"
+user.metafields = [{ namespace = "my-namespace", key = "my-key" }]
+`
+
+	config, err := core.LoadTOMLConfig(strings.NewReader(serializedConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Port != 8000 || !config.ListAssets {
+		t.Errorf("Unexpected top level settings %+v", config)
+	}
+
+	if len(config.Extensions) != 2 {
+		t.Fatalf("expected two extensions got %d instead", len(config.Extensions))
+	}
+
+	extension := config.Extensions[0]
+	if extension.UUID != "123" || extension.Type != "checkout_ui_extension" || extension.Title != `My "Extension"` {
+		t.Errorf("Unexpected extension %+v", extension)
+	}
+
+	if extension.Development.RootDir != "extensions/my-extension" || extension.Development.Entries["main"] != "src/index.js" {
+		t.Errorf("Unexpected development settings %+v", extension.Development)
+	}
+
+	if extension.Development.MimeTypes[".liquid"] != "text/plain" {
+		t.Errorf("Expected inline table to be loaded, got %v", extension.Development.MimeTypes)
+	}
+
+	metafields := config.Extensions[1].User.Metafields
+	if len(metafields) != 1 || metafields[0].Key != "my-key" {
+		t.Errorf("Expected the metafields of the second extension, got %+v", metafields)
+	}
+}
+
+func TestLoadTOMLConfigErrors(t *testing.T) {
+	for _, serializedConfig := range []string{
+		"port = ",
+		"port = 1\nport = 2",
+		"name = \"unterminated",
+		"description = \"\"\"multi\nline\"\"\"",
+		"[table",
+	} {
+		if _, err := core.LoadTOMLConfig(strings.NewReader(serializedConfig)); err == nil {
+			t.Errorf("Expected an error loading %q", serializedConfig)
+		}
+	}
+}
+
+func TestLoadTOMLConfigValues(t *testing.T) {
+	serializedConfig := `port = 0x1F_40
+
+[[extensions]]
+uuid = "tab\there"
+title = "C:\\Extensions\\\"Mine\" \u00e9"
+`
+
+	config, err := core.LoadTOMLConfig(strings.NewReader(serializedConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Port != 8000 {
+		t.Errorf("Expected a hexadecimal port, got %d", config.Port)
+	}
+	if extension := config.Extensions[0]; extension.UUID != "tab\there" || extension.Title != `C:\Extensions\"Mine" é` {
+		t.Errorf("Expected escape sequences to be replaced, got %+v", extension)
+	}
+}
+
+func TestLoadTOMLConfigRejectsNonTOMLValues(t *testing.T) {
+	for _, value := range []string{
+		"0755",
+		"00",
+		"0X1F",
+		"-0x1F",
+		"1__000",
+		"_1000",
+		"1000_",
+		"Inf",
+		"1.",
+		".5",
+		"01.5",
+		`"\a"`,
+		`"\x41"`,
+		`"\101"`,
+		`"\'"`,
+		`"\ud800"`,
+		`"\u12"`,
+		"\"bell\x07\"",
+	} {
+		if _, err := core.LoadTOMLConfig(strings.NewReader("title = " + value)); err == nil {
+			t.Errorf("Expected an error loading %s", value)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// traceParentPattern matches a traceparent of version 00. Later versions may
+// append fields, which are ignored.
+var traceParentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// TraceParent is the W3C trace context a request or the CLI was started in,
+// see https://www.w3.org/TR/trace-context/#traceparent-header
+type TraceParent struct {
+	TraceID  string
+	ParentID string
+	Flags    string
+}
+
+// ParseTraceParent parses the value of a traceparent header or TRACEPARENT
+// environment variable. Invalid values, which have to be ignored, return
+// false.
+func ParseTraceParent(value string) (TraceParent, bool) {
+	match := traceParentPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return TraceParent{}, false
+	}
+
+	version, traceID, parentID, flags, rest := match[1], match[2], match[3], match[4], match[5]
+	if version == "ff" || (version == "00" && rest != "") {
+		return TraceParent{}, false
+	}
+	if traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return TraceParent{}, false
+	}
+	return TraceParent{traceID, parentID, flags}, true
+}
+
+// String formats the trace context as traceparent of version 00
+func (traceParent TraceParent) String() string {
+	return fmt.Sprintf("00-%s-%s-%s", traceParent.TraceID, traceParent.ParentID, traceParent.Flags)
+}
@@ -0,0 +1,36 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	traceParent, ok := core.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || traceParent.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || traceParent.ParentID != "00f067aa0ba902b7" || traceParent.Flags != "01" {
+		t.Fatalf("Unexpected trace context %+v", traceParent)
+	}
+	if traceParent.String() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Expected the trace context to format as it was parsed, got %s", traceParent)
+	}
+
+	// Later versions may add fields
+	if _, ok := core.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); !ok {
+		t.Error("Expected a later version to be accepted")
+	}
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := core.ParseTraceParent(value); ok {
+			t.Errorf("Expected %q to be invalid", value)
+		}
+	}
+}
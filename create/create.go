@@ -4,33 +4,97 @@ import (
 	"bytes"
 	"embed"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"text/template"
 
 	"github.com/Shopify/shopify-cli-extensions/core"
 	"github.com/Shopify/shopify-cli-extensions/create/fsutils"
 	"github.com/Shopify/shopify-cli-extensions/create/process"
+	"gopkg.in/yaml.v3"
 )
 
 //go:embed templates/* templates/.shopify-cli.yml.tpl
 var templates embed.FS
 var templateRoot = "templates"
 var templateFileExtension = ".tpl"
+var templateSourceDir = "src"
 var defaultSourceDir = "src"
+var defaultBuildDir = "build"
+var defaultFileMode os.FileMode = 0644
 
-func NewExtensionProject(extension core.Extension) (err error) {
+// defaultMaxTemplateOutput caps the rendered size of each template, see
+// Options.MaxTemplateOutput
+const defaultMaxTemplateOutput int64 = 5 << 20
+
+// secretFileMode keeps files holding secrets readable by their owner only
+var secretFileMode os.FileMode = 0600
+
+// configTemplates are only rendered when their config format is requested
+var configTemplates = map[string]string{
+	"shopify.extension.toml": "toml",
+}
+
+// mainTemplates maps the main template files shipped for an extension type
+// to the template names that resolve to them and the UI framework they use.
+var mainTemplates = []mainTemplate{
+	{"javascript.js", []string{"javascript", "typescript"}, ""},
+	{"react.js", []string{"javascript-react", "typescript-react"}, "react"},
+}
+
+// rendererFrameworks are the UI frameworks a renderer can be bound to, which
+// is detected by its name, e.g. @shopify/checkout-ui-extensions-react. Other
+// renderers are framework agnostic.
+var rendererFrameworks = []string{"react", "preact", "vue", "svelte"}
+
+// Options customize the layout of a new extension project.
+type Options struct {
+	// SourceDir is the directory, relative to the extension root, holding the source files. Defaults to src.
+	SourceDir string
+	// BuildDir overrides the build directory of the extension. Defaults to the configured one or build.
+	BuildDir string
+	// WithTests scaffolds a test file and a test script if the extension type ships a test template.
+	WithTests bool
+	// VarsFile is a YAML file of key/value pairs exposed to templates as .Vars,
+	// keeping values like secrets out of the extension config.
+	VarsFile string
+	// ConfigFormat is yaml or toml. With toml a shopify.extension.toml is
+	// generated in addition to the YAML files. Defaults to yaml.
+	ConfigFormat string
+	// Exclude lists template paths relative to the template root, e.g.
+	// package.json.tpl, that aren't rendered. Glob patterns are supported.
+	Exclude []string
+	// Renderer overrides the configured renderer, optionally pinned to a
+	// version as name@version
+	Renderer string
+	// FileMode is the permission of created files, defaults to 0644. Files
+	// likely holding secrets, i.e. .env files, are always created with 0600.
+	FileMode os.FileMode
+	// CheckTemplates validates all templates before rendering any, see
+	// ValidateTemplates
+	CheckTemplates bool
+	// MaxTemplateOutput is the size in bytes a single template may render
+	// to, so that a pathological template fails instead of exhausting
+	// memory. Defaults to 5 MiB, negative disables the limit.
+	MaxTemplateOutput int64
+}
+
+func NewExtensionProject(extension core.Extension, options Options) (err error) {
 	fs := fsutils.NewFS(&templates, templateRoot)
-	project := &project{
-		&extension,
-		strings.ToUpper(extension.Type),
-		strings.Contains(extension.Development.Template, "react"),
-		strings.Contains(extension.Development.Template, "typescript"),
+
+	project, err := newProject(fs, extension, options)
+	if err != nil {
+		return
 	}
 
 	setup := process.NewProcess(
-		MakeDir(extension.Development.RootDir),
+		MakeDir(project.Development.RootDir),
 		CreateSourceFiles(fs, project),
 		MergeTemplates(fs, project),
 		MergeYamlAndJsonFiles(fs, project),
@@ -39,6 +103,89 @@ func NewExtensionProject(extension core.Extension) (err error) {
 	return setup.Run()
 }
 
+func newProject(fs *fsutils.FS, extension core.Extension, options Options) (*project, error) {
+	if options.CheckTemplates {
+		if err := ValidateTemplates(); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.BuildDir != "" {
+		extension.Development.BuildDir = options.BuildDir
+	}
+	if extension.Development.BuildDir == "" {
+		extension.Development.BuildDir = defaultBuildDir
+	}
+
+	if options.Renderer != "" {
+		extension.Development.Renderer = core.Renderer{Name: options.Renderer}
+	}
+	renderer, err := core.ParseRenderer(extension.Development.Renderer.Name)
+	if err != nil {
+		return nil, err
+	}
+	if renderer.Version != "" {
+		extension.Development.Renderer = renderer
+	}
+	if err := validateRenderer(extension.Development.Template, extension.Development.Renderer.Name); err != nil {
+		return nil, err
+	}
+
+	sourceDir := options.SourceDir
+	if sourceDir == "" {
+		sourceDir = defaultSourceDir
+	}
+
+	configFormat := options.ConfigFormat
+	if configFormat == "" {
+		configFormat = "yaml"
+	}
+	if configFormat != "yaml" && configFormat != "toml" {
+		return nil, fmt.Errorf("unsupported config format %q, use yaml or toml", configFormat)
+	}
+
+	vars, err := loadVars(options.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMode := options.FileMode
+	if fileMode == 0 {
+		fileMode = defaultFileMode
+	}
+	if fileMode&^os.ModePerm != 0 {
+		return nil, fmt.Errorf("invalid file mode %o", fileMode)
+	}
+
+	maxTemplateOutput := options.MaxTemplateOutput
+	if maxTemplateOutput == 0 {
+		maxTemplateOutput = defaultMaxTemplateOutput
+	}
+
+	project := &project{
+		Extension:         &extension,
+		FormattedType:     strings.ToUpper(extension.Type),
+		React:             strings.Contains(extension.Development.Template, "react"),
+		TypeScript:        strings.Contains(extension.Development.Template, "typescript"),
+		SourceDir:         sourceDir,
+		Vars:              vars,
+		ConfigFormat:      configFormat,
+		Exclude:           options.Exclude,
+		FileMode:          fileMode,
+		MaxTemplateOutput: maxTemplateOutput,
+	}
+
+	if options.WithTests {
+		if fs.Exists(filepath.Join(extension.Type, getTestTemplate(project))) {
+			project.WithTests = true
+		} else {
+			log.Printf("No test template available for %s, skipping tests", extension.Type)
+		}
+	}
+
+	return project, nil
+}
+
 func MakeDir(path string) process.Task {
 	return process.Task{
 		Run: func() error {
@@ -51,35 +198,45 @@ func MakeDir(path string) process.Task {
 }
 
 func CreateSourceFiles(fs *fsutils.FS, project *project) process.Task {
-	sourceDirPath := filepath.Join(project.Development.RootDir, defaultSourceDir)
+	sourceDirPath := filepath.Join(project.Development.RootDir, project.SourceDir)
 
 	return process.Task{
 		Run: func() (err error) {
+			// Checked first, so that nothing is left to roll back
+			mainTemplate := filepath.Join(project.Type, getMainTemplate(project))
+			if !fs.Exists(mainTemplate) {
+				return unsupportedTemplateError(fs, project)
+			}
+
 			if err := fsutils.MakeDir(sourceDirPath); err != nil {
 				return err
 			}
 
 			project.Development.Entries = make(map[string]string)
-			project.Development.Entries["main"] = filepath.Join(defaultSourceDir, getMainFileName(project))
+			project.Development.Entries["main"] = filepath.Join(project.SourceDir, getMainFileName(project))
 
-			// Create main index file
-			err = fs.CopyFile(
-				filepath.Join(project.Type, getMainTemplate(project)),
-				filepath.Join(project.Development.RootDir, project.Development.Entries["main"]),
-			)
+			// Create main index file and its companions, e.g. tests
+			for _, file := range getSourceFiles(project) {
+				err = fs.CopyFile(
+					filepath.Join(project.Type, file.template),
+					filepath.Join(sourceDirPath, file.name),
+					project.fileMode(file.name),
+				)
 
-			if err != nil {
-				return
+				if err != nil {
+					return
+				}
 			}
 
 			// Copy additional files inside template source
 			err = fs.Execute(&fsutils.Operation{
-				SourceDir: filepath.Join(project.Type, defaultSourceDir),
+				SourceDir: filepath.Join(project.Type, templateSourceDir),
 				TargetDir: sourceDirPath,
 				OnEachFile: func(filePath, targetPath string) (err error) {
 					return fs.CopyFile(
 						filePath,
 						targetPath,
+						project.fileMode(targetPath),
 					)
 				},
 				SkipEmpty: true,
@@ -88,12 +245,20 @@ func CreateSourceFiles(fs *fsutils.FS, project *project) process.Task {
 		},
 		Undo: func() error {
 			// TODO: Figure out if we should recursively remove all files inside src or not
-			return fsutils.RemoveDir(sourceDirPath)
+			if err := fsutils.RemoveDir(sourceDirPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
 		},
 	}
 }
 
 func MergeTemplates(fs *fsutils.FS, project *project) process.Task {
+	return mergeTemplates(fs, project, diskSink{})
+}
+
+// mergeTemplates renders the templates of the template root into the sink
+func mergeTemplates(fs *fsutils.FS, project *project, out sink) process.Task {
 	newFilePaths := make([]string, 0)
 	return process.Task{
 		Run: func() error {
@@ -105,7 +270,14 @@ func MergeTemplates(fs *fsutils.FS, project *project) process.Task {
 						return
 					}
 
+					if isExcluded(project, filePath) {
+						return
+					}
+
 					targetFilePath := strings.TrimSuffix(targetPath, templateFileExtension)
+					if format, ok := configTemplates[filepath.Base(targetFilePath)]; ok && format != project.ConfigFormat {
+						return
+					}
 
 					content, err := mergeTemplateWithData(project, filePath)
 					if err != nil {
@@ -117,14 +289,14 @@ func MergeTemplates(fs *fsutils.FS, project *project) process.Task {
 						return
 					}
 					newFilePaths = append(newFilePaths, targetFilePath)
-					return fsutils.CopyFileContent(targetFilePath, formattedContent)
+					return out.WriteFile(targetFilePath, formattedContent, project.fileMode(targetFilePath))
 				},
 				SkipEmpty: false,
 			})
 		},
 		Undo: func() (err error) {
 			for _, filePath := range newFilePaths {
-				if err = os.Remove(filePath); err != nil {
+				if err = out.Remove(filePath); err != nil {
 					return
 				}
 			}
@@ -133,7 +305,27 @@ func MergeTemplates(fs *fsutils.FS, project *project) process.Task {
 	}
 }
 
+// isExcluded checks a template against the exclude list, which holds paths
+// relative to the template root with or without the template file extension.
+func isExcluded(project *project, filePath string) bool {
+	relativePath := strings.TrimPrefix(filepath.ToSlash(filePath), templateRoot+"/")
+	for _, pattern := range project.Exclude {
+		for _, candidate := range []string{relativePath, strings.TrimSuffix(relativePath, templateFileExtension)} {
+			if matched, err := path.Match(pattern, candidate); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func MergeYamlAndJsonFiles(fs *fsutils.FS, project *project) process.Task {
+	return mergeYamlAndJsonFiles(fs, project, diskSink{})
+}
+
+// mergeYamlAndJsonFiles merges the YAML and JSON files of the extension type
+// into the ones rendered by mergeTemplates, or adds them to the sink
+func mergeYamlAndJsonFiles(fs *fsutils.FS, project *project, out sink) process.Task {
 	filesToRestore := make([]files, 0)
 	return process.Task{
 		Run: func() error {
@@ -145,40 +337,32 @@ func MergeYamlAndJsonFiles(fs *fsutils.FS, project *project) process.Task {
 						return
 					}
 
-					targetFile, openErr := fsutils.OpenFileForAppend(targetPath)
-
-					if openErr != nil {
-						return fs.CopyFile(filePath, targetPath)
-					}
-
-					defer targetFile.Close()
-
 					newContent, err := templates.ReadFile(filePath)
 					if err != nil {
 						return
 					}
 
-					originalContent, err := os.ReadFile(targetPath)
-
-					if err != nil {
+					originalContent, err := out.ReadFile(targetPath)
+					if os.IsNotExist(err) {
+						return out.WriteFile(targetPath, newContent, project.fileMode(targetPath))
+					} else if err != nil {
 						return
 					}
 
 					filesToRestore = append(filesToRestore, files{originalContent, targetPath})
 					formattedContent, err := getFormattedMergedContent(targetPath, originalContent, newContent, fs)
-
-					if err = os.WriteFile(targetPath, formattedContent, 0600); err != nil {
+					if err != nil {
 						return
 					}
 
-					return
+					return out.WriteFile(targetPath, formattedContent, project.fileMode(targetPath))
 				},
 				SkipEmpty: false,
 			})
 		},
 		Undo: func() (err error) {
 			for _, file := range filesToRestore {
-				return os.WriteFile(file.filePath, file.content, 0600)
+				return out.WriteFile(file.filePath, file.content, project.fileMode(file.filePath))
 			}
 			return
 		},
@@ -242,19 +426,84 @@ func mergeTemplateWithData(project *project, filePath string) (*bytes.Buffer, er
 		return &templateContent, err
 	}
 
-	fileTemplate := template.New(filePath)
-	fileTemplate, err = fileTemplate.Parse(string(content))
+	fileTemplate, err := parseTemplate(filePath, string(content))
 	if err != nil {
 		return &templateContent, err
 	}
 
-	if err = fileTemplate.Execute(&templateContent, project); err != nil {
+	var output io.Writer = &templateContent
+	if project.MaxTemplateOutput > 0 {
+		output = &limitedWriter{&templateContent, project.MaxTemplateOutput, project.MaxTemplateOutput}
+	}
+
+	if err = executeTemplate(fileTemplate, output, project); err != nil {
 		return &templateContent, err
 	}
 
 	return &templateContent, nil
 }
 
+// limitedWriter fails the write that would exceed limit bytes in total
+type limitedWriter struct {
+	w         io.Writer
+	limit     int64
+	remaining int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		return 0, fmt.Errorf("output exceeds %d bytes, see --max-template-output", l.limit)
+	}
+	l.remaining -= int64(len(p))
+	return l.w.Write(p)
+}
+
+// delimsHeader matches a first line like {{/* delims [[ ]] */}}, which
+// switches the template to other delimiters so that it can contain literal
+// braces, e.g. for files used by another templating language
+var delimsHeader = regexp.MustCompile(`^\{\{/\* delims (\S+) (\S+) \*/\}\}\r?\n`)
+
+// parseTemplate parses a template, honouring a delims header which is
+// removed from the output. Referencing a variable that wasn't provided fails
+// instead of rendering an empty string.
+func parseTemplate(name, content string) (*template.Template, error) {
+	fileTemplate := template.New(name).Option("missingkey=error")
+
+	if match := delimsHeader.FindStringSubmatch(content); match != nil {
+		fileTemplate = fileTemplate.Delims(match[1], match[2])
+		content = content[len(match[0]):]
+	}
+
+	return fileTemplate.Parse(content)
+}
+
+// executeTemplate renders a template. text/template returns panics of the
+// methods a template calls, e.g. nil pointer dereferences, as errors naming
+// the failing action.
+func executeTemplate(fileTemplate *template.Template, w io.Writer, data interface{}) error {
+	if err := fileTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("unable to render %s: %w", fileTemplate.Name(), err)
+	}
+	return nil
+}
+
+func loadVars(path string) (vars map[string]string, err error) {
+	vars = make(map[string]string)
+	if path == "" {
+		return
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read template variables: %w", err)
+	}
+
+	if err = yaml.Unmarshal(content, &vars); err != nil {
+		return nil, fmt.Errorf("unable to parse template variables in %s: %w", path, err)
+	}
+	return
+}
+
 func getMainFileName(project *project) string {
 	if project.React && project.TypeScript {
 		return "index.tsx"
@@ -274,11 +523,119 @@ func getMainTemplate(project *project) string {
 	return "javascript.js"
 }
 
+func getTestFileName(project *project) string {
+	return strings.Replace(getMainFileName(project), "index.", "index.test.", 1)
+}
+
+func getTestTemplate(project *project) string {
+	return strings.Replace(getMainTemplate(project), ".js", ".test.js", 1)
+}
+
+func getSourceFiles(project *project) []sourceFile {
+	files := []sourceFile{{getMainTemplate(project), getMainFileName(project)}}
+	if project.WithTests {
+		files = append(files, sourceFile{getTestTemplate(project), getTestFileName(project)})
+	}
+	return files
+}
+
+// unsupportedTemplateError explains which template variants are available
+// for the project's extension type when the requested one isn't shipped.
+func unsupportedTemplateError(fs *fsutils.FS, project *project) error {
+	variants := getSupportedTemplates(fs, project.Type)
+	if len(variants) == 0 {
+		return fmt.Errorf("no templates available for extension type %q", project.Type)
+	}
+
+	return fmt.Errorf(
+		"template %q is not available for extension type %q, supported templates: %s",
+		project.Development.Template,
+		project.Type,
+		strings.Join(variants, ", "),
+	)
+}
+
+// validateRenderer makes sure the renderer fits the UI framework of the
+// template. Framework agnostic renderers fit all templates, React templates
+// depend on their React flavour.
+func validateRenderer(template, rendererName string) error {
+	rendererFramework := getRendererFramework(rendererName)
+	if rendererFramework == "" {
+		return nil
+	}
+
+	for _, mainTemplate := range mainTemplates {
+		for _, variant := range mainTemplate.variants {
+			if variant != template {
+				continue
+			}
+
+			if mainTemplate.framework == rendererFramework {
+				// The template adds the framework package of the renderer
+				base := strings.TrimSuffix(rendererName, "-"+rendererFramework)
+				return fmt.Errorf("renderer %q is the %s package, configure the base package %q, template %q adds the %s package", rendererName, rendererFramework, base, template, rendererFramework)
+			}
+			if mainTemplate.framework == "" {
+				return fmt.Errorf("renderer %q is for %s, template %q doesn't use a UI framework", rendererName, rendererFramework, template)
+			}
+			return fmt.Errorf("renderer %q is for %s, template %q uses %s", rendererName, rendererFramework, template, mainTemplate.framework)
+		}
+	}
+	return nil
+}
+
+func getRendererFramework(rendererName string) string {
+	name := strings.ToLower(path.Base(rendererName))
+	for _, framework := range rendererFrameworks {
+		if name == framework || strings.HasSuffix(name, "-"+framework) {
+			return framework
+		}
+	}
+	return ""
+}
+
+func getSupportedTemplates(fs *fsutils.FS, extensionType string) []string {
+	variants := make([]string, 0)
+	for _, template := range mainTemplates {
+		if fs.Exists(filepath.Join(extensionType, template.file)) {
+			variants = append(variants, template.variants...)
+		}
+	}
+	return variants
+}
+
 type project struct {
 	*core.Extension
 	FormattedType string
 	React         bool
 	TypeScript    bool
+	SourceDir     string
+	WithTests     bool
+	Vars          map[string]string
+	ConfigFormat  string
+	Exclude       []string
+	FileMode      os.FileMode
+	// MaxTemplateOutput limits the rendered size of each template
+	MaxTemplateOutput int64
+}
+
+// fileMode returns the permissions a file is created with
+func (project *project) fileMode(filePath string) os.FileMode {
+	if strings.HasPrefix(filepath.Base(filePath), ".env") {
+		return secretFileMode
+	}
+	return project.FileMode
+}
+
+type sourceFile struct {
+	template string
+	name     string
+}
+
+type mainTemplate struct {
+	file      string
+	variants  []string
+	framework string
 }
 
 type files struct {
@@ -291,4 +648,5 @@ type packageJSON struct {
 	Dependencies    map[string]string `json:"dependencies"`
 	License         string            `json:"license"`
 	Scripts         map[string]string `json:"scripts"`
+	Babel           interface{}       `json:"babel,omitempty"`
 }
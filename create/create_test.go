@@ -0,0 +1,407 @@
+package create
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+	"github.com/Shopify/shopify-cli-extensions/create/fsutils"
+)
+
+func TestMergeTemplatesSkipsExcludedFiles(t *testing.T) {
+	rootDir := t.TempDir()
+
+	project := &project{
+		Extension: &core.Extension{
+			Type: "checkout_ui_extension",
+			Development: core.Development{
+				RootDir:  rootDir,
+				BuildDir: "build",
+				Entries:  map[string]string{"main": "src/index.js"},
+			},
+		},
+		ConfigFormat: "yaml",
+		Exclude:      []string{"package.json.tpl"},
+	}
+
+	fs := fsutils.NewFS(&templates, templateRoot)
+	if err := MergeTemplates(fs, project).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(rootDir, "package.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected excluded package.json not to be written, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(rootDir, "shopifile.yml")); err != nil {
+		t.Errorf("Expected shopifile.yml to be written, got %v", err)
+	}
+}
+
+func TestMergeTemplateWithDataLimitsOutput(t *testing.T) {
+	project := templateValidationProjects()[1]
+	project.MaxTemplateOutput = 16
+
+	_, err := mergeTemplateWithData(project, "templates/package.json.tpl")
+	if err == nil || !strings.Contains(err.Error(), "templates/package.json.tpl") || !strings.Contains(err.Error(), "exceeds 16 bytes") {
+		t.Errorf("Expected the output limit to fail naming the template, got %v", err)
+	}
+
+	project.MaxTemplateOutput = -1
+	if _, err := mergeTemplateWithData(project, "templates/package.json.tpl"); err != nil {
+		t.Errorf("Expected a negative limit to disable it, got %v", err)
+	}
+}
+
+type nilableTemplateData struct {
+	Renderer *core.Renderer
+}
+
+func (data nilableTemplateData) RendererName() string {
+	return data.Renderer.Name
+}
+
+func TestParseTemplateWithDelims(t *testing.T) {
+	fileTemplate, err := parseTemplate("test.tpl", "{{/* delims [[ ]] */}}\n<h1>{{ title }}</h1>[[ .Type ]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content bytes.Buffer
+	project := &project{Extension: &core.Extension{Type: "checkout_ui_extension"}}
+	if err := executeTemplate(fileTemplate, &content, project); err != nil {
+		t.Fatal(err)
+	}
+
+	if content.String() != "<h1>{{ title }}</h1>checkout_ui_extension" {
+		t.Errorf("Expected literal braces to be kept and the header removed, got %q", content.String())
+	}
+
+	if err := validateTemplate("test.tpl", "{{/* delims [[ ]] */}}\n[[ .Missing ]]"); err == nil {
+		t.Error("Expected templates with other delimiters to be validated")
+	}
+}
+
+func TestExecuteTemplateMissingVar(t *testing.T) {
+	varsFile := filepath.Join(t.TempDir(), "vars.yml")
+	if err := os.WriteFile(varsFile, []byte("API_KEY: secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vars, err := loadVars(varsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileTemplate, err := parseTemplate(".env.tpl", "API_KEY={{ .Vars.API_KEY }}\nAPI_SECRET={{ .Vars.API_SECRET }}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content bytes.Buffer
+	project := &project{Extension: &core.Extension{}, Vars: vars}
+	err = executeTemplate(fileTemplate, &content, project)
+	if err == nil || !strings.HasPrefix(err.Error(), "unable to render .env.tpl: ") || !strings.Contains(err.Error(), `no entry for key "API_SECRET"`) {
+		t.Errorf("Expected an error naming the missing key, got %v", err)
+	}
+
+	project.Vars["API_SECRET"] = "other"
+	content.Reset()
+	if err := executeTemplate(fileTemplate, &content, project); err != nil {
+		t.Fatal(err)
+	}
+	if content.String() != "API_KEY=secret\nAPI_SECRET=other\n" {
+		t.Errorf("Expected the vars to be rendered, got %q", content.String())
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	if err := ValidateTemplates(); err != nil {
+		t.Errorf("Expected the shipped templates to be valid, got %v", err)
+	}
+
+	for _, content := range []string{
+		"{{ .Missing }}",
+		"{{ if .React }}{{ .Development.Missing }}{{ end }}",
+		"{{ .Vars.UNDEFINED }}",
+		"{{ if }}",
+	} {
+		if err := validateTemplate("test.tpl", content); err == nil {
+			t.Errorf("Expected %q to be reported", content)
+		}
+	}
+}
+
+func TestExecuteTemplateReportsPanics(t *testing.T) {
+	fileTemplate := template.Must(template.New("package.json.tpl").Parse(`{"name": "{{ .RendererName }}"}`))
+
+	var content bytes.Buffer
+	err := executeTemplate(fileTemplate, &content, nilableTemplateData{})
+	if err == nil {
+		t.Fatal("Expected an error rendering a nil renderer")
+	}
+
+	if !strings.HasPrefix(err.Error(), "unable to render package.json.tpl: ") || !strings.Contains(err.Error(), ".RendererName") {
+		t.Errorf("Expected the error to name the template and the failing field, got %v", err)
+	}
+
+	content.Reset()
+	if err := executeTemplate(fileTemplate, &content, nilableTemplateData{&core.Renderer{Name: "renderer"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if content.String() != `{"name": "renderer"}` {
+		t.Errorf("Unexpected content %s", content.String())
+	}
+}
+
+func TestPlanUpgradeKeepsUserDependencies(t *testing.T) {
+	extension := core.Extension{
+		Type: "checkout_ui_extension",
+		Development: core.Development{
+			RootDir:  filepath.Join(t.TempDir(), "extension"),
+			Template: "javascript",
+			Renderer: core.Renderer{Name: "@shopify/checkout-ui-extensions"},
+		},
+	}
+
+	if err := NewExtensionProject(extension, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := PlanUpgrade(extension, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Expected a freshly created extension to be up to date, got changes to %s", changes[0].Path)
+	}
+
+	packagePath := filepath.Join(extension.Development.RootDir, "package.json")
+	content, err := os.ReadFile(packagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = bytes.Replace(content, []byte(`"license": "MIT"`), []byte(`"license": "ISC"`), 1)
+	content = bytes.Replace(content, []byte(`"dependencies": {`), []byte(`"dependencies": {"lodash": "^4.0.0",`), 1)
+	if err := os.WriteFile(packagePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err = PlanUpgrade(extension, Options{WithTests: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != packagePath {
+		t.Fatalf("Expected only package.json to change, got %d changes", len(changes))
+	}
+
+	updated := string(changes[0].Updated)
+	for _, expected := range []string{`"lodash": "^4.0.0"`, `"license": "ISC"`, `"test": "jest"`} {
+		if !strings.Contains(updated, expected) {
+			t.Errorf("Expected upgraded package.json to contain %s, got %s", expected, updated)
+		}
+	}
+
+	if err := ApplyUpgrade(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	if changes, _ = PlanUpgrade(extension, Options{WithTests: true}); len(changes) != 0 {
+		t.Errorf("Expected upgrade to be idempotent, got changes to %s", changes[0].Path)
+	}
+}
+
+func TestPlanUpgradeDoesNotWrite(t *testing.T) {
+	extension := core.Extension{
+		Type: "checkout_ui_extension",
+		Development: core.Development{
+			RootDir:  t.TempDir(),
+			Template: "javascript",
+			Renderer: core.Renderer{Name: "@shopify/checkout-ui-extensions"},
+		},
+	}
+
+	changes, err := PlanUpgrade(extension, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) == 0 {
+		t.Fatal("Expected the scaffolding of an empty directory to be planned")
+	}
+	for _, change := range changes {
+		if change.Original != nil {
+			t.Errorf("Expected %s to be a new file", change.Path)
+		}
+		if _, err := os.Stat(change.Path); !os.IsNotExist(err) {
+			t.Errorf("Expected planning not to write %s", change.Path)
+		}
+	}
+}
+
+func TestNewExtensionProjectUnsupportedTemplate(t *testing.T) {
+	extension := core.Extension{
+		Type: "product_subscription",
+		Development: core.Development{
+			RootDir:  filepath.Join(t.TempDir(), "extension"),
+			Template: "javascript",
+		},
+	}
+
+	err := NewExtensionProject(extension, Options{})
+	if err == nil || err.Error() != `no templates available for extension type "product_subscription"` {
+		t.Errorf("Expected an error about the missing templates, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(extension.Development.RootDir, "src")); !os.IsNotExist(err) {
+		t.Errorf("Expected no source directory to be created, got %v", err)
+	}
+
+	fs := fsutils.NewFS(&templates, templateRoot)
+	project := &project{Extension: &core.Extension{Type: "checkout_ui_extension", Development: core.Development{Template: "vue"}}}
+	expected := `template "vue" is not available for extension type "checkout_ui_extension", supported templates: javascript, typescript, javascript-react, typescript-react`
+	if err := unsupportedTemplateError(fs, project); err == nil || err.Error() != expected {
+		t.Errorf("Expected the supported templates to be listed, got %v", err)
+	}
+}
+
+func TestNewExtensionProjectFileMode(t *testing.T) {
+	for _, test := range []struct {
+		mode     os.FileMode
+		expected os.FileMode
+	}{
+		{0, 0644},
+		{0640, 0640},
+	} {
+		extension := core.Extension{
+			Type: "checkout_ui_extension",
+			Development: core.Development{
+				RootDir:  filepath.Join(t.TempDir(), "extension"),
+				Template: "javascript",
+			},
+		}
+
+		if err := NewExtensionProject(extension, Options{FileMode: test.mode}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, file := range []string{"package.json", "shopifile.yml", filepath.Join("src", "index.js")} {
+			info, err := os.Stat(filepath.Join(extension.Development.RootDir, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != test.expected {
+				t.Errorf("Expected %s to be created with mode %o, got %o", file, test.expected, info.Mode().Perm())
+			}
+		}
+	}
+}
+
+func TestNewExtensionProjectRejectsIncompatibleRenderer(t *testing.T) {
+	tests := []struct {
+		template string
+		renderer string
+		err      string
+	}{
+		{"typescript-react", "@shopify/checkout-ui-extensions", ""},
+		{"typescript-react", "@shopify/checkout-ui-extensions@^0.12.0", ""},
+		{"typescript-react", "@shopify/checkout-ui-extensions-react@^0.12.0", `renderer "@shopify/checkout-ui-extensions-react" is the react package, configure the base package "@shopify/checkout-ui-extensions", template "typescript-react" adds the react package`},
+		{"javascript", "@shopify/checkout-ui-extensions", ""},
+		{"typescript-react", "vue", `renderer "vue" is for vue, template "typescript-react" uses react`},
+		{"javascript-react", "@shopify/checkout-ui-extensions-svelte", `renderer "@shopify/checkout-ui-extensions-svelte" is for svelte, template "javascript-react" uses react`},
+		{"javascript", "@shopify/checkout-ui-extensions-react", `renderer "@shopify/checkout-ui-extensions-react" is for react, template "javascript" doesn't use a UI framework`},
+	}
+
+	for _, test := range tests {
+		extension := core.Extension{
+			Type: "checkout_ui_extension",
+			Development: core.Development{
+				RootDir:  filepath.Join(t.TempDir(), "extension"),
+				Template: test.template,
+			},
+		}
+
+		err := NewExtensionProject(extension, Options{Renderer: test.renderer})
+		if test.err == "" && err != nil {
+			t.Errorf("Expected renderer %s to fit template %s, got %v", test.renderer, test.template, err)
+		}
+		if test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("Expected error %q, got %v", test.err, err)
+		}
+	}
+}
+
+func TestNewExtensionProjectReactDependencies(t *testing.T) {
+	extension := core.Extension{
+		Type: "checkout_ui_extension",
+		Development: core.Development{
+			RootDir:  filepath.Join(t.TempDir(), "extension"),
+			Template: "javascript-react",
+		},
+	}
+
+	if err := NewExtensionProject(extension, Options{Renderer: "@shopify/checkout-ui-extensions@^0.12.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(extension.Development.RootDir, "package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generated packageJSON
+	if err := json.Unmarshal(content, &generated); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"@shopify/checkout-ui-extensions-react": "^0.12.0", "react": "^17.0.0"}
+	if !reflect.DeepEqual(generated.Dependencies, expected) {
+		t.Errorf("Expected dependencies %v, got %v", expected, generated.Dependencies)
+	}
+}
+
+func TestGenerateConfig(t *testing.T) {
+	content, err := GenerateConfig([]string{"checkout_ui_extension", "checkout_ui_extension"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := core.LoadConfig(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Expected the generated configuration to load, got %v\n%s", err, content)
+	}
+
+	if len(config.Extensions) != 2 {
+		t.Fatalf("Expected 2 extensions, got %d", len(config.Extensions))
+	}
+
+	uuidFormat := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for index, rootDir := range []string{"checkout_ui_extension", "checkout_ui_extension_2"} {
+		extension := config.Extensions[index]
+		if !uuidFormat.MatchString(extension.UUID) {
+			t.Errorf("Expected a random UUID, got %q", extension.UUID)
+		}
+		if extension.Development.RootDir != rootDir || extension.Development.BuildDir != "build" {
+			t.Errorf("Expected root_dir %s and build_dir build, got %s and %s", rootDir, extension.Development.RootDir, extension.Development.BuildDir)
+		}
+		if extension.Development.Renderer.Name != "@shopify/checkout-ui-extensions" || extension.Development.Template != "javascript" {
+			t.Errorf("Expected the default renderer and template, got %+v", extension.Development)
+		}
+		if extension.Development.Entries["main"] != "src/index.js" {
+			t.Errorf("Expected the main entry src/index.js, got %v", extension.Development.Entries)
+		}
+	}
+
+	if config.Extensions[0].UUID == config.Extensions[1].UUID {
+		t.Error("Expected each extension to get its own UUID")
+	}
+
+	if _, err := GenerateConfig([]string{"unknown_extension"}); err == nil || !strings.Contains(err.Error(), "supported types: checkout_ui_extension") {
+		t.Errorf("Expected an error listing the supported types, got %v", err)
+	}
+}
@@ -1,10 +1,9 @@
 package fsutils
 
 import (
-	"bytes"
 	"embed"
 	"encoding/json"
-	"io"
+	iofs "io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,13 +16,19 @@ func NewFS(embeddedFS *embed.FS, root string) *FS {
 	}
 }
 
-func (fs *FS) CopyFile(filePath, targetPath string) error {
+func (fs *FS) CopyFile(filePath, targetPath string, mode os.FileMode) error {
 	normalizedPath := strings.Replace(filePath, fs.root+"/", "", 1)
 	content, err := fs.ReadFile(filepath.Join(fs.root, normalizedPath))
 	if err != nil {
 		return err
 	}
-	return CopyFileContent(targetPath, content)
+	return CopyFileContent(targetPath, content, mode)
+}
+
+func (fs *FS) Exists(filePath string) bool {
+	normalizedPath := strings.Replace(filePath, fs.root+"/", "", 1)
+	_, err := iofs.Stat(fs, filepath.Join(fs.root, normalizedPath))
+	return err == nil
 }
 
 func (fs *FS) Execute(op *Operation) error {
@@ -66,18 +71,13 @@ func (fs *FS) Execute(op *Operation) error {
 	return nil
 }
 
-func CopyFileContent(targetPath string, content []byte) error {
-	file, err := os.Create(targetPath)
-	if err != nil {
+// CopyFileContent writes the content to targetPath. The mode is applied to
+// existing files as well and isn't subject to the umask.
+func CopyFileContent(targetPath string, content []byte, mode os.FileMode) error {
+	if err := os.WriteFile(targetPath, content, mode); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	if _, err := io.Copy(file, bytes.NewReader(content)); err != nil {
-		return err
-	}
-
-	return nil
+	return os.Chmod(targetPath, mode)
 }
 
 func FormatContent(targetPath string, content []byte) ([]byte, error) {
@@ -104,10 +104,6 @@ func RemoveDir(dirPath string) error {
 	return os.Remove(dirPath)
 }
 
-func OpenFileForAppend(filePath string) (*os.File, error) {
-	return os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0600)
-}
-
 type Operation struct {
 	SourceDir  string
 	TargetDir  string
@@ -0,0 +1,129 @@
+package create
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+	"github.com/Shopify/shopify-cli-extensions/create/fsutils"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultRenderers are the renderers of starter configurations by extension
+// type, types without a renderer are left for the user to fill in
+var defaultRenderers = map[string]string{
+	"checkout_ui_extension": "@shopify/checkout-ui-extensions",
+}
+
+type starterConfig struct {
+	Extensions []starterExtension `yaml:"extensions"`
+}
+
+type starterExtension struct {
+	UUID        string             `yaml:"uuid"`
+	Type        string             `yaml:"type"`
+	Development starterDevelopment `yaml:"development"`
+}
+
+type starterDevelopment struct {
+	RootDir  string            `yaml:"root_dir"`
+	BuildDir string            `yaml:"build_dir"`
+	Template string            `yaml:"template"`
+	Renderer *starterRenderer  `yaml:"renderer,omitempty"`
+	Entries  map[string]string `yaml:"entries"`
+}
+
+type starterRenderer struct {
+	Name string `yaml:"name"`
+}
+
+// GenerateConfig returns a starter configuration with one extension per
+// type. Types are checked against the shipped templates, and the result is
+// loaded with core.LoadConfig before it's returned.
+func GenerateConfig(types []string) ([]byte, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no extension types given, supported types: %s", strings.Join(SupportedTypes(), ", "))
+	}
+
+	templateFS := fsutils.NewFS(&templates, templateRoot)
+	config := starterConfig{}
+	rootDirs := make(map[string]int)
+
+	for _, extensionType := range types {
+		variants := getSupportedTemplates(templateFS, extensionType)
+		if len(variants) == 0 {
+			return nil, fmt.Errorf("unknown extension type %q, supported types: %s", extensionType, strings.Join(SupportedTypes(), ", "))
+		}
+
+		uuid, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		// Extensions of the same type get a root directory each
+		rootDirs[extensionType]++
+		rootDir := extensionType
+		if count := rootDirs[extensionType]; count > 1 {
+			rootDir = fmt.Sprintf("%s_%d", extensionType, count)
+		}
+
+		development := starterDevelopment{
+			RootDir:  rootDir,
+			BuildDir: defaultBuildDir,
+			Template: variants[0],
+			Entries:  map[string]string{"main": path.Join(defaultSourceDir, "index.js")},
+		}
+		if renderer, ok := defaultRenderers[extensionType]; ok {
+			development.Renderer = &starterRenderer{renderer}
+		}
+
+		config.Extensions = append(config.Extensions, starterExtension{uuid, extensionType, development})
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("---\n")
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+
+	if _, err := core.LoadConfig(bytes.NewReader(buffer.Bytes())); err != nil {
+		return nil, fmt.Errorf("generated an invalid configuration: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// SupportedTypes returns the extension types templates are shipped for
+func SupportedTypes() []string {
+	templateFS := fsutils.NewFS(&templates, templateRoot)
+	entries, err := fs.ReadDir(templates, templateRoot)
+	if err != nil {
+		return nil
+	}
+
+	types := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() && len(getSupportedTemplates(templateFS, entry.Name())) > 0 {
+			types = append(types, entry.Name())
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() (string, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return "", fmt.Errorf("unable to generate a UUID: %w", err)
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
@@ -0,0 +1,76 @@
+package create
+
+import (
+	"os"
+	"sort"
+
+	"github.com/Shopify/shopify-cli-extensions/create/fsutils"
+)
+
+// sink receives the files rendered by the merge tasks. diskSink writes them
+// to the extension directory, memorySink keeps them so that an upgrade can
+// compare them with the files on disk.
+type sink interface {
+	// ReadFile returns the content of a file, an error satisfying
+	// os.IsNotExist if there is none
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, content []byte, mode os.FileMode) error
+	Remove(path string) error
+}
+
+type diskSink struct{}
+
+func (diskSink) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (diskSink) WriteFile(path string, content []byte, mode os.FileMode) error {
+	return fsutils.CopyFileContent(path, content, mode)
+}
+
+func (diskSink) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// memorySink renders as if into an empty directory, files on disk are
+// never read
+type memorySink struct {
+	files map[string]memoryFile
+}
+
+type memoryFile struct {
+	content []byte
+	mode    os.FileMode
+}
+
+func newMemorySink() *memorySink {
+	return &memorySink{files: make(map[string]memoryFile)}
+}
+
+func (sink *memorySink) ReadFile(path string) ([]byte, error) {
+	file, ok := sink.files[path]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return file.content, nil
+}
+
+func (sink *memorySink) WriteFile(path string, content []byte, mode os.FileMode) error {
+	sink.files[path] = memoryFile{content, mode}
+	return nil
+}
+
+func (sink *memorySink) Remove(path string) error {
+	delete(sink.files, path)
+	return nil
+}
+
+// paths returns the paths of the files written, sorted
+func (sink *memorySink) paths() []string {
+	paths := make([]string, 0, len(sink.files))
+	for path := range sink.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,17 @@
+import { extend } from "@shopify/checkout-ui-extensions";
+
+jest.mock("@shopify/checkout-ui-extensions", () => ({
+  extend: jest.fn(),
+  Text: "Text",
+}));
+
+describe("extension", () => {
+  it("renders in the Checkout::Feature::Render extension point", () => {
+    require("./index");
+
+    expect(extend).toHaveBeenCalledWith(
+      "Checkout::Feature::Render",
+      expect.any(Function)
+    );
+  });
+});
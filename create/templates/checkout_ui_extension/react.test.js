@@ -0,0 +1,17 @@
+import { render } from "@shopify/checkout-ui-extensions-react";
+
+jest.mock("@shopify/checkout-ui-extensions-react", () => ({
+  render: jest.fn(),
+  Text: "Text",
+}));
+
+describe("extension", () => {
+  it("renders in the Checkout::Feature::Render extension point", () => {
+    require("./index");
+
+    expect(render).toHaveBeenCalledWith(
+      "Checkout::Feature::Render",
+      expect.any(Function)
+    );
+  });
+});
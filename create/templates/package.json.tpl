@@ -1,14 +1,27 @@
 {
+    {{ if .WithTests }}"babel": {
+      "presets": [
+        ["@babel/preset-env", {"targets": {"node": "current"}}]{{ if .React }},
+        "@babel/preset-react"{{ end }}{{ if .TypeScript }},
+        "@babel/preset-typescript"{{ end }}
+      ]
+    },{{ end }}
     "license": "MIT",
     "dependencies": {
-      {{ if .React }}"{{ .Development.Renderer.Name }}-react": "latest",{{ end }}
-      {{ if .React }}"react": "^17.0.0"{{ else }}"{{ .Development.Renderer.Name }}": "latest"{{ end }}
+      {{ if .React }}"{{ .Development.Renderer.Name }}-react": "{{ with .Development.Renderer.Version }}{{ . }}{{ else }}latest{{ end }}",{{ end }}
+      {{ if .React }}"react": "^17.0.0"{{ else }}"{{ .Development.Renderer.Name }}": "{{ with .Development.Renderer.Version }}{{ . }}{{ else }}latest{{ end }}"{{ end }}
     },
     "devDependencies": {
       {{ if .TypeScript }}"typescript": "^4.1.0",{{ end }}
+      {{ if .WithTests }}"jest": "^27.0.0",
+      "babel-jest": "^27.0.0",
+      "@babel/preset-env": "^7.15.0",{{ if .React }}
+      "@babel/preset-react": "^7.14.0",{{ end }}{{ if .TypeScript }}
+      "@babel/preset-typescript": "^7.15.0",{{ end }}{{ end }}
       "@shopify/shopify-cli-extensions": "latest"
     },
     "scripts": {
+      {{ if .WithTests }}"test": "jest",{{ end }}
       "build": "shopify-cli-extensions build",
       "develop": "shopify-cli-extensions develop"
     }
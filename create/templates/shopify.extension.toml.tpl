@@ -0,0 +1,19 @@
+[[extensions]]
+type = {{ printf "%q" .Type }}
+uuid = {{ printf "%q" .UUID }}
+{{- if .Title }}
+title = {{ printf "%q" .Title }}
+{{- end }}
+{{- if .Description }}
+description = {{ printf "%q" .Description }}
+{{- end }}
+
+[extensions.development]
+root_dir = {{ printf "%q" .Development.RootDir }}
+build_dir = {{ printf "%q" .Development.BuildDir }}
+template = {{ printf "%q" .Development.Template }}
+
+[extensions.development.entries]
+{{- range $key, $value := .Development.Entries }}
+{{ printf "%q" $key }} = {{ printf "%q" $value }}
+{{- end }}
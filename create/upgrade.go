@@ -0,0 +1,201 @@
+package create
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+	"github.com/Shopify/shopify-cli-extensions/create/fsutils"
+	"github.com/Shopify/shopify-cli-extensions/create/process"
+)
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 2
+
+// FileChange is a generated file that differs from the one on disk.
+// Original is nil for files that don't exist yet.
+type FileChange struct {
+	Path     string
+	Original []byte
+	Updated  []byte
+	Mode     os.FileMode
+}
+
+// PlanUpgrade renders the configuration and build files of an existing
+// extension with the current templates and returns the files that would
+// change. Source files are never touched. In package.json, dependencies and
+// scripts added by the user are kept, so planning is idempotent: once the
+// changes are applied, planning again returns no changes.
+func PlanUpgrade(extension core.Extension, options Options) ([]FileChange, error) {
+	fs := fsutils.NewFS(&templates, templateRoot)
+
+	project, err := newProject(fs, extension, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(project.Development.Entries) == 0 {
+		project.Development.Entries = map[string]string{
+			"main": filepath.Join(project.SourceDir, getMainFileName(project)),
+		}
+	}
+
+	// The same tasks as for a new extension, rendered into memory
+	generated := newMemorySink()
+	render := process.NewProcess(
+		mergeTemplates(fs, project, generated),
+		mergeYamlAndJsonFiles(fs, project, generated),
+	)
+	if err := render.Run(); err != nil {
+		return nil, err
+	}
+
+	changes := make([]FileChange, 0)
+	for _, targetPath := range generated.paths() {
+		updated := generated.files[targetPath].content
+
+		original, err := os.ReadFile(targetPath)
+		if errors.Is(err, os.ErrNotExist) {
+			original = nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if original != nil && filepath.Base(targetPath) == "package.json" {
+			if updated, err = upgradePackageJSON(original, updated); err != nil {
+				return nil, err
+			}
+		}
+
+		if !bytes.Equal(original, updated) {
+			changes = append(changes, FileChange{targetPath, original, updated, generated.files[targetPath].mode})
+		}
+	}
+
+	return changes, nil
+}
+
+// ApplyUpgrade writes the changes returned by PlanUpgrade
+func ApplyUpgrade(changes []FileChange) error {
+	for _, change := range changes {
+		if err := (diskSink{}).WriteFile(change.Path, change.Updated, change.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upgradePackageJSON updates an existing package.json with the generated
+// one. Dependencies and scripts are merged with the generated ones taking
+// precedence, other settings are only added when missing.
+func upgradePackageJSON(original, generated []byte) ([]byte, error) {
+	var existing, updated map[string]interface{}
+	if err := json.Unmarshal(original, &existing); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(generated, &updated); err != nil {
+		return nil, err
+	}
+
+	for key, value := range updated {
+		existingMap, existingIsMap := existing[key].(map[string]interface{})
+		updatedMap, updatedIsMap := value.(map[string]interface{})
+
+		switch {
+		case existingIsMap && updatedIsMap && isMergedPackageKey(key):
+			for name, version := range updatedMap {
+				existingMap[name] = version
+			}
+		case existing[key] == nil:
+			existing[key] = value
+		}
+	}
+
+	return json.MarshalIndent(existing, "", "  ")
+}
+
+func isMergedPackageKey(key string) bool {
+	return key == "dependencies" || key == "devDependencies" || key == "scripts"
+}
+
+// Diff returns the changed lines prefixed with - and + along with a few
+// lines of context.
+func (change FileChange) Diff() string {
+	original := splitLines(change.Original)
+	updated := splitLines(change.Updated)
+
+	lines := diffLines(original, updated)
+
+	var diff strings.Builder
+	lastPrinted := -1
+	for index, line := range lines {
+		if !isNearChange(lines, index) {
+			continue
+		}
+		if lastPrinted >= 0 && index > lastPrinted+1 {
+			diff.WriteString("  ...\n")
+		}
+		diff.WriteString(line)
+		diff.WriteString("\n")
+		lastPrinted = index
+	}
+	return diff.String()
+}
+
+func isNearChange(lines []string, index int) bool {
+	for offset := -diffContext; offset <= diffContext; offset++ {
+		if i := index + offset; i >= 0 && i < len(lines) && !strings.HasPrefix(lines[i], " ") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffLines computes a line based diff using the longest common subsequence
+func diffLines(original, updated []string) []string {
+	common := make([][]int, len(original)+1)
+	for i := range common {
+		common[i] = make([]int, len(updated)+1)
+	}
+
+	for i := len(original) - 1; i >= 0; i-- {
+		for j := len(updated) - 1; j >= 0; j-- {
+			if original[i] == updated[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(original)+len(updated))
+	i, j := 0, 0
+	for i < len(original) || j < len(updated) {
+		switch {
+		case i < len(original) && j < len(updated) && original[i] == updated[j]:
+			lines = append(lines, "  "+original[i])
+			i++
+			j++
+		case j < len(updated) && (i == len(original) || common[i][j+1] >= common[i+1][j]):
+			lines = append(lines, "+ "+updated[j])
+			j++
+		default:
+			lines = append(lines, "- "+original[i])
+			i++
+		}
+	}
+	return lines
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
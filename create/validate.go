@@ -0,0 +1,72 @@
+package create
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+// ValidateTemplates parses every template shipped with create and executes it
+// against a project with zero values, and against one with all options
+// enabled to reach conditional blocks. This catches templates referencing
+// fields the project doesn't have before they fail an actual creation.
+func ValidateTemplates() error {
+	return fs.WalkDir(templates, templateRoot, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(filePath, templateFileExtension) {
+			return nil
+		}
+
+		content, err := templates.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		return validateTemplate(filePath, string(content))
+	})
+}
+
+func validateTemplate(name, content string) error {
+	fileTemplate, err := parseTemplate(name, content)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", name, err)
+	}
+
+	for _, project := range templateValidationProjects() {
+		if err := executeTemplate(fileTemplate, io.Discard, project); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func templateValidationProjects() []*project {
+	zero := &project{Extension: &core.Extension{}, Vars: map[string]string{}}
+
+	complete := &project{
+		Extension: &core.Extension{
+			Type:        "checkout_ui_extension",
+			UUID:        "00000000-0000-0000-0000-000000000000",
+			Title:       "Title",
+			Description: "Description",
+			Development: core.Development{
+				Renderer: core.Renderer{Name: "@shopify/checkout-ui-extensions", Version: "0.1.0"},
+				Entries:  map[string]string{"main": "src/index.js"},
+			},
+		},
+		FormattedType: "CHECKOUT_UI_EXTENSION",
+		React:         true,
+		TypeScript:    true,
+		SourceDir:     defaultSourceDir,
+		WithTests:     true,
+		Vars:          map[string]string{},
+		ConfigFormat:  "toml",
+		FileMode:      defaultFileMode,
+	}
+
+	return []*project{zero, complete}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shopify/shopify-cli-extensions/core"
+	"github.com/Shopify/shopify-cli-extensions/create"
+)
+
+// Severities of lint findings. Warnings point out likely mistakes and fail
+// lint --strict, infos only matter for some workflows.
+const (
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+type lintFinding struct {
+	severity string
+	message  string
+}
+
+// lint reports problems of the configuration that don't keep it from
+// loading. It exits with 1 when there are warnings and --strict is passed.
+func (cli *CLI) lint(args ...string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	strict := flags.Bool("strict", false, "exit with an error when there are warnings")
+	configureColors := addColorFlags(flags)
+	flags.Parse(args)
+	configureColors()
+
+	findings := cli.lintFindings()
+	warnings := 0
+	for _, finding := range findings {
+		severity := finding.severity
+		if severity == severityWarning {
+			warnings++
+			severity = colorize(red, severity)
+		}
+		fmt.Printf("%s: %s\n", severity, finding.message)
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("No problems found")
+	}
+	if *strict && warnings > 0 {
+		os.Exit(1)
+	}
+}
+
+func (cli *CLI) lintFindings() []lintFinding {
+	findings := make([]lintFinding, 0)
+	for _, namespace := range cli.namespaces() {
+		for _, warning := range core.Warnings(namespace) {
+			findings = append(findings, lintFinding{severityWarning, warning})
+		}
+	}
+
+	supportedTypes := make(map[string]bool)
+	for _, extensionType := range create.SupportedTypes() {
+		supportedTypes[extensionType] = true
+	}
+
+	rootDirs := make(map[string]string)
+	for _, extension := range cli.config.AllExtensions() {
+		development := extension.Development
+
+		buildDir := filepath.Join(development.RootDir, development.BuildDir)
+		if info, err := os.Stat(buildDir); err != nil || !info.IsDir() {
+			findings = append(findings, lintFinding{severityWarning, fmt.Sprintf("build directory %s of extension %s doesn't exist, it wasn't built yet or build_dir is wrong", buildDir, extension.UUID)})
+		}
+
+		rootDir := filepath.Clean(development.RootDir)
+		if other, ok := rootDirs[rootDir]; ok {
+			findings = append(findings, lintFinding{severityWarning, fmt.Sprintf("extensions %s and %s share the root directory %s, their builds overwrite each other", other, extension.UUID, rootDir)})
+		} else {
+			rootDirs[rootDir] = extension.UUID
+		}
+
+		if !supportedTypes[extension.Type] {
+			findings = append(findings, lintFinding{severityInfo, fmt.Sprintf("no templates are shipped for type %s of extension %s, create supports %s", extension.Type, extension.UUID, strings.Join(create.SupportedTypes(), ", "))})
+		}
+	}
+	return findings
+}
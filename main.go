@@ -1,20 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/Shopify/shopify-cli-extensions/api"
 	"github.com/Shopify/shopify-cli-extensions/build"
 	"github.com/Shopify/shopify-cli-extensions/core"
 	"github.com/Shopify/shopify-cli-extensions/create"
+	"github.com/Shopify/shopify-cli-extensions/otlp"
 )
 
 var ctx context.Context
@@ -22,6 +33,17 @@ var ctx context.Context
 //go:generate make update-version
 const version = "v0.0.0"
 
+// commit is set at build time with -ldflags "-X main.commit=<sha>"
+var commit = ""
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultShutdownTimeout   = 5 * time.Second
+	traceExportTimeout       = 5 * time.Second
+)
+
 func init() {
 	ctx = context.Background()
 }
@@ -30,12 +52,28 @@ func main() {
 	cli := CLI{}
 	cmd, args := os.Args[1], os.Args[2:]
 
-	if len(args) > 0 {
+	// Without a configuration, build, serve and lint use the extension
+	// project the working directory belongs to
+	if (cmd == "build" || cmd == "serve" || cmd == "lint") && (len(args) == 0 || isFlag(args[0])) {
+		path, err := findProjectFile()
+		if err != nil {
+			log.Fatalf("No configuration given: %v", err)
+		}
+		args = append([]string{path}, args...)
+	}
+
+	// migrate-config reads configurations that may not load anymore,
+	// init-config generates one from extension types and tail connects to
+	// a running server
+	if len(args) > 0 && cmd != "migrate-config" && cmd != "init-config" && cmd != "tail" {
 		config, err := loadConfigFrom(args[0])
 		if err != nil {
 			panic(err)
 		}
+		config.ServerVersion = version
+		config.ServerCommit = commit
 		cli.config = config
+		cli.configPath = args[0]
 		args = args[1:]
 	}
 
@@ -46,42 +84,187 @@ func main() {
 		cli.create(args...)
 	case "serve":
 		cli.serve(args...)
+	case "upgrade":
+		cli.upgrade(args...)
+	case "migrate-config":
+		cli.migrateConfig(args...)
+	case "init-config":
+		cli.initConfig(args...)
+	case "tail":
+		cli.tail(args...)
+	case "lint":
+		cli.lint(args...)
 	case "version":
 		fmt.Printf("%s\n", version)
 	}
 }
 
 type CLI struct {
-	config *core.Config
+	config     *core.Config
+	configPath string
+	// tracer exports spans of requests and builds, nil unless an OTLP
+	// exporter is configured
+	tracer *otlp.Tracer
 }
 
 func (cli *CLI) build(args ...string) {
-	api := api.New(cli.config)
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	filter := flags.String("filter", "", "comma separated list of extension UUIDs, type:<pattern> or label:<label> filters")
+	label := flags.String("label", "", "comma separated list of labels, only extensions with one of them are used")
+	logDir := flags.String("log-dir", "", "write the output of each extension's build to <log-dir>/<uuid>.log")
+	noCache := flags.Bool("no-cache", false, "build extensions even if their sources didn't change since the last build")
+	since := flags.String("since", "", "only build extensions with files changed since the given git ref, e.g. origin/main")
+	incremental := flags.Bool("incremental", false, "only build extensions with sources modified after their build output")
+	explain := flags.Bool("explain", false, "print the commands, working directories and environment of the builds without running them")
+	mode := addModeFlag(flags)
+	configureColors := addColorFlags(flags)
+	flags.Parse(args)
+	configureColors()
+
+	cli.filterExtensions(*filter, *label)
+	reloadable := api.NewReloadableApi(api.New(cli.config))
+	cli.tracer = newTracer()
 
 	var wg sync.WaitGroup
 	build_chan := make(chan build.Result)
 
+	options := cli.buildOptions()
+	options.NoCache = *noCache
+	options.Mode = *mode
+
+	extensions, err := core.SortByDependencies(cli.config.AllExtensions())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *explain {
+		for _, e := range extensions {
+			if err := build.NewBuilder(e, options).Explain(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+		}
+		os.Exit(0)
+	}
+
+	if options.Mode != "" {
+		log.Printf("[Build] Building in %s mode", options.Mode)
+	}
+	cli.logWarnings()
+
+	if *logDir != "" {
+		if err := os.MkdirAll(*logDir, 0755); err != nil {
+			log.Fatalf("Unable to create log directory: %v", err)
+		}
+	}
+
+	var changed map[string]bool
+	if *since != "" {
+		if changed, err = build.ChangedSince(ctx, *since, extensions); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var outdated map[string]bool
+	if *incremental {
+		if outdated, err = build.OutdatedExtensions(extensions); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Build scripts run in their own process groups and don't receive the
+	// interrupt themselves
+	onInterrupt(1, func() {
+		log.Println("[Build] Interrupted, stopping the build scripts")
+		cli.shutdownTracer()
+	})
+
 	errors := 0
-	for _, e := range cli.config.Extensions {
-		b := build.NewBuilder(e)
+	results := make([]build.Result, 0)
+	var resultsMutex sync.Mutex
+
+	// Extensions wait for the builds of their dependencies, which close
+	// their channel once done
+	built := make(map[string]chan struct{}, len(extensions))
+	failed := make(map[string]bool, len(extensions))
+	for _, e := range extensions {
+		built[e.UUID] = make(chan struct{})
+	}
 
-		wg.Add(1)
-		go b.Build(ctx, func(result build.Result) {
+	for _, e := range extensions {
+		e := e
+		extensionOptions := options
+		logFile := ""
+
+		if *logDir != "" {
+			logFile = filepath.Join(*logDir, e.UUID+".log")
+			output, err := os.Create(logFile)
+			if err != nil {
+				log.Fatalf("Unable to create log file: %v", err)
+			}
+			defer output.Close()
+			extensionOptions.Output = output
+		}
+
+		b := build.NewBuilder(e, extensionOptions)
+
+		onResult := func(result build.Result) {
 			defer wg.Done()
 			build_chan <- result
 
+			resultsMutex.Lock()
+			results = append(results, result)
+			failed[e.UUID] = !result.Success
 			if !result.Success {
 				errors++
-				log.Printf("[Build] Error: %s, Extension: %s", result.Error, result.UUID)
+			}
+			resultsMutex.Unlock()
+			close(built[e.UUID])
+
+			if !result.Success && logFile != "" {
+				log.Printf("[Build] %s %s, Extension: %s (%s), see %s", colorize(red, "Error:"), result.Error, result.UUID, result.Duration.Round(time.Millisecond), logFile)
+			} else if !result.Success {
+				log.Printf("[Build] %s %s, Extension: %s (%s)", colorize(red, "Error:"), result.Error, result.UUID, result.Duration.Round(time.Millisecond))
+			} else if result.Cached {
+				log.Printf("[Build] %s Extension: %s, sources didn't change", colorize(green, "Cached"), result.UUID)
 			} else {
-				log.Printf("[Build] Success! Extension: %s", result.UUID)
+				log.Printf("[Build] %s Extension: %s (%s)", colorize(green, "Success!"), result.UUID, result.Duration.Round(time.Millisecond))
 			}
-		})
+		}
+
+		wg.Add(1)
+		go func() {
+			for _, dependency := range e.DependsOn {
+				if done, ok := built[dependency]; ok {
+					<-done
+
+					resultsMutex.Lock()
+					dependencyFailed := failed[dependency]
+					resultsMutex.Unlock()
+
+					if dependencyFailed {
+						onResult(build.Result{Success: false, Error: fmt.Errorf("dependency %s failed to build", dependency), UUID: e.UUID})
+						return
+					}
+				}
+			}
+			// Extensions unaffected by the changes since --since, or with
+			// build output newer than their sources with --incremental, are
+			// skipped and reported as cached
+			if (changed != nil && !changed[e.UUID]) || (outdated != nil && !outdated[e.UUID]) {
+				onResult(build.Result{Success: true, UUID: e.UUID, Cached: true})
+				return
+			}
+			b.Build(ctx, onResult)
+		}()
 
-		go cli.monitor(wg, build_chan, "Build", api, e)
+		go cli.monitor(build_chan, "Build", reloadable, e)
 	}
 
 	wg.Wait()
+	close(build_chan)
+
+	logBuildSummary(results)
+	cli.shutdownTracer()
 
 	if errors > 0 {
 		os.Exit(1)
@@ -91,74 +274,579 @@ func (cli *CLI) build(args ...string) {
 }
 
 func (cli *CLI) create(args ...string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	createOptions := addCreateFlags(flags)
+	flags.Parse(args)
+
 	extension := cli.config.Extensions[0]
-	err := create.NewExtensionProject(extension)
+	err := create.NewExtensionProject(extension, createOptions())
 	if err != nil {
 		panic(fmt.Errorf("failed to create a new extension: %w", err))
 	}
 }
 
+func (cli *CLI) upgrade(args ...string) {
+	flags := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	apply := flags.Bool("apply", false, "write the changes instead of only reporting them")
+	createOptions := addCreateFlags(flags)
+	flags.Parse(args)
+
+	extension := cli.config.Extensions[0]
+	if rootDir := flags.Arg(0); rootDir != "" {
+		extension.Development.RootDir = rootDir
+	}
+
+	changes, err := create.PlanUpgrade(extension, createOptions())
+	if err != nil {
+		log.Fatalf("failed to upgrade extension: %v", err)
+	}
+
+	if len(changes) == 0 {
+		log.Printf("Extension in %s is up to date", extension.Development.RootDir)
+		return
+	}
+
+	for _, change := range changes {
+		if change.Original == nil {
+			fmt.Printf("%s (new file)\n%s\n", change.Path, change.Diff())
+		} else {
+			fmt.Printf("%s\n%s\n", change.Path, change.Diff())
+		}
+	}
+
+	if !*apply {
+		log.Printf("Run upgrade with --apply to write %d changed files", len(changes))
+		return
+	}
+
+	if err := create.ApplyUpgrade(changes); err != nil {
+		log.Fatalf("failed to upgrade extension: %v", err)
+	}
+	log.Printf("Upgraded %d files in %s", len(changes), extension.Development.RootDir)
+}
+
+// initConfig writes a starter configuration with an extension of each of the
+// given types to stdout
+func (cli *CLI) initConfig(types ...string) {
+	if len(types) == 0 {
+		log.Fatalf("Usage: init-config <type>..., supported types: %s", strings.Join(create.SupportedTypes(), ", "))
+	}
+
+	content, err := create.GenerateConfig(types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(content)
+}
+
+// migrateConfig applies the migrations a configuration is missing and writes
+// the result to stdout, or replaces the file with --in-place after backing it
+// up.
+func (cli *CLI) migrateConfig(args ...string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: migrate-config <config.yml> [--in-place]")
+	}
+	path := args[0]
+
+	flags := flag.NewFlagSet("migrate-config", flag.ExitOnError)
+	inPlace := flags.Bool("in-place", false, "replace the configuration, keeping the original as <config>.bak")
+	flags.Parse(args[1:])
+
+	if strings.HasSuffix(path, ".toml") || filepath.Base(path) == "package.json" {
+		log.Fatal("Only YAML configurations can be migrated")
+	}
+
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = readStdin()
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrated, applied, err := core.MigrateConfig(content)
+	if err != nil {
+		log.Fatalf("Unable to migrate %s: %v", path, err)
+	}
+
+	for _, migration := range applied {
+		log.Printf("[Migrate] %d: %s", migration.Version, migration.Description)
+	}
+
+	if !*inPlace || path == "-" {
+		os.Stdout.Write(migrated)
+		return
+	}
+
+	if len(applied) == 0 {
+		log.Printf("%s is up to date", path)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(path+".bak", content, info.Mode().Perm()); err != nil {
+		log.Fatalf("Unable to back up %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Migrated %s, the original is kept in %s.bak", path, path)
+}
+
+// addCreateFlags registers the flags shared by create and upgrade. The
+// returned function collects their values once the flags have been parsed.
+func addCreateFlags(flags *flag.FlagSet) func() create.Options {
+	sourceDir := flags.String("source-dir", "src", "name of the directory holding the extension's source files")
+	outputDir := flags.String("output-dir", "", "name of the build directory, defaults to the configured build_dir or build")
+	withTests := flags.Bool("with-tests", false, "scaffold a test file and a test script")
+	varsFile := flags.String("vars", "", "YAML file with variables available to templates as .Vars")
+	configFormat := flags.String("config-format", "yaml", "yaml, or toml to also generate a shopify.extension.toml")
+	exclude := flags.String("exclude", "", "comma separated list of templates not to render, e.g. package.json.tpl")
+	fileMode := flags.String("file-mode", "0644", "permissions of created files in octal, .env files are always created with 0600")
+	renderer := flags.String("renderer", "", "renderer package, optionally pinned as name@version, defaults to the configured renderer")
+	checkTemplates := flags.Bool("check-templates", false, "validate all templates before rendering any")
+	maxTemplateOutput := flags.Int64("max-template-output", 0, "maximum size in bytes a single template may render to, defaults to 5 MiB, negative disables the limit")
+
+	return func() create.Options {
+		mode, err := strconv.ParseUint(*fileMode, 8, 32)
+		if err != nil || mode > 0777 {
+			log.Fatalf("Invalid file mode %q, expected permissions in octal like 0644", *fileMode)
+		}
+		// A zero mode means the default to create.Options, created files
+		// wouldn't be readable by anyone
+		if mode == 0 {
+			log.Fatalf("Invalid file mode %q, files need some permissions, e.g. 0600", *fileMode)
+		}
+
+		return create.Options{
+			SourceDir:         *sourceDir,
+			BuildDir:          *outputDir,
+			WithTests:         *withTests,
+			VarsFile:          *varsFile,
+			ConfigFormat:      *configFormat,
+			Exclude:           splitList(*exclude),
+			Renderer:          *renderer,
+			FileMode:          os.FileMode(mode),
+			CheckTemplates:    *checkTemplates,
+			MaxTemplateOutput: *maxTemplateOutput,
+		}
+	}
+}
+
 func (cli *CLI) serve(args ...string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	allowRemoteShutdown := flags.Bool("allow-remote-shutdown", false, "stop the server on POST /shutdown")
+	filter := flags.String("filter", "", "comma separated list of extension UUIDs, type:<pattern> or label:<label> filters")
+	label := flags.String("label", "", "comma separated list of labels, only extensions with one of them are used")
+	buildOnStart := flags.Bool("build-on-start", false, "build the extensions once the server is listening")
+	checkTemplates := flags.Bool("check-templates", false, "exit when the preview page of an extension can't be rendered instead of logging a warning")
+	assetsOnly := flags.Bool("assets-only", false, "only serve the build directories, without manifest and status updates")
+	accessLogFormat := flags.String("access-log-format", "", "write a line per request to stdout, in clf (Common Log Format)")
+	debugEndpoints := flags.Bool("debug-endpoints", false, "serve the complete configuration of each extension at /extensions/{uuid}/debug")
+	progress := flags.Bool("progress", false, "send build_progress updates with the percentage of builds to clients")
+	mode := addModeFlag(flags)
+	configureColors := addColorFlags(flags)
+	flags.Parse(args)
+	configureColors()
+
+	cli.filterExtensions(*filter, *label)
+	cli.config.DebugEndpoints = *debugEndpoints
+	cli.config.AssetsOnly = *assetsOnly
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cli.config.Port))
+	if err != nil {
+		log.Fatal(describeListenError(err, cli.config.Port))
+	}
+	// Port 0 asks the OS for a free port, asset URLs have to use the one we got.
+	cli.config.Port = listener.Addr().(*net.TCPAddr).Port
+
 	log.Printf("Shopify CLI Extensions Server is now available at http://localhost:%d/", cli.config.Port)
-	api := api.New(cli.config)
+	reloadable := api.NewReloadableApi(api.New(cli.config))
 
-	var wg sync.WaitGroup
+	if err := reloadable.Current().CheckTemplates(); err != nil && *checkTemplates {
+		log.Fatal(err)
+	} else if err != nil {
+		log.Printf("%s %v", colorize(red, "[Warning]"), err)
+	}
+	cli.logWarnings()
+
+	for _, namespace := range cli.namespaces() {
+		for _, e := range namespace.Extensions {
+			if token := e.Development.PreviewToken; token != "" {
+				log.Printf("Share extension %s at %s%s/extensions/%s?token=%s", e.UUID, namespace.PublicBaseUrl(e.Type), namespace.ApiRoot, e.UUID, url.QueryEscape(token))
+			}
+		}
+	}
+
+	cli.tracer = newTracer()
 
 	develop_chan := make(chan build.Result)
 	watch_chan := make(chan build.Result)
 
-	for _, e := range cli.config.Extensions {
-		b := build.NewBuilder(e)
+	options := cli.buildOptions()
+	options.Mode = *mode
+	if options.Mode != "" {
+		log.Printf("Extensions are built in %s mode", options.Mode)
+	}
 
-		wg.Add(1)
-		go b.Develop(ctx, func(result build.Result) {
-			develop_chan <- result
-		})
+	for _, e := range cli.config.AllExtensions() {
+		e := e
+		extensionOptions := options
+		if *progress {
+			extensionOptions.OnProgress = func(percent int) {
+				reloadable.Current().Notify(api.StatusUpdate{Type: "build_progress", Extensions: []core.Extension{e}, Progress: &percent})
+			}
+		}
+		b := build.NewBuilder(e, extensionOptions)
+
+		go func() {
+			// The initial build finishes before the development build starts
+			// writing into the same build directory
+			if *buildOnStart {
+				build_chan := make(chan build.Result)
+				go cli.monitor(build_chan, "Build", reloadable, e)
+
+				b.Build(ctx, func(result build.Result) {
+					build_chan <- result
+				})
+				close(build_chan)
+			}
 
-		go cli.monitor(wg, develop_chan, "Develop", api, e)
+			b.Develop(ctx, func(result build.Result) {
+				develop_chan <- result
+			})
+		}()
+
+		go cli.monitor(develop_chan, "Develop", reloadable, e)
 
 		go b.Watch(ctx, func(result build.Result) {
 			watch_chan <- result
 		})
 
-		go cli.monitor(wg, watch_chan, "Watch", api, e)
+		go cli.monitor(watch_chan, "Watch", reloadable, e)
+	}
+
+	// The timeouts only apply to regular requests and the websocket upgrade
+	// request. The websocket upgrader clears the deadlines set by the server
+	// once it hijacked the connection, so status updates aren't cut off.
+	//
+	// Without tracer the trace context of requests is only passed on to the
+	// upstream proxy. A nil *otlp.Tracer isn't a nil api.RequestTracer.
+	var requestTracer api.RequestTracer
+	if cli.tracer != nil {
+		requestTracer = cli.tracer
+	}
+	handler := api.NewTraceContext(reloadable, requestTracer)
+	if *accessLogFormat != "" {
+		if handler, err = api.NewAccessLog(handler, *accessLogFormat, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: timeout(cli.config.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		ReadTimeout:       timeout(cli.config.ReadTimeout, defaultReadTimeout),
+		IdleTimeout:       timeout(cli.config.IdleTimeout, defaultIdleTimeout),
 	}
 
-	addr := fmt.Sprintf(":%d", cli.config.Port)
+	var once sync.Once
+	stopped := make(chan struct{})
+	// The server stops accepting connections and waits for in-flight
+	// requests while websocket clients are closed, both within the same
+	// grace period. Whatever is left once it's over is closed forcefully.
+	shutdown := func() {
+		once.Do(func() {
+			shutdownCtx, cancel := context.WithTimeout(ctx, timeout(cli.config.ShutdownTimeout, defaultShutdownTimeout))
+			defer cancel()
+
+			closed := make(chan struct{})
+			go func() {
+				reloadable.Current().Shutdown()
+				close(closed)
+			}()
+
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Shutdown grace period exceeded, closing remaining connections: %v", err)
+				server.Close()
+			}
 
-	server := &http.Server{Addr: addr, Handler: api}
+			select {
+			case <-closed:
+			case <-shutdownCtx.Done():
+			}
+			cli.shutdownTracer()
+			close(stopped)
+		})
+	}
+
+	onInterrupt(0, shutdown)
+
+	handleCommands := func(a *api.ExtensionsApi) {
+		if *allowRemoteShutdown {
+			// Lets test harnesses tear the server down without killing the process,
+			// which can leave the port in TIME_WAIT.
+			a.HandleCommand("/shutdown", func(rw http.ResponseWriter, r *http.Request) {
+				log.Println("Remote shutdown requested")
+				rw.WriteHeader(http.StatusAccepted)
+				go shutdown()
+			})
+		}
+	}
+	handleCommands(reloadable.Current())
 
-	onInterrupt(func() {
-		api.Shutdown()
-		server.Shutdown(ctx)
+	onHangup(func() {
+		err := reloadable.Reload(func() (*api.ExtensionsApi, error) {
+			config, err := cli.reloadConfig(*filter, *label, *debugEndpoints, *assetsOnly)
+			if err != nil {
+				return nil, err
+			}
+			a := api.New(config)
+			handleCommands(a)
+			return a, nil
+		})
+		if err != nil {
+			log.Printf("%s unable to reload the configuration: %v", colorize(red, "[Reload]"), err)
+			return
+		}
+		log.Printf("[Reload] Reloaded the configuration from %s", cli.configPath)
 	})
 
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	if err := server.Serve(listener); err != http.ErrServerClosed {
 		panic(err)
 	}
 
-	wg.Wait()
+	<-stopped
+}
+
+// splitList splits a comma separated flag value, ignoring empty elements
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, element := range strings.Split(value, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			list = append(list, element)
+		}
+	}
+	return list
+}
+
+// logBuildSummary lists the build times of all extensions, slowest first
+func logBuildSummary(results []build.Result) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Duration > results[j].Duration
+	})
+
+	log.Println("[Build] Build times:")
+	for _, result := range results {
+		status := colorize(green, "ok")
+		if result.Cached {
+			status = colorize(green, "cached")
+		} else if !result.Success {
+			status = colorize(red, "failed")
+		}
+		log.Printf("[Build]   %8s  %s (%s)", result.Duration.Round(time.Millisecond), result.UUID, status)
+	}
+}
+
+// logWarnings logs the warnings of all namespaces, which hosts get with the
+// manifest
+func (cli *CLI) logWarnings() {
+	for _, namespace := range cli.namespaces() {
+		for _, warning := range core.Warnings(namespace) {
+			log.Printf("%s %s", colorize(red, "[Warning]"), warning)
+		}
+	}
+}
+
+// addModeFlag registers --mode, which is validated while parsing the flags
+func addModeFlag(flags *flag.FlagSet) *build.Mode {
+	var mode build.Mode
+	flags.Func("mode", "build mode passed to the build scripts as NODE_ENV: development or production (default: NODE_ENV is inherited)", func(value string) (err error) {
+		mode, err = build.ParseMode(value)
+		return
+	})
+	return &mode
+}
+
+func (cli *CLI) buildOptions() build.Options {
+	options := build.Options{
+		Env:         build.ConcurrencyEnv(cli.config.BuildConcurrency, len(cli.config.AllExtensions())),
+		TraceParent: build.TraceParentFromEnv(os.Getenv),
+	}
+	if cli.tracer != nil {
+		options.Tracer = cli.tracer
+	}
+	return options
+}
+
+// newTracer configures the export of spans with the OTEL_* environment
+// variables, nil when no exporter is configured
+func newTracer() *otlp.Tracer {
+	tracer, err := otlp.NewTracerFromEnv(os.Getenv)
+	if err != nil {
+		log.Fatalf("Unable to configure tracing: %v", err)
+	}
+	return tracer
+}
+
+// shutdownTracer exports the spans that are still queued, if tracing is on
+func (cli *CLI) shutdownTracer() {
+	if cli.tracer == nil {
+		return
+	}
+
+	exportCtx, cancel := context.WithTimeout(ctx, traceExportTimeout)
+	defer cancel()
+	if err := cli.tracer.Shutdown(exportCtx); err != nil {
+		log.Printf("[Trace] %v", err)
+	}
+}
+
+// namespaces returns the configuration of the top level extensions followed by
+// the configurations of all apps.
+func (cli *CLI) namespaces() []*core.Config {
+	namespaces := []*core.Config{cli.config}
+	for _, app := range cli.config.Apps {
+		namespaces = append(namespaces, cli.config.ForApp(app))
+	}
+	return namespaces
+}
+
+// reloadConfig loads the configuration file again, applying the same flags
+// as when the server started. Settings of the listener, i.e. the port and the
+// timeouts, can't change while serving and are kept.
+func (cli *CLI) reloadConfig(filter, label string, debugEndpoints, assetsOnly bool) (*core.Config, error) {
+	if cli.configPath == "" || cli.configPath == "-" {
+		return nil, errors.New("the configuration was read from stdin")
+	}
+
+	config, err := loadConfigFrom(cli.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	applyFilters(config, filter, label)
+
+	config.Port = cli.config.Port
+	config.ServerVersion = cli.config.ServerVersion
+	config.ServerCommit = cli.config.ServerCommit
+	config.DebugEndpoints = debugEndpoints
+	config.AssetsOnly = assetsOnly
+	return config, nil
+}
+
+func (cli *CLI) filterExtensions(filter, label string) {
+	applyFilters(cli.config, filter, label)
+
+	if len(cli.config.AllExtensions()) == 0 && label != "" {
+		log.Fatalf("No extensions match the filter %q and the labels %q", filter, label)
+	} else if len(cli.config.AllExtensions()) == 0 {
+		log.Fatalf("No extensions match the filter %q", filter)
+	}
 }
 
-func (cli *CLI) monitor(wg sync.WaitGroup, ch chan build.Result, action string, a *api.ExtensionsApi, e core.Extension) {
-	defer wg.Done()
+// applyFilters keeps the extensions matching --filter that have one of the
+// labels of --label
+func applyFilters(config *core.Config, filter, label string) {
+	labelFilters := make([]string, 0)
+	for _, name := range strings.Split(label, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			labelFilters = append(labelFilters, "label:"+name)
+		}
+	}
+
+	for _, filters := range [][]string{strings.Split(filter, ","), labelFilters} {
+		config.Extensions = core.FilterExtensions(config.Extensions, filters)
+		for index, app := range config.Apps {
+			config.Apps[index].Extensions = core.FilterExtensions(app.Extensions, filters)
+		}
+	}
+}
 
+func (cli *CLI) monitor(ch chan build.Result, action string, reloadable *api.ReloadableApi, e core.Extension) {
 	for result := range ch {
-		if result.Success {
+		a := reloadable.Current()
+		if result.Success && result.Asset != "" {
+			log.Printf("[%s] asset %s changed for extension: %s", action, result.Asset, result.UUID)
+			go a.Notify(api.StatusUpdate{Type: "asset_changed", Extensions: []core.Extension{e}, Asset: &api.ChangedAsset{Name: result.Asset}})
+		} else if result.Success {
 			log.Printf("[%s] event for extension: %s", action, result.UUID)
-			go a.Notify(api.StatusUpdate{Type: "success", Extensions: []core.Extension{e}})
+			go a.Notify(api.StatusUpdate{Type: "success", Extensions: []core.Extension{e}, Duration: result.Duration.Milliseconds()})
 		} else {
-			log.Printf("[%s] error for extension %s, error: %s", action, result.UUID, result.Error.Error())
-			go a.Notify(api.StatusUpdate{Type: "error", Extensions: []core.Extension{e}})
+			log.Printf("[%s] %s for extension %s, error: %s", action, colorize(red, "error"), result.UUID, result.Error.Error())
+			go a.Notify(api.StatusUpdate{Type: "error", Extensions: []core.Extension{e}, Duration: result.Duration.Milliseconds()})
 		}
 	}
 }
 
+// timeout returns the configured timeout or the fallback when none is
+// configured. Negative timeouts are passed on: the HTTP server treats them
+// as disabled, while a negative shutdown_timeout expires right away.
+func timeout(configured, fallback time.Duration) time.Duration {
+	if configured == 0 {
+		return fallback
+	}
+	return configured
+}
+
+func describeListenError(err error, port int) error {
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return fmt.Errorf(
+			"port %d is already in use, possibly by another instance of the server. "+
+				"Choose a different port or use port 0 to pick a free one",
+			port,
+		)
+	}
+	return fmt.Errorf("unable to listen on port %d: %w", port, err)
+}
+
+// isFlag tells flags apart from the configuration argument, where - stands
+// for stdin
+func isFlag(arg string) bool {
+	return strings.HasPrefix(arg, "-") && arg != "-"
+}
+
+// findProjectFile returns the .shopify-cli.yml of the working directory or
+// its parents, relative to the working directory when possible
+func findProjectFile() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	path, err := core.FindProjectFile(cwd)
+	if err != nil {
+		return "", err
+	}
+
+	if relative, err := filepath.Rel(cwd, path); err == nil {
+		return relative, nil
+	}
+	return path, nil
+}
+
 func loadConfigFrom(path string) (config *core.Config, err error) {
+	if filepath.Base(path) == core.ProjectFile {
+		if config, err = core.LoadProjectConfig(path); err == nil {
+			build.ResolveBuildDirs(config)
+		}
+		return
+	}
+
 	var configSource io.ReadCloser
 
 	if path == "-" {
-		configSource = os.Stdin
+		var content []byte
+		if content, err = readStdin(); err != nil {
+			return
+		}
+		configSource = io.NopCloser(bytes.NewReader(content))
 	} else {
 		configSource, err = os.Open(path)
 		if err != nil {
@@ -167,20 +855,42 @@ func loadConfigFrom(path string) (config *core.Config, err error) {
 		defer configSource.Close()
 	}
 
-	config, err = core.LoadConfig(configSource)
-	if err != nil {
-		panic(err)
+	if strings.HasSuffix(path, ".toml") {
+		config, err = core.LoadTOMLConfig(configSource)
+	} else if filepath.Base(path) == "package.json" {
+		config, err = core.LoadPackageJSONConfig(configSource)
+	} else {
+		config, err = core.LoadConfig(configSource)
 	}
 
+	// The server and the builds have to agree on build directories that
+	// aren't configured
+	if err == nil {
+		build.ResolveBuildDirs(config)
+	}
 	return
 }
 
-func onInterrupt(handle func()) {
-	interrupt := make(chan os.Signal)
+// onHangup calls handle whenever the process receives SIGHUP
+func onHangup(handle func()) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			handle()
+		}
+	}()
+}
+
+// onInterrupt calls handle on SIGINT or SIGTERM, then kills the build scripts
+// that are still running and exits with the given code
+func onInterrupt(code int, handle func()) {
+	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-interrupt
 		handle()
-		os.Exit(0)
+		build.StopCommands()
+		os.Exit(code)
 	}()
 }
@@ -0,0 +1,435 @@
+// The otlp package records the spans of requests and builds and exports them
+// to an OpenTelemetry collector with OTLP over HTTP, encoded as JSON. It's
+// configured with the standard OTEL_* environment variables and stays off
+// unless an endpoint is configured, see DECISIONS.md for why the SDK isn't
+// used.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/shopify-cli-extensions/api"
+	"github.com/Shopify/shopify-cli-extensions/build"
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+const (
+	defaultServiceName = "shopify-extensions"
+	// exportInterval and maxQueueSize follow the defaults of the batch span
+	// processor of the OpenTelemetry SDKs
+	exportInterval = 5 * time.Second
+	maxQueueSize   = 2048
+	exportTimeout  = 10 * time.Second
+)
+
+// Span kinds and status codes of the OTLP protocol
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	statusOk         = 1
+	statusError      = 2
+)
+
+// Tracer records a span per request and per production build, it implements
+// api.RequestTracer and build.Tracer. Spans are exported in batches every
+// few seconds and once the tracer is shut down.
+type Tracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	client   *http.Client
+
+	mutex sync.Mutex
+	queue []span
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewTracerFromEnv configures a tracer with the OTEL_* environment variables.
+// Without OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT,
+// with OTEL_TRACES_EXPORTER=none or OTEL_SDK_DISABLED=true it returns nil.
+// Only the http/json protocol is supported.
+func NewTracerFromEnv(getenv func(string) string) (*Tracer, error) {
+	if disabled, _ := strconv.ParseBool(getenv("OTEL_SDK_DISABLED")); disabled {
+		return nil, nil
+	}
+
+	switch exporter := getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", "otlp":
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %s, only otlp is supported", exporter)
+	}
+
+	endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if endpoint == "" {
+		return nil, nil
+	}
+	if parsed, err := url.Parse(endpoint); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %s, expected an http or https URL", endpoint)
+	}
+
+	protocol := getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/json" {
+		return nil, fmt.Errorf("unsupported OTLP protocol %s, only http/json is supported", protocol)
+	}
+
+	headers, err := parseHeaders(getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	traceHeaders, err := parseHeaders(getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range traceHeaders {
+		headers[key] = value
+	}
+
+	service := getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = defaultServiceName
+	}
+
+	return newTracer(endpoint, headers, service, exportInterval), nil
+}
+
+func newTracer(endpoint string, headers map[string]string, service string, interval time.Duration) *Tracer {
+	tracer := &Tracer{
+		endpoint: endpoint,
+		headers:  headers,
+		service:  service,
+		client:   &http.Client{Timeout: exportTimeout},
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go tracer.run(interval)
+	return tracer
+}
+
+// parseHeaders parses the W3C baggage like list of headers of
+// OTEL_EXPORTER_OTLP_HEADERS, e.g. api-key=secret,tenant=dev
+func parseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, header := range strings.Split(value, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+
+		key, value, found := cut(header, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid OTLP header %q, expected key=value", header)
+		}
+
+		decoded, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP header %q: %w", header, err)
+		}
+		headers[strings.TrimSpace(key)] = decoded
+	}
+	return headers, nil
+}
+
+// cut is strings.Cut, which isn't available with Go 1.16
+func cut(s, separator string) (string, string, bool) {
+	if index := strings.Index(s, separator); index >= 0 {
+		return s[:index], s[index+len(separator):], true
+	}
+	return s, "", false
+}
+
+// StartRequest starts the server span of a request. Requests whose parent
+// isn't sampled aren't recorded.
+func (tracer *Tracer) StartRequest(r *http.Request, parent *core.TraceParent) api.RequestSpan {
+	span, sampled := tracer.start(r.Method, spanKindServer, parent)
+	if !sampled {
+		return unsampledRequest{}
+	}
+
+	span.Attributes = append(span.Attributes,
+		stringAttribute("http.request.method", r.Method),
+		stringAttribute("url.path", r.URL.Path),
+	)
+	if uuid := extensionUUID(r.URL.Path); uuid != "" {
+		span.Attributes = append(span.Attributes, stringAttribute("extension.uuid", uuid))
+	}
+	return &requestSpan{tracer, span}
+}
+
+// StartBuild starts the span of a production build. Builds whose parent
+// isn't sampled aren't recorded.
+func (tracer *Tracer) StartBuild(ctx context.Context, extension core.Extension, parent *core.TraceParent) build.BuildSpan {
+	span, sampled := tracer.start("build", spanKindInternal, parent)
+	if !sampled {
+		return unsampledBuild{}
+	}
+
+	span.Attributes = append(span.Attributes,
+		stringAttribute("extension.uuid", extension.UUID),
+		stringAttribute("extension.type", extension.Type),
+	)
+	return &buildSpan{tracer, span}
+}
+
+// Shutdown stops the periodic export and exports the remaining spans
+func (tracer *Tracer) Shutdown(ctx context.Context) error {
+	tracer.stopOnce.Do(func() {
+		close(tracer.stop)
+	})
+	<-tracer.stopped
+	return tracer.export(ctx)
+}
+
+// start creates a span with a new ID in the trace of the parent, or in a new
+// trace without a parent
+func (tracer *Tracer) start(name string, kind int, parent *core.TraceParent) (span, bool) {
+	span := span{
+		SpanID:    newID(8),
+		Name:      name,
+		Kind:      kind,
+		StartTime: uint64(time.Now().UnixNano()),
+	}
+
+	if parent == nil {
+		span.TraceID = newID(16)
+		return span, true
+	}
+
+	flags, err := strconv.ParseUint(parent.Flags, 16, 8)
+	if err != nil || flags&1 == 0 {
+		return span, false
+	}
+	span.TraceID = parent.TraceID
+	span.ParentSpanID = parent.ParentID
+	return span, true
+}
+
+// end queues the span for the next export. Spans are dropped while the
+// queue is full.
+func (tracer *Tracer) end(span span) {
+	span.EndTime = uint64(time.Now().UnixNano())
+
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	if len(tracer.queue) < maxQueueSize {
+		tracer.queue = append(tracer.queue, span)
+	}
+}
+
+func (tracer *Tracer) run(interval time.Duration) {
+	defer close(tracer.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := tracer.export(context.Background()); err != nil {
+				log.Printf("[Trace] %v", err)
+			}
+		case <-tracer.stop:
+			return
+		}
+	}
+}
+
+// export sends the queued spans to the collector in a single request
+func (tracer *Tracer) export(ctx context.Context) error {
+	tracer.mutex.Lock()
+	spans := tracer.queue
+	tracer.queue = nil
+	tracer.mutex.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(exportRequest{[]resourceSpans{{
+		Resource:   resource{[]attribute{stringAttribute("service.name", tracer.service)}},
+		ScopeSpans: []scopeSpans{{scope{"github.com/Shopify/shopify-cli-extensions"}, spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tracer.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, value := range tracer.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := tracer.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to export %d spans: %w", len(spans), err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unable to export %d spans, the collector responded with %s", len(spans), response.Status)
+	}
+	return nil
+}
+
+// extensionUUID returns the UUID of the extension a request path belongs to,
+// e.g. /extensions/{uuid}/assets/main.js, if any
+func extensionUUID(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for index := 0; index < len(segments)-1; index++ {
+		if segments[index] == "extensions" && segments[index+1] != "" {
+			return segments[index+1]
+		}
+	}
+	return ""
+}
+
+// newID returns a random trace or span ID of size bytes, hex encoded
+func newID(size int) string {
+	id := make([]byte, size)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%0*x", size*2, time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+type requestSpan struct {
+	tracer *Tracer
+	span   span
+}
+
+func (s *requestSpan) TraceParent() *core.TraceParent {
+	return &core.TraceParent{TraceID: s.span.TraceID, ParentID: s.span.SpanID, Flags: "01"}
+}
+
+// End records the status of the response, server errors fail the span
+func (s *requestSpan) End(status int) {
+	s.span.Attributes = append(s.span.Attributes, intAttribute("http.response.status_code", int64(status)))
+	if status >= 500 {
+		s.span.Status = spanStatus{Code: statusError}
+	}
+	s.tracer.end(s.span)
+}
+
+type buildSpan struct {
+	tracer *Tracer
+	span   span
+}
+
+// End records the result of the build, failed builds fail the span
+func (s *buildSpan) End(result build.Result) {
+	s.span.Attributes = append(s.span.Attributes, boolAttribute("build.cached", result.Cached))
+	if result.Mode != "" {
+		s.span.Attributes = append(s.span.Attributes, stringAttribute("build.mode", string(result.Mode)))
+	}
+
+	if result.Success {
+		s.span.Status = spanStatus{Code: statusOk}
+	} else if result.Error != nil {
+		s.span.Status = spanStatus{Code: statusError, Message: result.Error.Error()}
+	} else {
+		s.span.Status = spanStatus{Code: statusError}
+	}
+	s.tracer.end(s.span)
+}
+
+type unsampledRequest struct{}
+
+func (unsampledRequest) TraceParent() *core.TraceParent {
+	return nil
+}
+
+func (unsampledRequest) End(status int) {}
+
+type unsampledBuild struct{}
+
+func (unsampledBuild) End(result build.Result) {}
+
+// The types below are the JSON encoding of the OTLP export request, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type span struct {
+	TraceID      string      `json:"traceId"`
+	SpanID       string      `json:"spanId"`
+	ParentSpanID string      `json:"parentSpanId,omitempty"`
+	Name         string      `json:"name"`
+	Kind         int         `json:"kind"`
+	StartTime    uint64      `json:"startTimeUnixNano,string"`
+	EndTime      uint64      `json:"endTimeUnixNano,string"`
+	Attributes   []attribute `json:"attributes,omitempty"`
+	Status       spanStatus  `json:"status"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *int64  `json:"intValue,omitempty,string"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func stringAttribute(key, value string) attribute {
+	return attribute{key, attributeValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int64) attribute {
+	return attribute{key, attributeValue{IntValue: &value}}
+}
+
+func boolAttribute(key string, value bool) attribute {
+	return attribute{key, attributeValue{BoolValue: &value}}
+}
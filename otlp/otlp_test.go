@@ -0,0 +1,230 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/shopify-cli-extensions/api"
+	"github.com/Shopify/shopify-cli-extensions/build"
+	"github.com/Shopify/shopify-cli-extensions/core"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func TestNewTracerFromEnv(t *testing.T) {
+	for _, values := range []map[string]string{
+		{},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_TRACES_EXPORTER": "none"},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_SDK_DISABLED": "true"},
+	} {
+		tracer, err := NewTracerFromEnv(env(values))
+		if tracer != nil || err != nil {
+			t.Errorf("Expected tracing to be off with %v, got %v, %v", values, tracer, err)
+		}
+	}
+
+	for _, values := range []map[string]string{
+		{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_TRACES_EXPORTER": "zipkin"},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT": "localhost:4318"},
+		{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_EXPORTER_OTLP_HEADERS": "api-key"},
+	} {
+		if _, err := NewTracerFromEnv(env(values)); err == nil {
+			t.Errorf("Expected an error with %v", values)
+		}
+	}
+
+	tracer, err := NewTracerFromEnv(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":       "http://localhost:4318/",
+		"OTEL_EXPORTER_OTLP_PROTOCOL":       "http/json",
+		"OTEL_EXPORTER_OTLP_HEADERS":        "api-key=secret,tenant=dev",
+		"OTEL_EXPORTER_OTLP_TRACES_HEADERS": "tenant=my%20team",
+		"OTEL_SERVICE_NAME":                 "extensions",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracer.Shutdown(context.TODO())
+
+	if tracer.endpoint != "http://localhost:4318/v1/traces" {
+		t.Errorf("Expected the traces path to be appended to the endpoint, got %s", tracer.endpoint)
+	}
+	if tracer.headers["api-key"] != "secret" || tracer.headers["tenant"] != "my team" {
+		t.Errorf("Expected the trace headers to take precedence, got %v", tracer.headers)
+	}
+	if tracer.service != "extensions" {
+		t.Errorf("Expected the service name to be configurable, got %s", tracer.service)
+	}
+
+	tracer, err = NewTracerFromEnv(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://localhost:4318",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:9999/traces",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracer.Shutdown(context.TODO())
+
+	if tracer.endpoint != "http://localhost:9999/traces" || tracer.service != defaultServiceName {
+		t.Errorf("Expected the traces endpoint to be used as is, got %s", tracer.endpoint)
+	}
+}
+
+// collector records the export requests it receives
+type collector struct {
+	mutex    sync.Mutex
+	requests []exportRequest
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var request exportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, request)
+	c.headers = append(c.headers, r.Header)
+}
+
+func (c *collector) spans() []span {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	spans := []span{}
+	for _, request := range c.requests {
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				spans = append(spans, scopeSpans.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func attributes(span span) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, attribute := range span.Attributes {
+		switch value := attribute.Value; {
+		case value.StringValue != nil:
+			values[attribute.Key] = *value.StringValue
+		case value.IntValue != nil:
+			values[attribute.Key] = *value.IntValue
+		case value.BoolValue != nil:
+			values[attribute.Key] = *value.BoolValue
+		}
+	}
+	return values
+}
+
+func TestTracerExportsSpans(t *testing.T) {
+	collector := &collector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	tracer := newTracer(server.URL+"/v1/traces", map[string]string{"api-key": "secret"}, defaultServiceName, time.Hour)
+
+	var forwarded string
+	handler := api.NewTraceContext(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("traceparent")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}), tracer)
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("GET", "/extensions/00000000-0000-0000-0000-000000000000/assets/main.js", nil)
+	req.Header.Set("traceparent", traceParent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	parent, _ := core.ParseTraceParent(traceParent)
+	extension := core.Extension{UUID: "00000000-0000-0000-0000-000000000001", Type: "checkout_ui_extension"}
+	tracer.StartBuild(context.TODO(), extension, &parent).End(build.Result{Success: false, Error: errors.New("build script failed"), UUID: extension.UUID, Mode: build.Production})
+
+	unsampled, _ := core.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	tracer.StartBuild(context.TODO(), extension, &unsampled).End(build.Result{Success: true, UUID: extension.UUID})
+
+	if err := tracer.Shutdown(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := collector.spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected the request and the sampled build span to be exported, got %+v", spans)
+	}
+	if collector.headers[0].Get("api-key") != "secret" || collector.headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the configured headers to be sent, got %v", collector.headers[0])
+	}
+	service := collector.requests[0].ResourceSpans[0].Resource.Attributes[0]
+	if service.Key != "service.name" || *service.Value.StringValue != defaultServiceName {
+		t.Errorf("Expected the service name as resource attribute, got %+v", service)
+	}
+
+	request := spans[0]
+	if request.TraceID != parent.TraceID || request.ParentSpanID != parent.ParentID || request.Kind != spanKindServer {
+		t.Errorf("Expected the request span to be a server span of the incoming trace, got %+v", request)
+	}
+	if expected := fmt.Sprintf("00-%s-%s-01", parent.TraceID, request.SpanID); forwarded != expected {
+		t.Errorf("Expected the request span to be the forwarded parent %s, got %s", expected, forwarded)
+	}
+	if values := attributes(request); values["http.request.method"] != "GET" || values["http.response.status_code"] != int64(503) || values["extension.uuid"] != "00000000-0000-0000-0000-000000000000" {
+		t.Errorf("Expected the request attributes, got %v", values)
+	}
+	if request.Status.Code != statusError || request.EndTime < request.StartTime {
+		t.Errorf("Expected a failed request span, got %+v", request)
+	}
+
+	built := spans[1]
+	if built.TraceID != parent.TraceID || built.ParentSpanID != parent.ParentID || built.Name != "build" {
+		t.Errorf("Expected the build span to be a child of the TRACEPARENT, got %+v", built)
+	}
+	if values := attributes(built); values["extension.uuid"] != extension.UUID || values["extension.type"] != extension.Type || values["build.mode"] != "production" || values["build.cached"] != false {
+		t.Errorf("Expected the build attributes, got %v", values)
+	}
+	if built.Status.Code != statusError || built.Status.Message != "build script failed" {
+		t.Errorf("Expected the error of the build as status, got %+v", built.Status)
+	}
+}
+
+func TestTracerStartsTraces(t *testing.T) {
+	tracer := newTracer("http://localhost:4318/v1/traces", map[string]string{}, defaultServiceName, time.Hour)
+
+	span := tracer.StartRequest(httptest.NewRequest("GET", "/extensions/", nil), nil)
+	traceParent := span.TraceParent()
+	if traceParent == nil || len(traceParent.TraceID) != 32 || len(traceParent.ParentID) != 16 || traceParent.Flags != "01" {
+		t.Errorf("Expected requests without trace context to start a sampled trace, got %v", traceParent)
+	}
+
+	unsampled, _ := core.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if span := tracer.StartRequest(httptest.NewRequest("GET", "/extensions/", nil), &unsampled); span.TraceParent() != nil {
+		t.Errorf("Expected requests of unsampled traces not to be recorded, got %v", span.TraceParent())
+	}
+}
+
+func TestTracerExportErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	tracer := newTracer(server.URL, map[string]string{}, defaultServiceName, time.Hour)
+	tracer.StartBuild(context.TODO(), core.Extension{}, nil).End(build.Result{Success: true})
+
+	if err := tracer.Shutdown(context.TODO()); err == nil {
+		t.Error("Expected rejected exports to fail")
+	}
+}
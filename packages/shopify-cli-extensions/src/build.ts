@@ -3,9 +3,11 @@ import {getConfigs} from './configs';
 
 export interface Options {
   mode: 'development' | 'production';
+  // Overrides the configured build_dir, e.g. to build into a temporary directory
+  outDir?: string;
 }
 
-export function build({mode}: Options) {
+export function build({mode, outDir}: Options) {
   const isDevelopment = mode === 'development';
   const {
     development: {entries, build = {}, serve = {}, buildDir},
@@ -30,7 +32,7 @@ export function build({mode}: Options) {
     logLevel: 'info',
     legalComments: isDevelopment ? 'none' : 'linked',
     minify: !isDevelopment,
-    outdir: buildDir,
+    outdir: outDir || buildDir,
     plugins: getPlugins(),
     target: 'es6',
     resolveExtensions: ['.tsx', '.ts', '.js', '.json', '.esnext', '.mjs', '.ejs'],
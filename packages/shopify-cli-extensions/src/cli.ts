@@ -5,15 +5,21 @@ import {build} from './build';
 run();
 
 async function run() {
-  const command = process.argv.slice(2)[0];
+  const [command, ...args] = process.argv.slice(2);
+  const outDir = getOption(args, '--build-dir');
   switch (command) {
     case 'build': {
-      build({mode: 'production'});
+      build({mode: 'production', outDir});
       break;
     }
     case 'develop': {
-      build({mode: 'development'});
+      build({mode: 'development', outDir});
       break;
     }
   }
 }
+
+function getOption(args: string[], name: string) {
+  const index = args.indexOf(name);
+  return index === -1 ? undefined : args[index + 1];
+}
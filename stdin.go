@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const defaultStdinTimeout = 30 * time.Second
+
+// stdinTimeoutVariable overrides how long the configuration may take to
+// arrive on stdin, e.g. SHOPIFY_EXTENSIONS_STDIN_TIMEOUT=2m. A negative
+// duration waits indefinitely.
+const stdinTimeoutVariable = "SHOPIFY_EXTENSIONS_STDIN_TIMEOUT"
+
+// readStdin reads stdin until it's closed, so that configurations written
+// slowly by another process aren't decoded before they're complete. Reading
+// fails when stdin isn't closed within the timeout.
+func readStdin() ([]byte, error) {
+	timeout, err := stdinTimeout()
+	if err != nil {
+		return nil, err
+	}
+	return readAllWithTimeout(os.Stdin, timeout)
+}
+
+func stdinTimeout() (time.Duration, error) {
+	value, ok := os.LookupEnv(stdinTimeoutVariable)
+	if !ok || value == "" {
+		return defaultStdinTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", stdinTimeoutVariable, err)
+	}
+	return timeout, nil
+}
+
+type readResult struct {
+	content []byte
+	err     error
+}
+
+func readAllWithTimeout(r io.Reader, timeout time.Duration) ([]byte, error) {
+	if timeout < 0 {
+		return io.ReadAll(r)
+	}
+
+	reader := &countingReader{Reader: r}
+	done := make(chan readResult, 1)
+	go func() {
+		content, err := io.ReadAll(reader)
+		done <- readResult{content, err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.content, result.err
+	case <-time.After(timeout):
+		if reader.count() == 0 {
+			return nil, fmt.Errorf("no configuration was received on stdin within %s", timeout)
+		}
+		return nil, fmt.Errorf("stdin wasn't closed within %s, the configuration may be incomplete", timeout)
+	}
+}
+
+// countingReader keeps track of the bytes read by the goroutine reading
+// stdin, so a timeout can tell whether anything arrived at all
+type countingReader struct {
+	io.Reader
+	bytes int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	atomic.AddInt64(&reader.bytes, int64(n))
+	return n, err
+}
+
+func (reader *countingReader) count() int64 {
+	return atomic.LoadInt64(&reader.bytes)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadAllWithTimeout(t *testing.T) {
+	reader, writer := io.Pipe()
+	go func() {
+		io.WriteString(writer, "port: 8000\n")
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(writer, "extensions: []\n")
+		writer.Close()
+	}()
+
+	content, err := readAllWithTimeout(reader, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "port: 8000\nextensions: []\n" {
+		t.Errorf("Expected everything written until stdin was closed, got %q", content)
+	}
+}
+
+func TestReadAllWithTimeoutNoData(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	_, err := readAllWithTimeout(reader, 20*time.Millisecond)
+	if err == nil || err.Error() != "no configuration was received on stdin within 20ms" {
+		t.Errorf("Expected an error about the missing configuration, got %v", err)
+	}
+}
+
+func TestReadAllWithTimeoutPartialData(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go io.WriteString(writer, "port: 8000\n")
+
+	_, err := readAllWithTimeout(reader, 50*time.Millisecond)
+	if err == nil || err.Error() != "stdin wasn't closed within 50ms, the configuration may be incomplete" {
+		t.Errorf("Expected an error about the incomplete configuration, got %v", err)
+	}
+}
+
+func TestReadAllWithoutTimeout(t *testing.T) {
+	content, err := readAllWithTimeout(strings.NewReader("port: 8000\n"), -1)
+	if err != nil || string(content) != "port: 8000\n" {
+		t.Errorf("Expected a negative timeout to read everything, got %q, %v", content, err)
+	}
+}
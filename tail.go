@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/shopify-cli-extensions/api"
+	"github.com/gorilla/websocket"
+)
+
+const defaultReconnectBackoff = 1 * time.Second
+
+// tail prints the status updates of a running server until interrupted. The
+// connection is re-established whenever it's lost, waiting the backoff the
+// server suggested plus some jitter.
+func (cli *CLI) tail(args ...string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: tail <server-url> [--json]")
+	}
+
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	printJSON := flags.Bool("json", false, "print the status updates as JSON")
+	configureColors := addColorFlags(flags)
+	flags.Parse(args[1:])
+	configureColors()
+
+	feedUrl, err := statusFeedUrl(args[0])
+	if err != nil {
+		log.Fatalf("Invalid server URL %s: %v", args[0], err)
+	}
+
+	// The current connection is closed properly on Ctrl-C
+	var connectionMutex sync.Mutex
+	var connection *websocket.Conn
+	onInterrupt(0, func() {
+		connectionMutex.Lock()
+		defer connectionMutex.Unlock()
+		if connection != nil {
+			connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		}
+	})
+
+	sessionId := ""
+	backoff := defaultReconnectBackoff
+	for {
+		current, _, err := websocket.DefaultDialer.Dial(feedUrl, nil)
+		if err != nil {
+			log.Printf("[Tail] Unable to connect to %s: %v", feedUrl, err)
+		} else {
+			log.Printf("[Tail] Connected to %s", feedUrl)
+			connectionMutex.Lock()
+			connection = current
+			connectionMutex.Unlock()
+			sessionId, backoff = readStatusUpdates(current, sessionId, backoff, *printJSON)
+		}
+
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		log.Printf("[Tail] Reconnecting in %s", wait.Round(time.Millisecond))
+		time.Sleep(wait)
+	}
+}
+
+// readStatusUpdates prints status updates until the connection is lost and
+// returns the session and the reconnect backoff of the server
+func readStatusUpdates(connection *websocket.Conn, sessionId string, backoff time.Duration, printJSON bool) (string, time.Duration) {
+	defer connection.Close()
+
+	for {
+		_, message, err := connection.ReadMessage()
+		if err != nil {
+			log.Printf("[Tail] Connection lost: %v", err)
+			return sessionId, backoff
+		}
+
+		update := api.StatusUpdate{}
+		if err := json.Unmarshal(message, &update); err != nil {
+			log.Printf("[Tail] Ignoring invalid message: %v", err)
+			continue
+		}
+
+		if update.Type == "connected" {
+			if sessionId != "" && update.SessionId != sessionId {
+				log.Printf("[Tail] The server restarted, new session %s", update.SessionId)
+			}
+			sessionId = update.SessionId
+			if update.ReconnectBackoff > 0 {
+				backoff = time.Duration(update.ReconnectBackoff) * time.Millisecond
+			}
+		}
+
+		if printJSON {
+			fmt.Fprintln(os.Stdout, string(message))
+		} else {
+			fmt.Fprintln(os.Stdout, formatStatusUpdate(update))
+		}
+	}
+}
+
+// statusFeedUrl turns the URL of a server into the URL of its status
+// websocket. URLs without a path connect to the default /extensions/.
+func statusFeedUrl(serverUrl string) (string, error) {
+	parsed, err := url.Parse(serverUrl)
+	if err != nil {
+		return "", err
+	}
+
+	switch parsed.Scheme {
+	case "http":
+		parsed.Scheme = "ws"
+	case "https":
+		parsed.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("unsupported scheme %q, expected http, https, ws or wss", parsed.Scheme)
+	}
+
+	if parsed.Path == "" || parsed.Path == "/" {
+		parsed.Path = "/extensions/"
+	}
+	return parsed.String(), nil
+}
+
+func formatStatusUpdate(update api.StatusUpdate) string {
+	uuids := make([]string, 0, len(update.Extensions))
+	for _, extension := range update.Extensions {
+		uuids = append(uuids, extension.UUID)
+	}
+
+	text := fmt.Sprintf("%s %s", time.Now().Format("15:04:05"), formatUpdateType(update.Type))
+	if len(uuids) > 0 {
+		text += " " + strings.Join(uuids, ", ")
+	}
+
+	switch {
+	case update.Asset != nil:
+		text += fmt.Sprintf(", asset %s", update.Asset.Name)
+	case update.Progress != nil && *update.Progress >= 0:
+		text += fmt.Sprintf(", %d%%", *update.Progress)
+	case update.Duration > 0:
+		text += fmt.Sprintf(" (%s)", (time.Duration(update.Duration) * time.Millisecond).Round(time.Millisecond))
+	}
+	return text
+}
+
+func formatUpdateType(updateType string) string {
+	switch updateType {
+	case "success":
+		return colorize(green, updateType)
+	case "error":
+		return colorize(red, updateType)
+	default:
+		return updateType
+	}
+}